@@ -0,0 +1,172 @@
+package main
+
+import "slices"
+
+// Accumulate at most this many jittered passes into the last plot
+const maxSamples = 16
+
+// Running sums of the colors of the samples accumulated into the last
+// plot, valid while accumData is the data of the last plot
+var (
+	accumSum  []float32
+	accumData []byte
+)
+
+// halton returns element i of the Halton low discrepancy sequence in base b
+func halton(i, b int) float64 {
+	f, r := 1.0, 0.0
+	for ; i > 0; i /= b {
+		f /= float64(b)
+		r += f * float64(i%b)
+	}
+	return r
+}
+
+// accumulateSize returns the memory used by the accumulation buffer
+func accumulateSize() int64 {
+	return 4 * int64(len(accumSum))
+}
+
+// keptSamples returns a copy of the samples kept with the last plot
+// for a pass to add to, and a count per row of the samples it adds, or
+// nil if they aren't being kept
+func keptSamples() ([][]sample, []int) {
+	if lastPlot.lostSamples {
+		return nil, nil
+	}
+	supersamples := slices.Clone(lastPlot.supersamples)
+	if supersamples == nil {
+		supersamples = make([][]sample, len(lastPlot.iters))
+	}
+	return supersamples, make([]int, lastPlot.height)
+}
+
+// setKeptSamples keeps the samples of a finished pass with the last
+// plot, as long as they fit in the memory left over by the caches, as
+// they are only needed to color it again
+func setKeptSamples(supersamples [][]sample, kept []int) {
+	if supersamples == nil {
+		return
+	}
+	lastPlot.supersamples = supersamples
+	for _, n := range kept {
+		lastPlot.kept += n
+	}
+	if memoryUsage() > maxMemory {
+		lastPlot.supersamples, lastPlot.kept, lastPlot.lostSamples = nil, 0, true
+	}
+}
+
+// flat returns true if pixel x, y of the last plot and its four
+// neighbours took the same number of iterations, or are all in the
+// set, so jittering the samples won't change it much.
+//
+// The colors vary smoothly across such pixels so the jittered samples
+// average out to the color of the center.
+func flat(x, y int) bool {
+	width, height := lastPlot.width, lastPlot.height
+	it := lastPlot.iters[y*width+x].i
+	for _, o := range [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+		nx, ny := x+o[0], y+o[1]
+		if nx < 0 || nx >= width || ny < 0 || ny >= height {
+			continue
+		}
+		ni := lastPlot.iters[ny*width+nx].i
+		if ni != it && (ni < lastPlot.depth || it < lastPlot.depth) {
+			return false
+		}
+	}
+	return true
+}
+
+// sortLongestFirst sorts the pixels xs of row y of the last plot so
+// the ones which took the most iterations come first, returning xs
+//
+// The samples of a row are iterated together, and the SIMD kernel
+// finishes them sooner if the longest orbits are started first, as
+// then its lanes run out of work at about the same time rather than
+// one of them going on alone.
+func sortLongestFirst(xs []int, y int) []int {
+	row := lastPlot.iters[y*lastPlot.width : (y+1)*lastPlot.width]
+	slices.SortStableFunc(xs, func(a, b int) int {
+		return row[b].i - row[a].i
+	})
+	return xs
+}
+
+// accumulate renders another pass of the last plot with each sample
+// jittered within its pixel and averages it into the pixels.
+//
+// The plot on screen counts as the first sample, so after enough
+// passes with the jitter following a Halton sequence the whole image
+// converges to being antialiased, not just the edges.
+func accumulate(interrupted func() bool) bool {
+	width, height := lastPlot.width, lastPlot.height
+	x0, y0, dx, dy := lastPlot.x0, lastPlot.y0, lastPlot.dx, lastPlot.dy
+	n := lastPlot.samples + 1 // samples in the plot so far
+	if len(lastPlot.data) == 0 || len(accumData) == 0 || &accumData[0] != &lastPlot.data[0] {
+		// Start the sums off from the plot
+		accumSum = make([]float32, len(lastPlot.data))
+		for i, v := range lastPlot.data {
+			accumSum[i] = float32(v) * float32(n)
+		}
+	}
+	ox, oy := halton(n, 2)-0.5, halton(n, 3)-0.5
+	sum := slices.Clone(accumSum)
+	data := slices.Clone(lastPlot.data)
+	supersamples, kept := keptSamples()
+	ok := forEachRow(height, interrupted, func(y int) {
+		fy := y0 + dy*(float64(y)+oy)
+		var xs []int
+		for x := 0; x < width; x++ {
+			p := 3 * (y*width + x)
+			if flat(x, y) {
+				for k := 0; k < 3; k++ {
+					sum[p+k] += float32(data[p+k])
+				}
+				continue
+			}
+			xs = append(xs, x)
+		}
+		ps := make([]complex128, len(xs))
+		for k, x := range sortLongestFirst(xs, y) {
+			ps[k] = complex(x0+dx*(float64(x)+ox), fy)
+		}
+		its := make([]iteration, len(ps))
+		iteratePoints(ps, lastPlot.depth, its)
+		for j, x := range xs {
+			p := 3 * (y*width + x)
+			col := plotColor(its[j], lastPlot.depth, dx)
+			sum[p+0] += float32(col.R)
+			sum[p+1] += float32(col.G)
+			sum[p+2] += float32(col.B)
+			for k := 0; k < 3; k++ {
+				data[p+k] = uint8(sum[p+k]/float32(n+1) + 0.5)
+			}
+			if supersamples != nil {
+				// The new sample has the weight of one of the n
+				// the pixel is the average of so far
+				q := y*width + x
+				ss := supersamples[q]
+				if ss == nil {
+					ss = []sample{{it: lastPlot.iters[q], weight: 1}}
+					kept[y]++
+				}
+				var w float32
+				for _, s := range ss {
+					w += s.weight
+				}
+				supersamples[q] = append(ss, sample{it: its[j], weight: w / float32(n)})
+				kept[y]++
+			}
+		}
+	})
+	if !ok {
+		return false
+	}
+	accumSum, accumData = sum, data
+	lastPlot.data = data
+	lastPlot.samples = n
+	setKeptSamples(supersamples, kept)
+	return true
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// Flags
+var (
+	gammaFlag      = flag.Float64("gamma", 1, "Gamma the colors are shown with, more than 1 to lighten the dark colors")
+	brightnessFlag = flag.Float64("brightness", 0, "Amount added to the brightness of the colors from -1 to 1")
+	contrastFlag   = flag.Float64("contrast", 1, "Contrast of the colors, more than 1 for more")
+	saturationFlag = flag.Float64("saturation", 1, "Saturation of the colors, 0 for gray, more than 1 for more vivid")
+)
+
+// adjustment is one of the ways the colors can be changed after
+// they have been worked out
+type adjustment struct {
+	name     string   // shown in messages and used by the control commands
+	value    *float64 // the setting
+	neutral  float64  // value which leaves the colors alone
+	min, max float64  // range of values allowed
+	step     float64  // change for each key press
+	flag     *float64 // the flag setting it to start with
+}
+
+// The adjustments
+var (
+	gamma      = 1.0
+	brightness = 0.0
+	contrast   = 1.0
+	saturation = 1.0
+)
+
+// The adjustments in the order they are picked from
+var adjustments = []adjustment{
+	{name: "gamma", value: &gamma, neutral: 1, min: 0.1, max: 10, step: 0.1, flag: gammaFlag},
+	{name: "brightness", value: &brightness, neutral: 0, min: -1, max: 1, step: 0.05, flag: brightnessFlag},
+	{name: "contrast", value: &contrast, neutral: 1, min: 0, max: 10, step: 0.1, flag: contrastFlag},
+	{name: "saturation", value: &saturation, neutral: 1, min: 0, max: 10, step: 0.1, flag: saturationFlag},
+}
+
+// The adjustment changed by the keys
+var adjustIndex = 0
+
+// Lookup table of the gamma, brightness and contrast for each channel
+// value, nil if they leave the colors alone
+var adjustTable *[256]uint8
+
+// checkAdjustFlags sets the adjustments from their flags
+func checkAdjustFlags() error {
+	for _, a := range adjustments {
+		if err := setAdjustment(a.name, *a.flag); err != nil {
+			return fmt.Errorf("--%w", err)
+		}
+	}
+	return nil
+}
+
+// setAdjustment sets the adjustment called name to value
+func setAdjustment(name string, value float64) error {
+	for _, a := range adjustments {
+		if a.name != name {
+			continue
+		}
+		if !(value >= a.min && value <= a.max) {
+			return fmt.Errorf("%s must be from %g to %g not %g", a.name, a.min, a.max, value)
+		}
+		if *a.value != value {
+			*a.value = value
+			makeAdjustTable()
+			coloringID++
+			recolor()
+		}
+		return nil
+	}
+	var names []string
+	for _, a := range adjustments {
+		names = append(names, a.name)
+	}
+	return fmt.Errorf("unknown adjustment %q - use one of %s", name, strings.Join(names, ", "))
+}
+
+// nextAdjustment picks the next adjustment for the keys to change
+func nextAdjustment() {
+	adjustIndex = (adjustIndex + 1) % len(adjustments)
+	a := adjustments[adjustIndex]
+	message = fmt.Sprintf(tr("Adjusting %s, now %.3g"), a.name, *a.value)
+}
+
+// changeAdjustment moves the adjustment picked by steps steps
+func changeAdjustment(steps float64) {
+	a := adjustments[adjustIndex]
+	value := math.Round((*a.value+steps*a.step)/a.step) * a.step
+	_ = setAdjustment(a.name, math.Min(math.Max(value, a.min), a.max))
+	message = fmt.Sprintf("%s %.3g", a.name, *a.value)
+}
+
+// adjusted returns true if any of the adjustments change the colors
+func adjusted() bool {
+	for _, a := range adjustments {
+		if *a.value != a.neutral {
+			return true
+		}
+	}
+	return false
+}
+
+// makeAdjustTable works out adjustTable from the adjustments
+//
+// Contrast stretches the channels out from the middle, then the
+// brightness is added and the gamma applied.
+func makeAdjustTable() {
+	if gamma == 1 && brightness == 0 && contrast == 1 {
+		adjustTable = nil
+		return
+	}
+	var table [256]uint8
+	for i := range table {
+		x := (float64(i)/255-0.5)*contrast + 0.5 + brightness
+		x = math.Pow(math.Min(math.Max(x, 0), 1), 1/gamma)
+		table[i] = uint8(255*x + 0.5)
+	}
+	adjustTable = &table
+}
+
+// adjustColor applies the adjustments to col
+//
+// The saturation is changed first, by moving the channels away from
+// or towards the luma of the color.
+func adjustColor(col color.RGBA) color.RGBA {
+	if saturation != 1 {
+		luma := 0.2126*float64(col.R) + 0.7152*float64(col.G) + 0.0722*float64(col.B)
+		channel := func(c uint8) uint8 {
+			return uint8(math.Min(math.Max(luma+(float64(c)-luma)*saturation, 0), 255) + 0.5)
+		}
+		col.R, col.G, col.B = channel(col.R), channel(col.G), channel(col.B)
+	}
+	if t := adjustTable; t != nil {
+		col.R, col.G, col.B = t[col.R], t[col.G], t[col.B]
+	}
+	return col
+}
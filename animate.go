@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// Constants
+const (
+	// Time constant for easing the fly-in speed up and down
+	flyEasing = 500 * time.Millisecond
+
+	// Frame time the adaptive resolution aims for while animating
+	frameTime = 40 * time.Millisecond
+
+	// Maximum reduction in resolution while animating
+	maxRenderScale = 8
+)
+
+// Globals
+var (
+	flyIn       = false   // set if fly-in zoom is engaged
+	flyVelocity float64   // current zoom speed in doublings per second
+	renderScale = 1       // reduce the resolution by this factor
+	lastTick    time.Time // time the last animation frame was started
+)
+
+// toggleFlyIn starts or stops the fly-in zoom
+//
+// The zoom speed eases towards the target so starting and stopping
+// is smooth.
+func toggleFlyIn() {
+	flyIn = !flyIn
+	if flyIn && !animating() {
+		lastTick = time.Now()
+	}
+}
+
+// animating returns true if frames should be produced without waiting
+// for input
+func animating() bool {
+	return flyIn || flyVelocity != 0 || panning() || exploring || morphing
+}
+
+// animate moves the view on by the time since the last frame
+func animate() {
+	now := time.Now()
+	dt := now.Sub(lastTick)
+	lastTick = now
+	animatePan(dt)
+	if exploring {
+		exploreStep(dt)
+	}
+	if morphing {
+		morphStep(dt)
+	}
+	if paletteCycling {
+		cycleStep()
+	}
+
+	// Ease the velocity towards the target
+	target := 0.0
+	if flyIn {
+		target = *flyRate
+	}
+	flyVelocity += (target - flyVelocity) * (1 - math.Exp(-float64(dt)/float64(flyEasing)))
+	if !flyIn && math.Abs(flyVelocity) < 0.01 {
+		flyVelocity = 0
+	}
+	radius *= math.Exp2(-flyVelocity * dt.Seconds())
+}
+
+// adaptResolution adjusts renderScale so animation frames take about
+// frameTime to plot, returning to full resolution when still
+func adaptResolution() {
+	if !animating() {
+		renderScale = 1
+		return
+	}
+	if plotDuration > frameTime*5/4 && renderScale < maxRenderScale {
+		renderScale++
+	} else if renderScale > 1 {
+		// Predict the plot time at the next resolution up as work
+		// goes with the number of pixels
+		s := float64(renderScale)
+		predicted := time.Duration(float64(plotDuration) * s * s / ((s - 1) * (s - 1)))
+		if predicted < frameTime {
+			renderScale--
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+)
+
+// Flags
+var (
+	autoDepthFlag = flag.Bool("auto-depth", false, "Set the iteration depth from the zoom, deeper the further in")
+)
+
+// Auto depth settings
+const (
+	// Depth of the whole fractal
+	autoDepthBase = 256
+
+	// Doublings of the zoom for each doubling of the depth
+	autoDepthZooms = 10
+)
+
+// Set if the depth is worked out from the zoom
+var autoDepth = false
+
+// autoDepthFor returns the depth for a view of radius r, doubling the
+// depth of the whole fractal every autoDepthZooms doublings of zoom,
+// as deeper zooms need more iterations before their detail shows
+func autoDepthFor(r float64) int {
+	_, home := params.fractal.DefaultView()
+	zooms := int(math.Log2(home / r))
+	return autoDepthBase << min(max(0, zooms/autoDepthZooms), 20)
+}
+
+// updateAutoDepth sets the depth from the zoom if auto depth is on
+//
+// It is called before each frame so the depth keeps up with the zoom.
+func updateAutoDepth() {
+	if autoDepth {
+		depth = autoDepthFor(radius)
+	}
+}
+
+// toggleAutoDepth turns auto depth on or off
+func toggleAutoDepth() {
+	autoDepth = !autoDepth
+	updateAutoDepth()
+	if autoDepth {
+		message = fmt.Sprintf(tr("Auto depth on - depth %d"), depth)
+	} else {
+		message = tr("Auto depth off")
+	}
+}
+
+// setDepth sets the depth by hand, which overrides auto depth
+func setDepth(d int) {
+	depth = d
+	if autoDepth {
+		autoDepth = false
+		message = fmt.Sprintf(tr("Auto depth off - depth %d"), depth)
+	}
+}
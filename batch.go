@@ -0,0 +1,209 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Flags
+var (
+	atFlag     = flag.String("at", "", "View to start at as re,im[,radius], eg -0.745,0.11,0.01")
+	depthFlag  = flag.Int("depth", 256, "Iteration depth to start with")
+	framesFlag = flag.Int("frames", 0, "Render this many frames zooming in from the whole set to the --at view, --render must have a %d in it")
+	renderFlag = flag.String("render", "", "Render the view to this PNG file without the terminal and exit")
+	sizeFlag   = flag.String("size", "1920x1080", "Size in pixels of the image made by --render")
+)
+
+// startView sets the view from --at, --depth and --auto-depth
+func startView() error {
+	if *depthFlag < 1 {
+		return fmt.Errorf("--depth must be at least 1 not %d", *depthFlag)
+	}
+	depth = *depthFlag
+	autoDepth = *autoDepthFlag
+	if *atFlag == "" {
+		return nil
+	}
+	parts := strings.Split(*atFlag, ",")
+	fs, err := parseFloats(parts)
+	if err != nil {
+		return fmt.Errorf("--at: %w", err)
+	}
+	if len(fs) < 2 || len(fs) > 3 {
+		return fmt.Errorf("--at needs re,im[,radius] not %q", *atFlag)
+	}
+	// Keep all the digits of the center for deep zooms
+	p, err := parseDeepPoint(parts[0], parts[1])
+	if err != nil {
+		return fmt.Errorf("--at: %w", err)
+	}
+	setDeepCenter(p)
+	if len(fs) == 3 {
+		if !(fs[2] > 0) {
+			return fmt.Errorf("--at radius must be more than 0")
+		}
+		radius = fs[2]
+	}
+	return nil
+}
+
+// parseImageSize parses a size like 1920x1080
+func parseImageSize(s string) (width, height int, err error) {
+	w, h, ok := strings.Cut(strings.ToLower(s), "x")
+	if ok {
+		width, err = strconv.Atoi(w)
+	}
+	if ok && err == nil {
+		height, err = strconv.Atoi(h)
+	}
+	if !ok || err != nil || width < 1 || height < 1 {
+		return 0, 0, fmt.Errorf("bad size %q - use WIDTHxHEIGHT, eg 1920x1080", s)
+	}
+	return width, height, nil
+}
+
+// renderFile renders the view into a width x height PNG at path with
+// square pixels, refined and antialiased as far as termbrot goes
+// when idle
+func renderFile(path string, width, height int) error {
+	never := func() bool { return false }
+	updateAutoDepth()
+	dx, _ := getSetSizeAt(radius, width, height)
+	rebase(dx)
+	x0, y0, dx, dy := viewGrid(center, radius, width, height)
+	lastPlot = *renderPlot(x0, y0, dx, dy, width, height, depth, never)
+	for !lastPlot.refined || lastPlot.aliased || lastPlot.samples < maxSamples {
+		refineStep(never)
+	}
+	return savePNG(path, rgbImage(lastPlot.data, lastPlot.width, lastPlot.height))
+}
+
+// frameMemory returns the most memory rendering a width x height
+// frame uses, allowing for the copies made while refining it and the
+// accumulation buffer
+func frameMemory(width, height int) int64 {
+	return 2 * (plotSize(width, height) + 12*int64(width*height))
+}
+
+// frameJobs returns how many frames to render at once
+//
+// Refining a frame has long stretches where there are only a few
+// rows left to do, so rendering several at once keeps all the CPUs
+// busy as long as they fit in --max-memory.
+func frameJobs(frames, width, height int) int {
+	return max(1, min(frames, runtime.NumCPU(), int(maxMemory/frameMemory(width, height))))
+}
+
+// frameArgs returns the arguments which run termbrot to render frame
+// i of a zoom into re, im at radius r as one of jobs at once
+//
+// All the flags set for this run are passed on, so the frames come
+// out as this run would render them, with the view, the worker count
+// and memory limit replaced.
+func frameArgs(i int, re, im string, r float64, jobs int) []string {
+	workers := *workersFlag
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	set := []string{
+		"--render=" + fmt.Sprintf(*renderFlag, i),
+		fmt.Sprintf("--at=%s,%s,%.17g", re, im, r),
+		"--workers=" + strconv.Itoa(max(1, workers/jobs)),
+		"--max-memory=" + strconv.FormatInt(maxMemory/int64(jobs), 10),
+	}
+	var args []string
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "render", "at", "workers", "max-memory", "frames":
+		default:
+			args = append(args, "--"+f.Name+"="+f.Value.String())
+		}
+	})
+	return append(args, set...)
+}
+
+// renderFrames renders the frames of a zoom from the whole fractal into
+// the view, spaced evenly in zoom, each into the file named by
+// --render with the frame number
+//
+// As the renderer keeps its state in globals each frame is rendered
+// by running termbrot again, several at once, sharing the CPUs and
+// the memory between them.
+func renderFrames(frames, width, height int) error {
+	if !strings.Contains(*renderFlag, "%") {
+		return fmt.Errorf("--render needs a %%d for the frame number with --frames, eg zoom%%04d.png")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	_, startRadius := params.fractal.DefaultView()
+	// The frames choose their own kernels, but need all the digits
+	// of the center if the last is deep
+	dx, _ := getSetSizeAt(radius, width, height)
+	rebase(dx)
+	re, im := origin.text(center)
+	jobs := frameJobs(frames, width, height)
+	next := make(chan int, frames)
+	for i := 0; i < frames; i++ {
+		next <- i
+	}
+	close(next)
+	var (
+		mu       sync.Mutex
+		done     int
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	for j := 0; j < jobs; j++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				r := startRadius
+				if frames > 1 {
+					r *= math.Pow(radius/startRadius, float64(i)/float64(frames-1))
+				}
+				out, err := exec.Command(exe, frameArgs(i, re, im, r, jobs)...).CombinedOutput()
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = fmt.Errorf("frame %d: %v: %s", i, err, strings.TrimSpace(string(out)))
+				}
+				done++
+				fmt.Printf("\rRendered %d/%d frames", done, frames)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	fmt.Println()
+	return firstErr
+}
+
+// batchRender does the render asked for by --render, returning a
+// message saying how it went
+func batchRender() (string, error) {
+	width, height, err := parseImageSize(*sizeFlag)
+	if err != nil {
+		return "", fmt.Errorf("--size: %w", err)
+	}
+	t0 := time.Now()
+	if *framesFlag > 0 {
+		if err := renderFrames(*framesFlag, width, height); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Rendered %d frames (%d x %d) in %s", *framesFlag, width, height, truncatedDuration(time.Since(t0))), nil
+	}
+	if err := renderFile(*renderFlag, width, height); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Rendered %s (%d x %d) in %s", *renderFlag, width, height, truncatedDuration(time.Since(t0))), nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Benchmark settings
+const (
+	// Time each kernel is run on each view for
+	benchmarkTime = time.Second
+
+	// Points across and down the grid of each view
+	benchmarkGrid = 64
+)
+
+// A view the kernels are timed on
+type benchmarkView struct {
+	name   string
+	center complex128
+	radius float64
+	depth  int
+}
+
+// The views the kernels are timed on - the whole set, where most
+// points escape quickly or are found to be inside, and a zoom into
+// the boundary where the orbits are long
+var benchmarkViews = []benchmarkView{
+	{"whole set", -0.5, 2, 1000},
+	{"seahorse valley", complex(-0.745, 0.11), 0.01, 5000},
+}
+
+// A kernel timed, iterating a row of the grid
+type benchmarkKernel struct {
+	name    string
+	iterate func(cs []complex128, maxDepth int)
+}
+
+// The kernels timed
+var benchmarkKernels = []benchmarkKernel{
+	{"float64", eachPoint(func(c complex128, maxDepth int) { mandelbrot(0, c, 0, maxDepth) })},
+	{"float32", eachPoint(func(c complex128, maxDepth int) { mandelbrot32(0, complex64(c), 0, maxDepth) })},
+	{"fixed", eachPoint(func(c complex128, maxDepth int) { mandelbrotFixed(0, c, 0, maxDepth) })},
+	{"double", eachPoint(func(c complex128, maxDepth int) { ddIterate(c, maxDepth) })},
+}
+
+func init() {
+	if hasSIMD {
+		benchmarkKernels = append(benchmarkKernels, benchmarkKernel{"simd", func(cs []complex128, maxDepth int) {
+			mandelbrots(make([]complex128, len(cs)), cs, maxDepth, make([]int, len(cs)))
+		}})
+	}
+}
+
+// eachPoint makes a kernel iterating a row from one iterating a point
+func eachPoint(iterate func(c complex128, maxDepth int)) func(cs []complex128, maxDepth int) {
+	return func(cs []complex128, maxDepth int) {
+		for _, c := range cs {
+			iterate(c, maxDepth)
+		}
+	}
+}
+
+// runBenchmark times the iteration kernels, printing how many points
+// each iterates a second on each of benchmarkViews, for checking that
+// changes to them make them faster
+//
+// The kernels are called directly, so this times the inner loops
+// without the cardioid and bulb test round them. Points are counted
+// rather than iterations as points found to be inside the set stop
+// early.
+func runBenchmark() {
+	fmt.Printf("%-10s %-16s %10s %10s\n", "Kernel", "View", "Points/s", "µs/point")
+	row := make([]complex128, benchmarkGrid)
+	for _, k := range benchmarkKernels {
+		for _, v := range benchmarkViews {
+			points := 0
+			t0 := time.Now()
+			for time.Since(t0) < benchmarkTime {
+				for y := 0; y < benchmarkGrid; y++ {
+					for x := range row {
+						row[x] = v.center + complex(
+							v.radius*(2*float64(x)/benchmarkGrid-1),
+							v.radius*(2*float64(y)/benchmarkGrid-1))
+					}
+					k.iterate(row, v.depth)
+				}
+				points += benchmarkGrid * benchmarkGrid
+			}
+			dt := time.Since(t0)
+			fmt.Printf("%-10s %-16s %10.4g %10.3g\n", k.name, v.name,
+				float64(points)/dt.Seconds(), float64(dt.Microseconds())/float64(points))
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"unicode/utf8"
+)
+
+// The colors of the top and bottom halves of the cells of the screen
+// as last drawn with blocks, row by row, so the overlays can be
+// blended over them
+var blockCells [][][2]color.RGBA
+
+// shownCell returns the colors of the cell at row, col as last drawn,
+// or black if it hasn't been
+func shownCell(row, col int) [2]color.RGBA {
+	if row < len(blockCells) && col < len(blockCells[row]) {
+		return blockCells[row][col]
+	}
+	black := color.RGBA{0, 0, 0, 255}
+	return [2]color.RGBA{black, black}
+}
+
+// writeBlockLine draws raw RGB data covering cols cells of line row
+// of the screen from column col with upper half blocks, each the color
+// of a pixel above the color of the pixel below
+//
+// The data is sampled at the middles of the halves of the cells, so it
+// can be any size.
+func writeBlockLine(row, col int, data []byte, width, height, cols int) {
+	for len(blockCells) <= row {
+		blockCells = append(blockCells, nil)
+	}
+	if n := col + cols; len(blockCells[row]) < n {
+		blockCells[row] = append(blockCells[row], make([][2]color.RGBA, n-len(blockCells[row]))...)
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "\033[%d;%dH", row+1, col+1)
+	var last [2]color.RGBA
+	for x := 0; x < cols; x++ {
+		sx := x * width / cols
+		var cell [2]color.RGBA
+		for half := range cell {
+			p := 3 * ((2*half+1)*height/4*width + sx)
+			cell[half] = color.RGBA{data[p], data[p+1], data[p+2], 255}
+		}
+		blockCells[row][col+x] = cell
+		writeBlock(&b, cell, &last, x == 0)
+	}
+	b.WriteString("\033[0m")
+	_, _ = screen.Write(b.Bytes())
+}
+
+// writeBlock writes an upper half block in the colors of cell,
+// setting only the colors which aren't the same as the last block's
+// unless it is the first
+func writeBlock(b *bytes.Buffer, cell [2]color.RGBA, last *[2]color.RGBA, first bool) {
+	if first || cell[0] != last[0] {
+		fmt.Fprintf(b, "\033[38;2;%d;%d;%dm", cell[0].R, cell[0].G, cell[0].B)
+	}
+	if first || cell[1] != last[1] {
+		fmt.Fprintf(b, "\033[48;2;%d;%d;%dm", cell[1].R, cell[1].G, cell[1].B)
+	}
+	b.WriteString("▀")
+	*last = cell
+}
+
+// blend returns the color src, with premultiplied alpha, over dst
+func blend(src, dst color.RGBA) color.RGBA {
+	a := 255 - int(src.A)
+	return color.RGBA{
+		uint8(int(src.R) + int(dst.R)*a/255),
+		uint8(int(src.G) + int(dst.G)*a/255),
+		uint8(int(src.B) + int(dst.B)*a/255),
+		255,
+	}
+}
+
+// writeBlockImage draws an image.RGBA over the cells from the top left
+// of the screen with upper half blocks, blended over what the cells
+// show, leaving the cells it doesn't cover at all alone
+func writeBlockImage(img *image.RGBA) {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	at := func(x, y int) color.RGBA {
+		if y >= height {
+			return color.RGBA{}
+		}
+		p := y*img.Stride + 4*x
+		return color.RGBA{img.Pix[p], img.Pix[p+1], img.Pix[p+2], img.Pix[p+3]}
+	}
+	var b bytes.Buffer
+	for row := 0; 2*row < height; row++ {
+		var last [2]color.RGBA
+		moved := false
+		for x := 0; x < width; x++ {
+			top, bottom := at(x, 2*row), at(x, 2*row+1)
+			if top.A == 0 && bottom.A == 0 {
+				moved = false
+				continue
+			}
+			if !moved {
+				fmt.Fprintf(&b, "\033[%d;%dH", row+1, x+1)
+			}
+			cell := shownCell(row, x)
+			cell[0], cell[1] = blend(top, cell[0]), blend(bottom, cell[1])
+			writeBlock(&b, cell, &last, !moved)
+			moved = true
+		}
+	}
+	b.WriteString("\033[0m")
+	_, _ = screen.Write(b.Bytes())
+}
+
+// writeBlockText writes the lines of an overlay as text over the cells
+// from the top left of the screen, returning how many lines of the
+// screen it covers
+//
+// With the images drawn in cells the text can't be drawn into them in
+// a font, so it is written in the colors of the theme, over its panel
+// blended onto the cells or over the cells themselves if it has none.
+func writeBlockText(lines []overlayLine) int {
+	t := theme()
+	_, cols, _, _, _ := getTerminalSize()
+	width := 0
+	for _, line := range lines {
+		width = max(width, utf8.RuneCountInString(line.text)+2)
+	}
+	width = min(width, cols-1)
+	var b bytes.Buffer
+	for row, line := range lines {
+		if line.text == "" && t.panel.A == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\033[%d;1H", row+1)
+		text := []rune(" " + line.text)
+		end := width
+		if t.panel.A == 0 {
+			end = min(width, len(text))
+		}
+		for x := 0; x < end; x++ {
+			cell := shownCell(row, x)
+			bg := blend(t.panel, color.RGBA{
+				uint8((int(cell[0].R) + int(cell[1].R)) / 2),
+				uint8((int(cell[0].G) + int(cell[1].G)) / 2),
+				uint8((int(cell[0].B) + int(cell[1].B)) / 2),
+				255,
+			})
+			r := ' '
+			if x < len(text) {
+				r = text[x]
+			}
+			// The theme's text colors aren't premultiplied
+			a := int(line.col.A)
+			fg := color.RGBA{
+				uint8((int(line.col.R)*a + int(bg.R)*(255-a)) / 255),
+				uint8((int(line.col.G)*a + int(bg.G)*(255-a)) / 255),
+				uint8((int(line.col.B)*a + int(bg.B)*(255-a)) / 255),
+				255,
+			}
+			fmt.Fprintf(&b, "\033[38;2;%d;%d;%dm\033[48;2;%d;%d;%dm%c", fg.R, fg.G, fg.B, bg.R, bg.G, bg.B, r)
+		}
+	}
+	b.WriteString("\033[0m")
+	_, _ = screen.Write(b.Bytes())
+	return len(lines)
+}
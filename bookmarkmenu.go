@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Show at most this many bookmarks in the menu at once
+const menuRows = 6
+
+// menuItem is a bookmark or recent session in the menu
+type menuItem struct {
+	bookmark
+	session bool // set if it is a recent session rather than a bookmark
+}
+
+// Globals
+var (
+	menuOpen     bool                       // set while the bookmark menu is shown
+	menuSessions bool                       // set if the menu has the recent sessions too
+	menuItems    []menuItem                 // the items in the menu, newest first
+	menuSel      int                        // index of the selected item
+	menuThumbs   = map[string]*image.RGBA{} // thumbnails loaded so far by file name
+)
+
+// openMenu shows the bookmark menu, with the recent sessions first if
+// sessions is set
+func openMenu(sessions bool) {
+	var items []menuItem
+	add := func(bookmarks []bookmark, session bool) {
+		for i := len(bookmarks) - 1; i >= 0; i-- {
+			items = append(items, menuItem{bookmark: bookmarks[i], session: session})
+		}
+	}
+	if sessions {
+		recent, err := loadSessions()
+		if err != nil {
+			message = fmt.Sprintf(tr("Reading the recent sessions failed: %v"), err)
+			return
+		}
+		add(recent, true)
+	}
+	bookmarks, err := loadBookmarks()
+	if err != nil {
+		message = fmt.Sprintf(tr("Bookmark failed: %v"), err)
+		return
+	}
+	add(bookmarks, false)
+	if len(items) == 0 {
+		message = tr("No bookmarks yet - press b to add one")
+		return
+	}
+	menuItems = items
+	menuSessions = sessions
+	menuSel = 0
+	menuOpen = true
+}
+
+// deleteMenuItem removes the selected item from its file along with
+// its thumbnail
+func deleteMenuItem() {
+	item := menuItems[menuSel]
+	menuItems = append(menuItems[:menuSel], menuItems[menuSel+1:]...)
+	var bookmarks []bookmark
+	for i := len(menuItems) - 1; i >= 0; i-- {
+		if menuItems[i].session == item.session {
+			bookmarks = append(bookmarks, menuItems[i].bookmark)
+		}
+	}
+	path, err := bookmarksPath()
+	if item.session {
+		path, err = sessionsPath()
+	}
+	if err == nil {
+		err = writeBookmarks(path, bookmarks)
+	}
+	if err != nil {
+		message = fmt.Sprintf(tr("Bookmark failed: %v"), err)
+	}
+	removeThumbnail(&item.bookmark)
+	menuSel = min(menuSel, len(menuItems)-1)
+	if len(menuItems) == 0 {
+		menuOpen = false
+	}
+}
+
+// menuKey handles a key press while the bookmark menu is open
+func menuKey(ev termbox.Event) {
+	switch ev.Key + termbox.Key(ev.Ch) {
+	case termbox.KeyEsc, termbox.KeyCtrlC, 'q', 'B':
+		menuOpen = false
+	case termbox.KeyArrowUp, 'k':
+		menuSel = max(0, menuSel-1)
+	case termbox.KeyArrowDown, 'j':
+		menuSel = min(len(menuItems)-1, menuSel+1)
+	case termbox.KeyEnter:
+		menuItems[menuSel].restore()
+		menuOpen = false
+	case termbox.KeyDelete, 'x':
+		deleteMenuItem()
+	}
+}
+
+// thumbnail returns the thumbnail of b, loading it if necessary
+func thumbnail(b *bookmark) *image.RGBA {
+	if b.Thumbnail == "" {
+		return nil
+	}
+	img, ok := menuThumbs[b.Thumbnail]
+	if !ok {
+		img = loadThumbnail(b)
+		menuThumbs[b.Thumbnail] = img
+	}
+	return img
+}
+
+// menuOverlay returns an image of the bookmark menu
+func menuOverlay() *image.RGBA {
+	h := int(1.1*fontSize + 0.5)
+	sp := int(fontSize / 2)
+	rowHeight := max(thumbHeight, 3*h) + sp
+	first, last := menuShown()
+	title := menuTitle()
+	width := measureText(title)
+	for i := first; i < last; i++ {
+		for _, line := range menuItems[i].lines() {
+			width = max(width, thumbWidth+sp+measureText(line))
+		}
+	}
+	width += 2 * sp
+	height := 2*h + (last-first)*rowHeight
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	t := theme()
+	fillRect(img, img.Bounds(), color.RGBA{0, 0, 0, 192})
+	t.drawText(img, sp, h, title, t.title)
+	for i := first; i < last; i++ {
+		item := &menuItems[i]
+		y := 2*h + (i-first)*rowHeight - sp/2
+		col := t.help
+		if i == menuSel {
+			col = t.prompt
+			fillRect(img, image.Rect(0, y-sp/2, width, y+rowHeight-sp/2), color.RGBA{64, 64, 128, 255})
+		}
+		if thumb := thumbnail(&item.bookmark); thumb != nil {
+			copyImage(img, sp, y, thumb)
+		}
+		for j, line := range item.lines() {
+			t.drawText(img, 2*sp+thumbWidth, y+h*(j+1), line, col)
+		}
+	}
+	return img
+}
+
+// menuShown returns the first item shown in the menu and the one
+// after the last, keeping the selected one in the middle
+func menuShown() (first, last int) {
+	first = max(0, min(menuSel-menuRows/2, len(menuItems)-menuRows))
+	return first, min(len(menuItems), first+menuRows)
+}
+
+// menuTitle returns the title of the menu
+func menuTitle() string {
+	if menuSessions {
+		return tr("Start from - ↑↓ to choose, enter to go, x to delete, esc for the whole set")
+	}
+	return tr("Bookmarks - ↑↓ to choose, enter to go, x to delete, esc to close")
+}
+
+// lines returns the lines of text describing the item in the menu
+func (item *menuItem) lines() []string {
+	b := &item.bookmark
+	s := []string{
+		b.Time.Local().Format("2006-01-02 15:04"),
+		fmt.Sprintf("%.10g", complex(b.Re, b.Im)),
+		fmt.Sprintf(tr("radius %g, depth %d"), b.Radius, b.Depth),
+	}
+	if item.session {
+		s[0] += tr(" - recent session")
+	}
+	if b.Julia {
+		s[0] += fmt.Sprintf(tr(" - Julia set of %g"), complex(b.JuliaRe, b.JuliaIm))
+	}
+	return s
+}
+
+// menuLines returns the lines of text of the menu for drawing without
+// the thumbnails
+func menuLines() []overlayLine {
+	t := theme()
+	lines := []overlayLine{{menuTitle(), t.title}, {}}
+	first, last := menuShown()
+	for i := first; i < last; i++ {
+		col := t.help
+		if i == menuSel {
+			col = t.prompt
+		}
+		for _, line := range menuItems[i].lines() {
+			lines = append(lines, overlayLine{line, col})
+		}
+		lines = append(lines, overlayLine{})
+	}
+	return lines
+}
+
+// fillRect fills r of img with col
+func fillRect(img *image.RGBA, r image.Rectangle, col color.RGBA) {
+	r = r.Intersect(img.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetRGBA(x, y, col)
+		}
+	}
+}
+
+// copyImage copies src into img with its top left corner at x, y
+func copyImage(img *image.RGBA, x, y int, src *image.RGBA) {
+	b := src.Bounds()
+	for sy := b.Min.Y; sy < b.Max.Y; sy++ {
+		for sx := b.Min.X; sx < b.Max.X; sx++ {
+			img.SetRGBA(x+sx-b.Min.X, y+sy-b.Min.Y, src.RGBAAt(sx, sy))
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// bookmark is a saved location in the set
+type bookmark struct {
+	Time    time.Time `json:"time"`
+	Re      float64   `json:"re"` // center of the view
+	Im      float64   `json:"im"`
+	Radius  float64   `json:"radius"`
+	Depth   int       `json:"depth"`
+	Julia   bool      `json:"julia,omitempty"` // set if it is of the Julia set of JuliaRe, JuliaIm
+	JuliaRe float64   `json:"julia_re,omitempty"`
+	JuliaIm float64   `json:"julia_im,omitempty"`
+	Fractal string    `json:"fractal,omitempty"` // name of the fractal, the Mandelbrot set if not set
+	Param   float64   `json:"param,omitempty"`   // parameter of the fractal, eg the exponent of the Multibrot set
+	Formula string    `json:"formula,omitempty"` // formula of the formula fractal
+	Hybrid  string    `json:"hybrid,omitempty"`  // pattern of the hybrid fractal
+	Deep    []string  `json:"deep,omitempty"`    // real and imaginary parts of the center to full precision for deep zooms
+
+	// The coloring the view was bookmarked with
+	Palette   []string `json:"palette,omitempty"` // the gradient stops as for gradientHex
+	Decompose bool     `json:"decompose,omitempty"`
+	Coloring  string   `json:"coloring,omitempty"` // the coloring mode, smooth if not set
+	Layers    string   `json:"layers,omitempty"`   // the layers drawn instead of the coloring mode if set, as for --layers
+
+	Thumbnail string `json:"thumbnail,omitempty"` // file name of the thumbnail in the thumbnails directory
+}
+
+// Size of the bookmark thumbnails in pixels
+const (
+	thumbWidth  = 128
+	thumbHeight = 80
+)
+
+// newBookmark makes a bookmark of the view v
+func newBookmark(v view) bookmark {
+	return bookmark{
+		Time:    time.Now(),
+		Re:      real(v.absCenter()),
+		Im:      imag(v.absCenter()),
+		Radius:  v.radius,
+		Depth:   v.depth,
+		Julia:   v.params.julia,
+		JuliaRe: real(v.params.c),
+		JuliaIm: imag(v.params.c),
+		Fractal: v.params.fractal.Name(),
+		Param:   v.params.param,
+		Formula: formulaText(v.params),
+		Hybrid:  v.params.hybrid,
+		Deep:    deepText(v),
+
+		Palette:   gradientHex(),
+		Decompose: decompose,
+		Coloring:  coloringNames[coloring],
+		Layers:    layersText(),
+	}
+}
+
+// restore jumps to the bookmark, putting back the coloring it was
+// saved with
+func (b *bookmark) restore() {
+	setView(b.view())
+	decompose = b.Decompose
+	recolor()
+	mode := b.Coloring
+	if mode == "" {
+		mode = coloringNames[smoothColoring]
+	}
+	_ = setColoring(mode)
+	if b.Layers != "" {
+		_ = setLayers(b.Layers)
+	}
+	if stops, err := parseGradient(b.Palette); err == nil {
+		setGradient(stops)
+	}
+}
+
+// view returns the view the bookmark is of
+func (b *bookmark) view() view {
+	fractal, err := fractalByName(b.Fractal)
+	if err != nil {
+		fractal = mandelbrotFractal{}
+	}
+	var f *formula
+	switch fractal.(type) {
+	case formulaFractal:
+		f, err = parseFormula(b.Formula)
+	case hybridFractal:
+		_, err = checkHybridPattern(b.Hybrid)
+	}
+	if err != nil {
+		fractal = mandelbrotFractal{}
+	}
+	v := view{
+		center: complex(b.Re, b.Im),
+		radius: b.Radius,
+		depth:  b.Depth,
+		params: fractalParams{fractal: fractal, param: b.Param, formula: f, hybrid: b.Hybrid, julia: b.Julia, c: complex(b.JuliaRe, b.JuliaIm)},
+	}
+	if len(b.Deep) == 2 {
+		if p, err := parseDeepPoint(b.Deep[0], b.Deep[1]); err == nil {
+			v.center, v.origin = 0, p
+		}
+	}
+	return v
+}
+
+// deepText returns the real and imaginary parts of the center of v
+// to full precision if it is a deep zoom, or nil if not
+func deepText(v view) []string {
+	if v.origin == nil {
+		return nil
+	}
+	re, im := v.origin.text(v.center)
+	return []string{re, im}
+}
+
+// bookmarksPath returns the path of the bookmarks file
+func bookmarksPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "bookmarks.json"), nil
+}
+
+// loadBookmarks reads the saved bookmarks, oldest first
+func loadBookmarks() ([]bookmark, error) {
+	path, err := bookmarksPath()
+	if err != nil {
+		return nil, err
+	}
+	return readBookmarks(path)
+}
+
+// saveBookmarks writes the bookmarks file
+func saveBookmarks(bookmarks []bookmark) error {
+	path, err := bookmarksPath()
+	if err != nil {
+		return err
+	}
+	return writeBookmarks(path, bookmarks)
+}
+
+// readBookmarks reads a file of bookmarks, returning none if it
+// doesn't exist
+func readBookmarks(path string) ([]bookmark, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var bookmarks []bookmark
+	if err := json.Unmarshal(data, &bookmarks); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return bookmarks, nil
+}
+
+// writeBookmarks writes a file of bookmarks
+func writeBookmarks(path string, bookmarks []bookmark) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(bookmarks, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// thumbnailsDir returns the directory the thumbnails are kept in
+func thumbnailsDir() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "thumbnails"), nil
+}
+
+// renderThumbnail plots a small image of the view v with square pixels
+func renderThumbnail(v view) *image.RGBA {
+	dx := 2 * v.radius / thumbHeight
+	c := v.centerFrom(origin)
+	x0 := real(c) - dx*thumbWidth/2
+	y0 := imag(c) - dx*thumbHeight/2
+	p := renderPlot(x0, y0, dx, dx, thumbWidth, thumbHeight, v.depth, func() bool { return false })
+	return rgbImage(p.data, p.width, p.height)
+}
+
+// saveThumbnail renders and saves a thumbnail for b
+func saveThumbnail(b *bookmark) error {
+	dir, err := thumbnailsDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d.png", b.Time.UnixNano())
+	if err := savePNG(filepath.Join(dir, name), renderThumbnail(b.view())); err != nil {
+		return err
+	}
+	b.Thumbnail = name
+	return nil
+}
+
+// removeThumbnail deletes the thumbnail of b if it has one
+func removeThumbnail(b *bookmark) {
+	dir, err := thumbnailsDir()
+	if b.Thumbnail == "" || err != nil {
+		return
+	}
+	_ = os.Remove(filepath.Join(dir, b.Thumbnail))
+}
+
+// loadThumbnail loads the thumbnail of b, or returns nil if it hasn't got one
+func loadThumbnail(b *bookmark) *image.RGBA {
+	dir, err := thumbnailsDir()
+	if b.Thumbnail == "" || err != nil {
+		return nil
+	}
+	f, err := os.Open(filepath.Join(dir, b.Thumbnail))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil
+	}
+	rgba := image.NewRGBA(img.Bounds())
+	for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+		for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+			rgba.Set(x, y, img.At(x, y))
+		}
+	}
+	return rgba
+}
+
+// addBookmark saves a bookmark of the view v with a thumbnail,
+// showing the result as the message
+func addBookmark(v view) {
+	var thumbErr error
+	bookmarks, err := loadBookmarks()
+	if err == nil {
+		b := newBookmark(v)
+		thumbErr = saveThumbnail(&b)
+		bookmarks = append(bookmarks, b)
+		err = saveBookmarks(bookmarks)
+	}
+	switch {
+	case err != nil:
+		message = fmt.Sprintf(tr("Bookmark failed: %v"), err)
+	case thumbErr != nil:
+		message = fmt.Sprintf(tr("Bookmarked without a thumbnail: %v"), thumbErr)
+	default:
+		message = fmt.Sprintf(tr("Bookmarked %g"), v.absCenter())
+	}
+}
+
+// bookmarkAt bookmarks the current view moved to be centered on c
+func bookmarkAt(c complex128) {
+	v := currentView()
+	v.center = c
+	addBookmark(v)
+}
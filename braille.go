@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/cmplx"
+	"strings"
+	"unicode/utf8"
+)
+
+// Flags
+var (
+	brailleFlag = flag.String("braille", "set", "What the dots show with --graphics braille - set for the points in the set or edge for those within a pixel of it too by distance estimate")
+)
+
+// brailleMode is what the dots of braille show
+type brailleMode int
+
+// The braille modes
+const (
+	setBraille  brailleMode = iota // the points in the set
+	edgeBraille                    // the points in the set or near it
+)
+
+// Names of the braille modes indexed by brailleMode
+var brailleNames = []string{"set", "edge"}
+
+// The braille mode in use
+var braille = setBraille
+
+// Distance from the set in pixels of the points given dots with edge
+// braille, so the filaments too thin to hit a dot show
+const brailleWidth = 1.0
+
+// The bits of the braille pattern for each dot of a cell, by row and
+// column, as the dots are numbered down the left then the right with
+// the bottom row added last
+var brailleBits = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+// The dots of the cells of the screen as last drawn with braille, row
+// by row, so the overlays can be drawn over them
+var brailleCells [][]rune
+
+// checkBrailleFlag sets the braille mode from --braille
+func checkBrailleFlag() error {
+	for i, n := range brailleNames {
+		if n == *brailleFlag {
+			braille = brailleMode(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("--braille must be one of %s not %q", strings.Join(brailleNames, ", "), *brailleFlag)
+}
+
+// brailleDots returns true if the plots are colored for braille, which
+// they are when drawn in it but not when rendered to a file
+func brailleDots() bool {
+	return graphics == brailleGraphics && *renderFlag == ""
+}
+
+// brailleEdges returns true if the dots of braille are worked out from
+// the distance estimate
+func brailleEdges() bool {
+	return brailleDots() && braille == edgeBraille
+}
+
+// brailleColor returns white for an iteration result which gets a dot
+// in braille and black for one which doesn't
+//
+// Without the derivative tracked for the fractal only the points in
+// the set get one.
+func brailleColor(it iteration, plotDepth int, pixel float64) color.RGBA {
+	dot := it.i >= plotDepth
+	if !dot && brailleEdges() && needsDerivative() {
+		r := cmplx.Abs(it.z)
+		dot = r*math.Log(r)/cmplx.Abs(it.dz) < brailleWidth*pixel
+	}
+	if dot {
+		return color.RGBA{255, 255, 255, 255}
+	}
+	return color.RGBA{0, 0, 0, 255}
+}
+
+// isDot returns true if the color is bright enough to be a dot
+func isDot(r, g, b uint8) bool {
+	return 0.2126*float64(r)+0.7152*float64(g)+0.0722*float64(b) >= 128
+}
+
+// brailleCell returns the cell at row, col of the screen for drawing
+// into, making room for it if need be
+func brailleCell(row, col int) *rune {
+	for len(brailleCells) <= row {
+		brailleCells = append(brailleCells, nil)
+	}
+	if n := col + 1; len(brailleCells[row]) < n {
+		brailleCells[row] = append(brailleCells[row], make([]rune, n-len(brailleCells[row]))...)
+	}
+	return &brailleCells[row][col]
+}
+
+// writeBrailleLine draws raw RGB data covering cols cells of line row
+// of the screen from column col in braille, each cell 2 x 4 dots which
+// are set where the pixels are bright
+//
+// The data is sampled at the dots, so it can be any size. Only the
+// characters are sent, so it needs no colors from the terminal.
+func writeBrailleLine(row, col int, data []byte, width, height, cols int) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "\033[%d;%dH", row+1, col+1)
+	for x := 0; x < cols; x++ {
+		var dots rune
+		for dy, bits := range brailleBits {
+			sy := (2*dy + 1) * height / 8
+			for dx, bit := range bits {
+				sx := (4*x + 2*dx + 1) * width / (4 * cols)
+				p := 3 * (sy*width + sx)
+				if isDot(data[p], data[p+1], data[p+2]) {
+					dots |= bit
+				}
+			}
+		}
+		*brailleCell(row, col+x) = dots
+		b.WriteRune(0x2800 + dots)
+	}
+	_, _ = screen.Write(b.Bytes())
+}
+
+// writeBrailleImage draws an image.RGBA over the cells from the top
+// left of the screen in braille, adding dots where its pixels are more
+// opaque than not to the dots the cells show, leaving the cells it
+// doesn't cover at all alone
+func writeBrailleImage(img *image.RGBA) {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	var b bytes.Buffer
+	for row := 0; 4*row < height; row++ {
+		moved := false
+		for x := 0; 2*x < width; x++ {
+			var dots rune
+			for dy, bits := range brailleBits {
+				for dx, bit := range bits {
+					py, px := 4*row+dy, 2*x+dx
+					if py < height && px < width && img.Pix[py*img.Stride+4*px+3] >= 0x80 {
+						dots |= bit
+					}
+				}
+			}
+			if dots == 0 {
+				moved = false
+				continue
+			}
+			if !moved {
+				fmt.Fprintf(&b, "\033[%d;%dH", row+1, x+1)
+				moved = true
+			}
+			cell := brailleCell(row, x)
+			*cell |= dots
+			b.WriteRune(0x2800 + *cell)
+		}
+	}
+	_, _ = screen.Write(b.Bytes())
+}
+
+// writeBrailleText writes the lines of an overlay as plain text over
+// the cells from the top left of the screen, returning how many lines
+// of the screen it covers
+//
+// The text is padded with blanks to cover the dots under it if the
+// theme has a panel.
+func writeBrailleText(lines []overlayLine) int {
+	t := theme()
+	_, cols, _, _, _ := getTerminalSize()
+	width := 0
+	for _, line := range lines {
+		width = max(width, utf8.RuneCountInString(line.text)+2)
+	}
+	width = min(width, cols-1)
+	var b bytes.Buffer
+	for row, line := range lines {
+		if line.text == "" && t.panel.A == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\033[%d;1H", row+1)
+		text := []rune(" " + line.text)
+		text = text[:min(width, len(text))]
+		b.WriteString(string(text))
+		if t.panel.A != 0 {
+			b.WriteString(strings.Repeat(" ", width-len(text)))
+		}
+	}
+	_, _ = screen.Write(b.Bytes())
+	return len(lines)
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+// buddhaKind is which, if any, of the orbit density renderers is showing
+type buddhaKind int
+
+const (
+	noBuddha buddhaKind = iota
+	buddhabrotKind
+	nebulabrotKind
+	antiBuddhabrotKind
+)
+
+// Globals
+var (
+	buddhaMode buddhaKind
+	buddha     *buddhaRenderer
+
+	// Iteration limits of the red, green and blue layers of the
+	// Nebulabrot and which of them the keys change
+	nebulaLimits  = [3]int{5000, 500, 50}
+	nebulaChannel = 0
+
+	// Brightness the density is scaled by when tone mapped
+	exposure = 1.0
+)
+
+// Number of orbits each Buddhabrot worker follows before taking the lock
+const buddhaBatch = 1024
+
+// Bounds on the iteration limits of the Nebulabrot layers
+const (
+	minNebulaLimit = 16
+	maxNebulaLimit = 1 << 20
+)
+
+// buddhaRenderer accumulates the orbits of randomly chosen points of
+// the plane which escape the Mandelbrot set into density histograms,
+// one for each iteration limit, which are tone mapped for display.
+// The anti-Buddhabrot accumulates the orbits of the points which
+// don't escape instead.
+//
+// Like the flame, rendering is progressive - the workers keep running
+// in the background and the image sharpens the longer it is left.
+type buddhaRenderer struct {
+	mu      sync.Mutex
+	width   int
+	height  int
+	center  complex128
+	radius  float64
+	limits  []int       // iteration limit of each layer
+	anti    bool        // set to plot the orbits which don't escape
+	hits    [][]float32 // number of orbit points landing on each pixel of each layer
+	samples int         // number of points whose orbits have been followed
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// newBuddhaRenderer starts a renderer for the current view with a
+// layer for each of the iteration limits
+func newBuddhaRenderer(width, height int, limits []int, anti bool) *buddhaRenderer {
+	br := &buddhaRenderer{
+		width:  width,
+		height: height,
+		center: absCenter(),
+		radius: radius,
+		limits: limits,
+		anti:   anti,
+		hits:   make([][]float32, len(limits)),
+		stop:   make(chan struct{}),
+	}
+	for i := range br.hits {
+		br.hits[i] = make([]float32, width*height)
+	}
+	for i := 0; i < runtime.NumCPU(); i++ {
+		br.wg.Add(1)
+		go br.worker(rand.Int63())
+	}
+	return br
+}
+
+// worker follows the orbits of random points until stopped
+//
+// The points are chosen from anywhere within 2 of the origin as
+// orbits from anywhere can land in the view. Each orbit is followed
+// to the largest limit and plotted in every layer whose limit it
+// escaped within, or didn't for the anti-Buddhabrot. The Buddhabrot
+// is symmetric so each orbit is plotted reflected in the real axis
+// too.
+func (br *buddhaRenderer) worker(seed int64) {
+	defer br.wg.Done()
+	rng := rand.New(rand.NewSource(seed))
+	x0, y0, dx, dy := viewGrid(br.center, br.radius, br.width, br.height)
+	maxLimit := slices.Max(br.limits)
+	orbit := make([]complex128, maxLimit)
+	batch := make([][]int, len(br.limits))
+	for {
+		select {
+		case <-br.stop:
+			return
+		default:
+		}
+		for layer := range batch {
+			batch[layer] = batch[layer][:0]
+		}
+		for n := 0; n < buddhaBatch; n++ {
+			c := complex(4*rng.Float64()-2, 4*rng.Float64()-2)
+			if !br.anti && inMainBulbs(c) != "" {
+				continue
+			}
+			z := complex(0, 0)
+			i := 0
+			for ; i < maxLimit; i++ {
+				z = z*z + c
+				if real(z)*real(z)+imag(z)*imag(z) >= 4 {
+					break
+				}
+				orbit[i] = z
+			}
+			if (i >= maxLimit) != br.anti || i < 2 {
+				// Not plotted in any layer or nothing to plot -
+				// the first point is c itself which would only
+				// add noise
+				continue
+			}
+			for _, z := range orbit[1:i] {
+				px := int((real(z) - x0) / dx)
+				if px < 0 || px >= br.width {
+					continue
+				}
+				for _, y := range [2]float64{imag(z), -imag(z)} {
+					py := int((y - y0) / dy)
+					if py < 0 || py >= br.height {
+						continue
+					}
+					for layer, limit := range br.limits {
+						if (i < limit) != br.anti {
+							batch[layer] = append(batch[layer], py*br.width+px)
+						}
+					}
+				}
+			}
+		}
+		br.mu.Lock()
+		for layer, ps := range batch {
+			hits := br.hits[layer]
+			for _, p := range ps {
+				hits[p]++
+			}
+		}
+		br.samples += buddhaBatch
+		br.mu.Unlock()
+	}
+}
+
+// brightness returns how bright each pixel of the layer is from 0 to 1
+//
+// The density is square rooted so the faint outer orbits show as
+// well as the bright core, then scaled by the exposure.
+func (br *buddhaRenderer) brightness(layer int) []float64 {
+	hits := br.hits[layer]
+	maxHits := slices.Max(hits)
+	v := make([]float64, len(hits))
+	if maxHits == 0 {
+		return v
+	}
+	for p, h := range hits {
+		v[p] = min(1, exposure*math.Sqrt(float64(h/maxHits)))
+	}
+	return v
+}
+
+// toneMap converts the histograms into RGB
+//
+// A single layer is colored along the gradient, whereas three are
+// the red, green and blue channels of the Nebulabrot.
+func (br *buddhaRenderer) toneMap() []byte {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	data := make([]byte, 3*br.width*br.height)
+	if len(br.limits) == 1 {
+		for p, v := range br.brightness(0) {
+			col := gradientColor(v)
+			data[3*p+0], data[3*p+1], data[3*p+2] = col.R, col.G, col.B
+		}
+		return data
+	}
+	for layer := range br.limits {
+		for p, v := range br.brightness(layer) {
+			data[3*p+layer] = byte(255 * v)
+		}
+	}
+	return data
+}
+
+// shutdown stops the workers and waits for them to finish
+func (br *buddhaRenderer) shutdown() {
+	close(br.stop)
+	br.wg.Wait()
+}
+
+// buddhaLimits returns the iteration limits of the layers for the
+// current mode
+func buddhaLimits() []int {
+	if buddhaMode == nebulabrotKind {
+		return nebulaLimits[:]
+	}
+	return []int{depth}
+}
+
+// setBuddhabrot shows the orbit density renderer given, or stops it,
+// turning off the flame if need be as that would hide it
+func setBuddhabrot(kind buddhaKind) {
+	buddhaMode = kind
+	if kind != noBuddha {
+		flameMode = false
+		stopFlame()
+	} else {
+		stopBuddhabrot()
+	}
+}
+
+// nextBuddhabrot cycles through the Buddhabrot, the Nebulabrot, the
+// anti-Buddhabrot and back to the plot of the set
+func nextBuddhabrot() {
+	setBuddhabrot((buddhaMode + 1) % (antiBuddhabrotKind + 1))
+}
+
+// stopBuddhabrot stops any running Buddhabrot renderer
+func stopBuddhabrot() {
+	if buddha != nil {
+		buddha.shutdown()
+		buddha = nil
+	}
+}
+
+// changeNebulaLimit doubles or halves the iteration limit of the
+// selected layer of the Nebulabrot
+func changeNebulaLimit(double bool) {
+	limit := &nebulaLimits[nebulaChannel]
+	if double {
+		*limit = min(*limit*2, maxNebulaLimit)
+	} else {
+		*limit = max(*limit/2, minNebulaLimit)
+	}
+}
+
+// changeExposure brightens or darkens the density renderers
+func changeExposure(factor float64) {
+	exposure = min(max(exposure*factor, 1.0/64), 64)
+}
+
+// buddhaInfo describes the progress of the Buddhabrot for the info
+// overlay
+func buddhaInfo() []string {
+	samples := 0
+	if buddha != nil {
+		buddha.mu.Lock()
+		samples = buddha.samples
+		buddha.mu.Unlock()
+	}
+	if buddhaMode == antiBuddhabrotKind {
+		return []string{
+			fmt.Sprintf(tr("• Anti-Buddhabrot samples %d"), samples),
+			fmt.Sprintf(tr("• Exposure %.3g"), exposure),
+		}
+	}
+	if buddhaMode != nebulabrotKind {
+		return []string{
+			fmt.Sprintf(tr("• Buddhabrot samples %d"), samples),
+			fmt.Sprintf(tr("• Exposure %.3g"), exposure),
+		}
+	}
+	limits := ""
+	for i, limit := range nebulaLimits {
+		if i == nebulaChannel {
+			limits += fmt.Sprintf(" [%d]", limit)
+		} else {
+			limits += fmt.Sprintf(" %d", limit)
+		}
+	}
+	return []string{
+		fmt.Sprintf(tr("• Nebulabrot samples %d"), samples),
+		fmt.Sprintf(tr("• Red, green and blue limits%s"), limits),
+		fmt.Sprintf(tr("• Exposure %.3g"), exposure),
+	}
+}
+
+// writeBuddhabrot displays the current state of the Buddhabrot,
+// (re)starting the renderer if the view has changed
+func writeBuddhabrot() {
+	width, height, _, cols, _, cellHeight := getImageDimensions()
+	imgWidth, imgHeight = width, height
+	limits, anti := buddhaLimits(), buddhaMode == antiBuddhabrotKind
+	if buddha == nil || buddha.width != width || buddha.height != height || buddha.center != absCenter() || buddha.radius != radius || !slices.Equal(buddha.limits, limits) || buddha.anti != anti {
+		stopBuddhabrot()
+		buddha = newBuddhaRenderer(width, height, slices.Clone(limits), anti)
+	}
+	writeRGBFrame(buddha.toneMap(), width, height, cols, cellHeight)
+}
+
+// densityMode returns true if a density renderer, the flame or the
+// Buddhabrot, is showing rather than the plot of the set
+func densityMode() bool {
+	return flameMode || buddhaMode != noBuddha
+}
+
+// densityName returns the name of the density renderer showing, or
+// "" if the plot of the set is
+func densityName() string {
+	switch {
+	case flameMode:
+		return "flame"
+	case buddhaMode == buddhabrotKind:
+		return "buddhabrot"
+	case buddhaMode == nebulabrotKind:
+		return "nebulabrot"
+	case buddhaMode == antiBuddhabrotKind:
+		return "antibuddhabrot"
+	}
+	return ""
+}
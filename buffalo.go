@@ -0,0 +1,37 @@
+package main
+
+import "math"
+
+func init() {
+	registerFractal(30, buffaloFractal{})
+}
+
+// buffaloFractal is the Buffalo fractal
+type buffaloFractal struct{}
+
+func (buffaloFractal) Name() string                       { return "buffalo" }
+func (buffaloFractal) Title() string                      { return "Buffalo" }
+func (buffaloFractal) DefaultView() (complex128, float64) { return complex(-0.4, -0.5), 1.5 }
+
+// Iterate iterates the Buffalo fractal from iteration i until it
+// escapes or reaches maxDepth iterations
+//
+// This is the Mandelbrot iteration with the absolute values of both
+// parts of z^2 taken.
+func (buffaloFractal) Iterate(z, c complex128, i, maxDepth int) (int, complex128) {
+	x, y := real(z), imag(z)
+	cx, cy := real(c), imag(c)
+	for ; i < maxDepth; i++ {
+		if x*x+y*y >= escapeRadius*escapeRadius {
+			break
+		}
+		x, y = buffaloStep(x, y, cx, cy)
+	}
+	return i, complex(x, y)
+}
+
+// buffaloStep does one step of the Buffalo iteration of x, y with c at
+// cx, cy
+func buffaloStep(x, y, cx, cy float64) (float64, float64) {
+	return math.Abs(x*x-y*y) + cx, 2*math.Abs(x*y) + cy
+}
@@ -0,0 +1,37 @@
+package main
+
+import "math"
+
+func init() {
+	registerFractal(10, burningShipFractal{})
+}
+
+// burningShipFractal is the Burning Ship fractal
+type burningShipFractal struct{}
+
+func (burningShipFractal) Name() string                       { return "burning-ship" }
+func (burningShipFractal) Title() string                      { return "Burning Ship" }
+func (burningShipFractal) DefaultView() (complex128, float64) { return complex(-0.4, -0.6), 1.7 }
+
+// Iterate iterates the Burning Ship fractal from iteration i until it
+// escapes or reaches maxDepth iterations
+//
+// This is the Mandelbrot iteration with the absolute values of the
+// parts of z taken before squaring it.
+func (burningShipFractal) Iterate(z, c complex128, i, maxDepth int) (int, complex128) {
+	x, y := real(z), imag(z)
+	cx, cy := real(c), imag(c)
+	for ; i < maxDepth; i++ {
+		if x*x+y*y >= escapeRadius*escapeRadius {
+			break
+		}
+		x, y = burningShipStep(x, y, cx, cy)
+	}
+	return i, complex(x, y)
+}
+
+// burningShipStep does one step of the Burning Ship iteration of x, y
+// with c at cx, cy
+func burningShipStep(x, y, cx, cy float64) (float64, float64) {
+	return x*x - y*y + cx, 2*math.Abs(x*y) + cy
+}
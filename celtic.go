@@ -0,0 +1,37 @@
+package main
+
+import "math"
+
+func init() {
+	registerFractal(20, celticFractal{})
+}
+
+// celticFractal is the Celtic fractal
+type celticFractal struct{}
+
+func (celticFractal) Name() string                       { return "celtic" }
+func (celticFractal) Title() string                      { return "Celtic" }
+func (celticFractal) DefaultView() (complex128, float64) { return complex(-0.4, 0), 1.8 }
+
+// Iterate iterates the Celtic fractal from iteration i until it
+// escapes or reaches maxDepth iterations
+//
+// This is the Mandelbrot iteration with the absolute value of the
+// real part of z^2 taken.
+func (celticFractal) Iterate(z, c complex128, i, maxDepth int) (int, complex128) {
+	x, y := real(z), imag(z)
+	cx, cy := real(c), imag(c)
+	for ; i < maxDepth; i++ {
+		if x*x+y*y >= escapeRadius*escapeRadius {
+			break
+		}
+		x, y = celticStep(x, y, cx, cy)
+	}
+	return i, complex(x, y)
+}
+
+// celticStep does one step of the Celtic iteration of x, y with c at
+// cx, cy
+func celticStep(x, y, cx, cy float64) (float64, float64) {
+	return math.Abs(x*x-y*y) + cx, 2*x*y + cy
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"math/cmplx"
+)
+
+func init() {
+	registerFractal(120, collatzFractal{})
+}
+
+// Size of z the Collatz fractal has escaped at, beyond which the
+// cosine soon overflows
+const collatzEscape = 100
+
+// Number of iterations the gradient is spread over by collatzColor
+const collatzColorScale = 10
+
+// collatzFractal is the fractal of the complex Collatz map
+type collatzFractal struct{}
+
+func (collatzFractal) Name() string                       { return "collatz" }
+func (collatzFractal) Title() string                      { return "Collatz fractal" }
+func (collatzFractal) DefaultView() (complex128, float64) { return 0, 3 }
+func (collatzFractal) NoJulia() bool                      { return true }
+
+// Iterate iterates the complex Collatz map from iteration i until it
+// escapes or reaches maxDepth iterations
+//
+//	z = (2 + 7z - (2 + 5z) cos(πz)) / 4
+//
+// which is n/2 for even whole numbers n and 3n + 1 for odd ones. It
+// is the points of the plane which are iterated rather than a
+// parameter, so z starts at c and it has no Julia sets. The cosine
+// makes it much slower than the other fractals.
+func (collatzFractal) Iterate(z, c complex128, i, maxDepth int) (int, complex128) {
+	if i == 0 {
+		z = c
+	}
+	for ; i < maxDepth; i++ {
+		if real(z)*real(z)+imag(z)*imag(z) >= collatzEscape*collatzEscape {
+			break
+		}
+		z = (2 + 7*z - (2+5*z)*cmplx.Cos(math.Pi*z)) / 4
+	}
+	return i, z
+}
+
+// Color colors a point of the Collatz fractal
+//
+// Points escape in a few iterations so coloring them along the
+// gradient by the depth would leave them all dark, so the gradient
+// is spread over the first collatzColorScale iterations instead.
+func (collatzFractal) Color(it iteration) color.RGBA {
+	if it.i >= depth {
+		return color.RGBA{0, 0, 0, 255}
+	}
+	smooth := float64(it.i) + 1 - math.Log2(math.Log(cmplx.Abs(it.z)))
+	return decomposeColor(gradientColor(smooth/collatzColorScale), it.z)
+}
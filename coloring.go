@@ -0,0 +1,220 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"math"
+	"math/cmplx"
+	"strings"
+	"time"
+)
+
+// Flags
+var (
+	cycleRate = flag.Float64("cycle-rate", 0.125, "Palette cycling speed in turns round the palette per second, negative to go backwards")
+)
+
+// coloringMode is how the points outside the set are colored
+type coloringMode int
+
+// The coloring modes
+const (
+	smoothColoring    coloringMode = iota // by the smooth iteration count
+	angleColoring                         // by the angle of z when it escaped
+	angleIterColoring                     // by the angle, brighter for more iterations
+	histogramColoring                     // by how many points of the frame escaped sooner
+	cyclicColoring                        // by the smooth iteration count going round the palette
+	distanceColoring                      // by the smooth iteration count, darkened near the set
+	stripeColoring                        // by the average of the stripe terms of the orbit
+	expColoring                           // by the sum of exp(-|z|) over the orbit
+)
+
+// Names of the coloring modes indexed by coloringMode
+var coloringNames = []string{"smooth", "angle", "angle-iter", "histogram", "cyclic", "distance", "stripe", "exponential"}
+
+// Number of iterations the palette goes round once in for cyclic
+// coloring at a density of 1
+const cycleIterations = 64
+
+// Palette mapping settings
+var (
+	paletteDensity = 1.0 // number of times the palette goes round per cycleIterations for cyclic coloring
+	paletteOffset  = 0.0 // fraction of the way round the palette the colors are shifted by
+
+	paletteCycling = false   // set while the palette offset is going round by itself
+	lastCycle      time.Time // time the palette offset was last moved on
+)
+
+// The fraction of the escaped points of the frame which escaped in
+// fewer than each number of iterations, for histogram coloring
+var histogram []float64
+
+// The coloring mode in use
+var coloring = smoothColoring
+
+// setColoring selects the coloring mode called name, turning off the
+// layers
+func setColoring(name string) error {
+	for i, n := range coloringNames {
+		if n == name {
+			if coloring != coloringMode(i) || layers != nil {
+				coloring = coloringMode(i)
+				layers = nil
+				coloringID++
+				recolor()
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown coloring %q - use one of %s", name, strings.Join(coloringNames, ", "))
+}
+
+// nextColoring cycles to the next coloring mode
+func nextColoring() {
+	_ = setColoring(coloringNames[(int(coloring)+1)%len(coloringNames)])
+	message = fmt.Sprintf(tr("Coloring %s"), coloringNames[coloring])
+}
+
+// angleColor maps the angle of z as it escaped to a color from the
+// gradient, which makes the angular patterns which follow the field
+// lines round the set. Binary decomposition is the same thing with
+// only two colors. With iter set it is shaded brighter for more
+// iterations.
+func angleColor(i int, z complex128, maxDepth int, iter bool) color.RGBA {
+	t := (cmplx.Phase(z) + math.Pi) / (2 * math.Pi)
+	col := paletteColor(t)
+	if iter {
+		smooth := max(0, smoothCount(i, z))
+		shade(&col, 0.25+0.75*math.Log1p(smooth)/math.Log1p(float64(maxDepth)))
+	}
+	return decomposeColor(col, z)
+}
+
+// shade scales the brightness of col by f
+func shade(col *color.RGBA, f float64) {
+	f = math.Min(math.Max(f, 0), 1)
+	col.R = uint8(f * float64(col.R))
+	col.G = uint8(f * float64(col.G))
+	col.B = uint8(f * float64(col.B))
+}
+
+// equalize builds the histogram of the iteration counts of the points
+// in iters which escaped before plotDepth
+//
+// The counts are clamped to the depth the user asked for, as the
+// colors are, so refining the depth doesn't change the histogram.
+func equalize(iters []iteration, plotDepth int) {
+	counts := make([]int, depth+1)
+	escaped := 0
+	for _, it := range iters {
+		if it.i < plotDepth {
+			counts[min(it.i, depth-1)+1]++
+			escaped++
+		}
+	}
+	histogram = make([]float64, depth+1)
+	total := 0
+	for n, count := range counts {
+		total += count
+		histogram[n] = float64(total) / float64(max(1, escaped))
+	}
+}
+
+// histogramColor maps the smooth iteration count of a point to the
+// gradient by the fraction of the points of the frame which escaped
+// sooner, so the colors are spread evenly over the frame however the
+// iteration counts bunch up
+func histogramColor(i int, z complex128, maxDepth int) color.RGBA {
+	smooth := max(0, smoothCount(i, z))
+	if len(histogram) != maxDepth+1 {
+		// Not made yet for this depth
+		return decomposeColor(paletteColor(smooth/float64(maxDepth)), z)
+	}
+	n := min(int(smooth), maxDepth-1)
+	frac := min(smooth-float64(n), 1)
+	t := histogram[n] + (histogram[n+1]-histogram[n])*frac
+	return decomposeColor(paletteColor(t), z)
+}
+
+// cyclicColor maps the smooth iteration count of a point round and
+// round the palette, paletteDensity times every cycleIterations, so
+// deep zooms where the counts are all large still get every color
+func cyclicColor(i int, z complex128) color.RGBA {
+	t := max(0, smoothCount(i, z)) * paletteDensity / cycleIterations
+	return decomposeColor(paletteColor(t-math.Floor(t)), z)
+}
+
+// paletteColor returns the color at t along the gradient shifted by
+// the palette offset, going round from the end to the start if it is
+// shifted, or in gray or mono if the tone is set to them
+func paletteColor(t float64) color.RGBA {
+	if paletteOffset != 0 {
+		t += paletteOffset
+		t -= math.Floor(t)
+	}
+	if tone != colorTone {
+		return toneColor(t)
+	}
+	return gradientColor(t)
+}
+
+// changeDensity changes how many times the palette goes round for
+// cyclic coloring by factor
+func changeDensity(factor float64) {
+	setDensity(paletteDensity * factor)
+	message = fmt.Sprintf(tr("Palette density %.3g"), paletteDensity)
+}
+
+// setDensity sets how many times the palette goes round for cyclic
+// coloring
+func setDensity(density float64) {
+	density = min(max(density, 1.0/64), 64)
+	if density != paletteDensity {
+		paletteDensity = density
+		coloringID++
+		recolor()
+	}
+}
+
+// shiftPalette shifts the colors round the palette by steps of
+// 1/32 of the way round
+func shiftPalette(steps int) {
+	setPaletteOffset(paletteOffset + float64(steps)/32)
+	message = fmt.Sprintf(tr("Palette offset %.3g"), paletteOffset)
+}
+
+// setPaletteOffset shifts the colors offset of the way round the
+// palette
+func setPaletteOffset(offset float64) {
+	offset -= math.Floor(offset)
+	if offset != paletteOffset {
+		paletteOffset = offset
+		recolor()
+	}
+}
+
+// togglePaletteCycling starts or stops the colors going round the
+// palette by themselves
+func togglePaletteCycling() {
+	paletteCycling = !paletteCycling
+	lastCycle = time.Now()
+	if paletteCycling {
+		message = tr("Palette cycling on")
+	} else {
+		message = tr("Palette cycling off")
+	}
+}
+
+// cycleStep moves the palette offset on by the time since the last
+// step
+//
+// Only the colors change so the plot is colored again from the
+// iteration results kept with it, antialiasing and all, rather than
+// iterated again.
+func cycleStep() {
+	now := time.Now()
+	dt := now.Sub(lastCycle)
+	lastCycle = now
+	setPaletteOffset(paletteOffset + *cycleRate*dt.Seconds())
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// config is the user's settings read from the config file
+type config struct {
+	FontSize float64  `json:"font_size"` // size of the overlay text in points
+	Theme    string   `json:"theme"`     // name of the overlay theme
+	Language string   `json:"language"`  // language of the user interface, eg "fr"
+	Fonts    []string `json:"fonts"`     // TrueType fonts to use for characters missing from the built in one
+	Journal  bool     `json:"journal"`   // set to write every view visited to the journal
+	Palette  string   `json:"palette"`   // built in palette or palette file to start with
+	Gradient string   `json:"gradient"`  // gradient to start with as position:color stops
+
+	StartupMenu bool `json:"startup_menu"` // set to start with a menu of recent sessions and bookmarks
+}
+
+// The settings, with the defaults for anything not in the config file
+var cfg = config{
+	FontSize: defaultFontSize,
+}
+
+// configDir returns the directory termbrot keeps its files in
+func configDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "termbrot"), nil
+}
+
+// configPath returns the path of the config file
+func configPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.json"), nil
+}
+
+// loadConfig reads the config file into cfg if it exists
+func loadConfig() error {
+	path, err := configPath()
+	if err != nil {
+		// No config directory so no config
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
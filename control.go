@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Flags
+var (
+	controlFlag = flag.String("control", "", `Read commands from "stdin", one per line, eg goto -0.75 0.1 0.01`)
+)
+
+// eventCommand is the type of the event sent when a command arrives
+const eventCommand termbox.EventType = 100
+
+// Commands waiting to be run, one per eventCommand in events
+var commands = make(chan string, 64)
+
+// checkControlFlag checks the value of --control
+func checkControlFlag() error {
+	switch *controlFlag {
+	case "", "stdin":
+		return nil
+	}
+	return fmt.Errorf("--control must be \"stdin\" not %q", *controlFlag)
+}
+
+// readCommands reads the commands from stdin in the background,
+// sending an event for each so they are run in order with the input
+// from the terminal.
+func readCommands() {
+	if *controlFlag != "stdin" {
+		return
+	}
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			commands <- line
+			events <- inputEvent{Event: termbox.Event{Type: eventCommand}, t: time.Now()}
+		}
+	}()
+}
+
+// parseFloats parses all the args as floats
+func parseFloats(args []string) ([]float64, error) {
+	var fs []float64
+	for _, arg := range args {
+		f, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q", arg)
+		}
+		fs = append(fs, f)
+	}
+	return fs, nil
+}
+
+// parseBool parses on/off style arguments
+func parseBool(arg string) (bool, error) {
+	switch strings.ToLower(arg) {
+	case "on", "true", "yes", "1":
+		return true, nil
+	case "off", "false", "no", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("bad switch %q - use on or off", arg)
+}
+
+// runCommand runs a command line, returning whether to quit
+//
+// The commands are
+//
+//	goto <re> <im> [<radius>]
+//	zoom <factor>
+//	minibrot
+//	set depth|radius|decompose|sectors|coloring|layers|interior|formula|hybrid|fractal|flame|theme|palette|gradient|interpolate|density|offset|cycling|lighting|light|tone|gamma|brightness|contrast|saturation|fontsize <value>
+//	screenshot <file.png>
+//	palette <file.map|file.ugr|file.json>
+//	quit
+func runCommand(line string) (bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false, nil
+	}
+	cmd, args := fields[0], fields[1:]
+	switch cmd {
+	case "goto":
+		fs, err := parseFloats(args)
+		if err != nil {
+			return false, err
+		}
+		if len(fs) < 2 || len(fs) > 3 {
+			return false, fmt.Errorf("goto needs <re> <im> [<radius>]")
+		}
+		p, err := parseDeepPoint(args[0], args[1])
+		if err != nil {
+			return false, err
+		}
+		setDeepCenter(p)
+		if len(fs) == 3 && fs[2] > 0 {
+			radius = fs[2]
+		}
+	case "zoom":
+		fs, err := parseFloats(args)
+		if err != nil {
+			return false, err
+		}
+		if len(fs) != 1 || !(fs[0] > 0) {
+			return false, fmt.Errorf("zoom needs a factor more than 0")
+		}
+		radius /= fs[0]
+	case "minibrot":
+		return false, zoomToMinibrot()
+	case "set":
+		if len(args) < 2 {
+			return false, fmt.Errorf("set needs a name and a value")
+		}
+		return false, setCommand(args[0], strings.Join(args[1:], " "))
+	case "screenshot":
+		if len(args) != 1 {
+			return false, fmt.Errorf("screenshot needs a file name")
+		}
+		return false, screenshot(args[0])
+	case "palette":
+		if len(args) != 1 {
+			return false, fmt.Errorf("palette needs a file name")
+		}
+		return false, savePalette(args[0])
+	case "quit":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown command %q", cmd)
+	}
+	return false, nil
+}
+
+// setCommand sets the setting name to value
+func setCommand(name, value string) error {
+	switch name {
+	case "depth":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("bad depth %q", value)
+		}
+		setDepth(n)
+	case "auto-depth":
+		b, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		if b != autoDepth {
+			toggleAutoDepth()
+		}
+	case "radius":
+		r, err := strconv.ParseFloat(value, 64)
+		if err != nil || !(r > 0) {
+			return fmt.Errorf("bad radius %q", value)
+		}
+		radius = r
+	case "decompose":
+		b, err := parseBool(value)
+		if err != nil {
+			return setDecomposition(value)
+		}
+		decompose = b
+		recolor()
+	case "sectors":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("bad sectors %q", value)
+		}
+		return setSectors(n)
+	case "coloring":
+		return setColoring(value)
+	case "layers":
+		return setLayers(value)
+	case "formula":
+		return setFormula(value)
+	case "hybrid":
+		return setHybrid(value)
+	case "fractal":
+		f, err := fractalByName(value)
+		if err != nil {
+			return err
+		}
+		setFractal(f)
+	case "flame":
+		b, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		flameMode = b
+		if flameMode {
+			setBuddhabrot(noBuddha)
+		} else {
+			stopFlame()
+		}
+	case "gamma", "brightness", "contrast", "saturation":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("bad %s %q", name, value)
+		}
+		return setAdjustment(name, f)
+	case "tone":
+		return setTone(value)
+	case "interior":
+		return setInterior(value)
+	case "lighting":
+		b, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		if b != lighting {
+			toggleLighting()
+		}
+	case "light":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("bad light angle %q", value)
+		}
+		setLightAngle(f)
+	case "cycling":
+		b, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		if b != paletteCycling {
+			togglePaletteCycling()
+		}
+	case "buddhabrot", "nebulabrot", "antibuddhabrot":
+		b, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		kind := buddhabrotKind
+		switch name {
+		case "nebulabrot":
+			kind = nebulabrotKind
+		case "antibuddhabrot":
+			kind = antiBuddhabrotKind
+		}
+		if b {
+			setBuddhabrot(kind)
+		} else if buddhaMode == kind {
+			setBuddhabrot(noBuddha)
+		}
+	case "exposure":
+		e, err := strconv.ParseFloat(value, 64)
+		if err != nil || !(e > 0) {
+			return fmt.Errorf("bad exposure %q", value)
+		}
+		exposure = e
+	case "theme":
+		return setTheme(value)
+	case "palette":
+		return usePalette(value)
+	case "gradient":
+		return setGradientSpec(value)
+	case "interpolate":
+		return setInterpolation(value)
+	case "density":
+		d, err := strconv.ParseFloat(value, 64)
+		if err != nil || !(d > 0) {
+			return fmt.Errorf("bad density %q", value)
+		}
+		setDensity(d)
+	case "offset":
+		o, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(o) || math.IsInf(o, 0) {
+			return fmt.Errorf("bad offset %q", value)
+		}
+		setPaletteOffset(o)
+	case "fontsize":
+		size, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("bad font size %q", value)
+		}
+		setFontSize(size)
+	default:
+		return fmt.Errorf("unknown setting %q", name)
+	}
+	return nil
+}
+
+// screenshot draws the current view and saves it as a PNG
+func screenshot(path string) error {
+	draw()
+	if !densityMode() && !lastPlotCurrent() {
+		return fmt.Errorf("view not plotted at full resolution yet")
+	}
+	var data []byte
+	var width, height int
+	if flameMode {
+		data, width, height = flame.toneMap(), flame.width, flame.height
+	} else if buddhaMode != noBuddha {
+		data, width, height = buddha.toneMap(), buddha.width, buddha.height
+	} else {
+		data, width, height = lastPlot.data, lastPlot.width, lastPlot.height
+	}
+	return savePNG(path, rgbImage(data, width, height))
+}
+
+// rgbImage makes an image from raw RGB data
+func rgbImage(data []byte, width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < width*height; i++ {
+		copy(img.Pix[4*i:4*i+3], data[3*i:3*i+3])
+		img.Pix[4*i+3] = 255
+	}
+	return img
+}
+
+// savePNG saves img as a PNG file
+func savePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = png.Encode(f, img)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
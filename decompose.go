@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"math"
+	"math/cmplx"
+	"strings"
+)
+
+// Flags
+var (
+	sectorsFlag = flag.Int("sectors", 2, "Number of sectors binary decompose splits the angle of z into")
+)
+
+// decomposeStyle is how decompose marks the angle z escaped at
+type decomposeStyle int
+
+// The decompose styles
+const (
+	binaryDecompose decomposeStyle = iota // darkens every other sector of the angle
+	tintedDecompose                       // tints by the angle
+)
+
+// Names of the decompose styles indexed by decomposeStyle
+var decomposeNames = []string{"binary", "tinted"}
+
+// Decompose settings
+const (
+	decomposeShade = 0.5 // brightness of the dark sectors of binary decompose
+	decomposeTint  = 0.5 // how much of the color tinted decompose replaces
+	maxSectors     = 64  // most sectors binary decompose can use
+)
+
+// Decompose state, used when decompose is set
+var (
+	decomposition    = binaryDecompose
+	decomposeSectors = 2
+)
+
+// checkSectorsFlag sets the number of sectors from --sectors
+func checkSectorsFlag() error {
+	if err := setSectors(*sectorsFlag); err != nil {
+		return fmt.Errorf("--sectors: %w", err)
+	}
+	return nil
+}
+
+// setSectors sets the number of sectors of binary decompose
+func setSectors(n int) error {
+	if n < 2 || n > maxSectors {
+		return fmt.Errorf("sectors must be from 2 to %d not %d", maxSectors, n)
+	}
+	if n != decomposeSectors {
+		decomposeSectors = n
+		coloringID++
+		recolor()
+	}
+	return nil
+}
+
+// setDecomposition selects the decompose style called name and turns
+// decompose on
+func setDecomposition(name string) error {
+	for i, n := range decomposeNames {
+		if n == name {
+			if decomposition != decomposeStyle(i) {
+				decomposition = decomposeStyle(i)
+				coloringID++
+			}
+			decompose = true
+			recolor()
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown decompose %q - use on, off or one of %s", name, strings.Join(decomposeNames, ", "))
+}
+
+// nextDecomposition cycles to the next decompose style
+func nextDecomposition() {
+	_ = setDecomposition(decomposeNames[(int(decomposition)+1)%len(decomposeNames)])
+	message = fmt.Sprintf(tr("Decompose %s"), decomposeNames[decomposition])
+}
+
+// decomposeColor marks col with the angle of z if decompose is on,
+// either darkening every other one of decomposeSectors sectors, which
+// with 2 is the classic binary decomposition, or tinting it with a hue
+// which goes round with the angle
+func decomposeColor(col color.RGBA, z complex128) color.RGBA {
+	if !decompose {
+		return col
+	}
+	t := (cmplx.Phase(z) + math.Pi) / (2 * math.Pi)
+	if decomposition == tintedDecompose {
+		_, _, v := rgbToHSV(col)
+		return mixRGB(col, hsvToRGB(t, 1, v), decomposeTint)
+	}
+	if int(t*float64(decomposeSectors))%2 == 0 {
+		shade(&col, decomposeShade)
+	}
+	return col
+}
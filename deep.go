@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/cmplx"
+)
+
+// deepPoint is a point of the set held to more precision than a
+// complex128 can, for zooms deeper than float64 can reach
+type deepPoint struct {
+	re, im     *big.Float
+	approx     complex128   // the point rounded to a complex128
+	ddRe, ddIm doubleDouble // the point rounded to double-double
+}
+
+// Deep zoom settings
+const (
+	// Pixels spaced closer than this relative to the size of the
+	// center, about ten times the 2^-52 resolution of float64, can't
+	// be told apart from the next pixel in float64 so need the deep
+	// zoom kernels
+	deepSpacing = 2e-15
+
+	// Once center is this many radii from origin it is moved, so
+	// center stays small enough to hold the position within a pixel
+	rebaseDistance = 1e6
+)
+
+// The point center is relative to at deep zooms
+//
+// Once the view needs the deep zoom kernels, center and the set
+// co-ordinates of the plots are offsets from origin rather than the
+// points themselves, so they stay small enough for a float64 to hold
+// them to a fraction of a pixel. It is nil while they are the points
+// themselves.
+var origin *deepPoint
+
+// newDeepPoint returns the point d from o, or d itself if o is nil,
+// held to prec bits
+func newDeepPoint(o *deepPoint, d complex128, prec uint) *deepPoint {
+	p := &deepPoint{
+		re: new(big.Float).SetPrec(prec).SetFloat64(real(d)),
+		im: new(big.Float).SetPrec(prec).SetFloat64(imag(d)),
+	}
+	if o != nil {
+		p.re.Add(p.re, o.re)
+		p.im.Add(p.im, o.im)
+	}
+	p.round()
+	return p
+}
+
+// round works out the roundings of the point to complex128 and
+// double-double
+func (p *deepPoint) round() {
+	p.ddRe, p.ddIm = toDoubleDouble(p.re), toDoubleDouble(p.im)
+	p.approx = complex(p.ddRe.hi, p.ddIm.hi)
+}
+
+// toDoubleDouble returns f rounded to double-double
+func toDoubleDouble(f *big.Float) doubleDouble {
+	hi, _ := f.Float64()
+	lo, _ := new(big.Float).Sub(f, big.NewFloat(hi)).Float64()
+	return doubleDouble{hi, lo}
+}
+
+// parseDeepPoint parses the real and imaginary parts of a point to
+// as many digits as they are given to
+func parseDeepPoint(reText, imText string) (*deepPoint, error) {
+	p := &deepPoint{}
+	for _, part := range []struct {
+		text string
+		f    **big.Float
+	}{{reText, &p.re}, {imText, &p.im}} {
+		prec := max(64, uint(float64(len(part.text))*math.Log2(10))+16)
+		f, _, err := big.ParseFloat(part.text, 10, prec, big.ToNearestEven)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q", part.text)
+		}
+		*part.f = f
+	}
+	p.round()
+	return p, nil
+}
+
+// text returns the real and imaginary parts of the point d from o in
+// decimal to as many digits as o holds
+func (o *deepPoint) text(d complex128) (re, im string) {
+	if o == nil {
+		return fmt.Sprintf("%.17g", real(d)), fmt.Sprintf("%.17g", imag(d))
+	}
+	p := newDeepPoint(o, d, o.re.Prec())
+	digits := int(float64(o.re.Prec())*math.Log10(2)) + 1
+	return p.re.Text('g', digits), p.im.Text('g', digits)
+}
+
+// absPoint returns the point p, which is relative to origin like
+// center, as a complex128
+func absPoint(p complex128) complex128 {
+	if origin == nil {
+		return p
+	}
+	return origin.approx + p
+}
+
+// absCenter returns the center of the view as a complex128
+func absCenter() complex128 {
+	return absPoint(center)
+}
+
+// absCenter returns the center of the view v as a complex128
+func (v view) absCenter() complex128 {
+	if v.origin == nil {
+		return v.center
+	}
+	return v.origin.approx + v.center
+}
+
+// centerFrom returns the center of the view v relative to o
+func (v view) centerFrom(o *deepPoint) complex128 {
+	if v.origin == o {
+		return v.center
+	}
+	prec := uint(64)
+	for _, p := range []*deepPoint{v.origin, o} {
+		if p != nil {
+			prec = max(prec, p.re.Prec())
+		}
+	}
+	c := newDeepPoint(v.origin, v.center, prec)
+	if o != nil {
+		c.re.Sub(c.re, o.re)
+		c.im.Sub(c.im, o.im)
+	}
+	re, _ := c.re.Float64()
+	im, _ := c.im.Float64()
+	return complex(re, im)
+}
+
+// setCenter centers the view on the point c
+func setCenter(c complex128) {
+	center, origin = c, nil
+}
+
+// setDeepCenter centers the view on the point p
+func setDeepCenter(p *deepPoint) {
+	center, origin = 0, p
+}
+
+// centerText returns the center of the view to as many digits as it
+// is known to for the info overlay
+func centerText() string {
+	if origin == nil {
+		return fmt.Sprintf("%g", center)
+	}
+	re, im := origin.text(center)
+	if im[0] != '-' {
+		im = "+" + im
+	}
+	return "(" + re + im + "i)"
+}
+
+// deepView returns true if the view is zoomed in too far for float64
+// to tell its pixels dx apart
+func deepView(dx float64) bool {
+	return !params.julia && dx < deepSpacing*max(1, cmplx.Abs(absCenter()))
+}
+
+// deepPrecision returns the bits of precision needed for the points
+// of the view, rounded up to a whole number of words so zooming in
+// doesn't change it every step
+func deepPrecision() uint {
+	return precisionFor(absCenter(), radius)
+}
+
+// precisionFor returns the bits of precision needed for the points of
+// a view of radius r round c, rounded up to a whole number of words
+func precisionFor(c complex128, r float64) uint {
+	bits := 64 + max(0, math.Ceil(math.Log2(max(1, cmplx.Abs(c))/r)))
+	return (uint(bits) + 63) &^ 63
+}
+
+// rebase chooses the kernel for a frame with its pixels dx apart and
+// makes the co-ordinates relative to origin if it is one of the deep
+// zoom kernels, moving origin to the center of the view if
+// it is too far away or not precise enough, or makes them the points
+// themselves again if it doesn't
+//
+// It must be called before each frame is plotted. Moving origin
+// changes the co-ordinates of every point, so the plots from before
+// aren't used to fill in the ones after.
+func rebase(dx float64) {
+	chooseKernel(dx)
+	if !deepKernel() {
+		if origin != nil {
+			exploreTarget += origin.approx
+			setCenter(absCenter())
+		}
+		return
+	}
+	prec := deepPrecision()
+	if origin != nil && origin.re.Prec() >= prec && cmplx.Abs(center) <= rebaseDistance*radius {
+		return
+	}
+	exploreTarget -= center
+	setDeepCenter(newDeepPoint(origin, center, prec))
+}
+
+// bigIterate iterates the point p, relative to origin, of the
+// Mandelbrot set in big.Float to the precision of origin
+//
+// This is very slow, but exact however deep the zoom, so is kept for
+// checking the perturbation kernel against.
+func bigIterate(p complex128, maxDepth int) iteration {
+	prec := deepPrecision()
+	if origin != nil {
+		prec = origin.re.Prec()
+	}
+	c := newDeepPoint(origin, p, prec)
+	x, y := new(big.Float).SetPrec(prec), new(big.Float).SetPrec(prec)
+	x2, y2, t := new(big.Float).SetPrec(prec), new(big.Float).SetPrec(prec), new(big.Float).SetPrec(prec)
+	i := 0
+	for ; i < maxDepth; i++ {
+		x2.Mul(x, x)
+		y2.Mul(y, y)
+		if r, _ := t.Add(x2, y2).Float64(); r >= 4 {
+			break
+		}
+		// y = 2xy + cy, x = x² - y² + cx
+		y.Mul(y, x)
+		y.Add(y, y)
+		y.Add(y, c.im)
+		x.Sub(x2, y2)
+		x.Add(x, c.re)
+	}
+	re, _ := x.Float64()
+	im, _ := y.Float64()
+	return iteration{i: i, z: complex(re, im)}
+}
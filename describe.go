@@ -0,0 +1,199 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Flags
+var (
+	describeFlag = flag.String("describe", "", `Describe each view in text for screen readers, "alongside" the images or "only" text`)
+)
+
+// The last view described so it is only described once
+var (
+	lastDescribed     view
+	lastDescribedMode string
+)
+
+// describeAgain makes the next view be described even if it hasn't
+// changed, eg if the screen was cleared
+func describeAgain() {
+	lastDescribed = view{}
+}
+
+// describing returns true if views should be described in text
+func describing() bool {
+	return *describeFlag != ""
+}
+
+// textOnly returns true if only the text descriptions are wanted
+func textOnly() bool {
+	return *describeFlag == "only"
+}
+
+// checkDescribeFlag checks the value of --describe
+func checkDescribeFlag() error {
+	switch *describeFlag {
+	case "", "alongside", "only":
+		return nil
+	}
+	return fmt.Errorf("--describe must be \"alongside\" or \"only\" not %q", *describeFlag)
+}
+
+// inMainBulbs returns which of the main cardioid or the period 2 bulb
+// c is in, or "" if neither
+func inMainBulbs(c complex128) string {
+	if inCardioid(c) {
+		return "the main cardioid"
+	}
+	if inPeriod2Bulb(c) {
+		return "the period 2 bulb"
+	}
+	return ""
+}
+
+// describePlot describes the structures in the last plot using some
+// simple heuristics
+func describePlot() string {
+	p := &lastPlot
+	if p.width == 0 || p.height == 0 {
+		return ""
+	}
+	var parts []string
+
+	// What is at the center
+	it := p.iters[(p.height/2)*p.width+p.width/2]
+	if it.i >= p.depth {
+		where := ""
+		if !params.julia && isMandelbrot(params.fractal) {
+			where = inMainBulbs(absCenter())
+		}
+		if where == "" {
+			where = "the set"
+		}
+		parts = append(parts, fmt.Sprintf("The center is inside %s", where))
+	} else {
+		parts = append(parts, fmt.Sprintf("The center escapes after %d iterations", it.i))
+	}
+
+	// How much is inside the set, where it is and how much edge
+	inside, edges := 0, 0
+	var sx, sy float64
+	for y := 0; y < p.height; y++ {
+		for x := 0; x < p.width; x++ {
+			in := p.iters[y*p.width+x].i >= p.depth
+			if in {
+				inside++
+				sx += float64(x)
+				sy += float64(y)
+			}
+			if x > 0 && in != (p.iters[y*p.width+x-1].i >= p.depth) {
+				edges++
+			}
+		}
+	}
+	total := p.width * p.height
+	switch fraction := float64(inside) / float64(total); {
+	case inside == 0:
+		parts = append(parts, "none of the view is inside the set")
+	case inside == total:
+		parts = append(parts, "all of the view is inside the set")
+	default:
+		s := fmt.Sprintf("%.0f%% of the view is inside the set", 100*fraction)
+		dx := sx/float64(inside)/float64(p.width) - 0.5
+		dy := sy/float64(inside)/float64(p.height) - 0.5
+		var dir []string
+		if dy < -0.15 {
+			dir = append(dir, "top")
+		} else if dy > 0.15 {
+			dir = append(dir, "bottom")
+		}
+		if dx < -0.15 {
+			dir = append(dir, "left")
+		} else if dx > 0.15 {
+			dir = append(dir, "right")
+		}
+		if len(dir) > 0 {
+			s += ", mostly to the " + strings.Join(dir, " ")
+		}
+		parts = append(parts, s)
+	}
+	switch edgeDensity := float64(edges) / float64(p.height); {
+	case edges == 0:
+	case edgeDensity > 20:
+		parts = append(parts, "the edge is very detailed")
+	case edgeDensity > 4:
+		parts = append(parts, "the edge is detailed")
+	default:
+		parts = append(parts, "the edge is smooth")
+	}
+	return strings.Join(parts, ", ") + "."
+}
+
+// describeView returns a concise description of the current view
+func describeView() string {
+	zoom := 2 / radius
+	s := fmt.Sprintf("Center %s, radius %g, zoom %.3gx, depth %d.", centerText(), radius, zoom, depth)
+	if params.julia {
+		s = fmt.Sprintf("Julia set of %g. ", params.c) + s
+	}
+	switch f := params.fractal; f.(type) {
+	case mandelbrotFractal:
+		// Goes without saying
+	case formulaFractal:
+		s = fmt.Sprintf("Formula %s. ", params.formula.text) + s
+	case hybridFractal:
+		s = fmt.Sprintf("Hybrid of pattern %s. ", params.hybrid) + s
+	default:
+		if fp := fractalParamOf(f); fp != nil {
+			s = fmt.Sprintf("%s of %s %g. ", f.Title(), fp.name, params.param) + s
+		} else {
+			s = f.Title() + ". " + s
+		}
+	}
+	if flameMode {
+		return s + " Showing a fractal flame."
+	}
+	switch buddhaMode {
+	case buddhabrotKind:
+		return s + " Showing the Buddhabrot."
+	case nebulabrotKind:
+		return s + " Showing the Nebulabrot."
+	case antiBuddhabrotKind:
+		return s + " Showing the anti-Buddhabrot."
+	}
+	if lastPlotCurrent() {
+		s += " " + describePlot()
+	}
+	return s
+}
+
+// writeDescription writes the description of the view for screen
+// readers if it has changed since it was last written
+func writeDescription() {
+	if !describing() || animating() {
+		return
+	}
+	if currentView() == lastDescribed && densityName() == lastDescribedMode {
+		return
+	}
+	lastDescribed, lastDescribedMode = currentView(), densityName()
+	text := describeView()
+	if textOnly() {
+		// Let the descriptions scroll up the screen
+		fmt.Fprintf(os.Stdout, "%s\r\n", text)
+		return
+	}
+	// Write on the bottom line of the screen which the image doesn't use
+	rows, cols, _, _, err := getTerminalSize()
+	if err != nil {
+		return
+	}
+	if r := []rune(text); len(r) > cols {
+		text = string(r[:cols])
+	}
+	fmt.Fprintf(os.Stdout, "\033[%d;1H\033[2K%s", rows, text)
+}
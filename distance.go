@@ -0,0 +1,27 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"math/cmplx"
+)
+
+// Distance from the set in pixels at which points get most of their
+// brightness back with distance coloring
+const distanceWidth = 1.0
+
+// distanceColor shades the smooth color of a point by its estimated
+// distance from the set, measured in pixels of size pixel, so the
+// filaments of the boundary, which are too thin for any pixel to land
+// on, still show up as crisp dark lines even at low depths
+func distanceColor(i int, z, dz complex128, maxDepth int, pixel float64) color.RGBA {
+	col := smoothColor(i, z, maxDepth)
+	if !needsDerivative() {
+		// Derivative not tracked for this fractal
+		return col
+	}
+	r := cmplx.Abs(z)
+	d := r * math.Log(r) / cmplx.Abs(dz)
+	shade(&col, math.Tanh(d/(distanceWidth*pixel)))
+	return col
+}
@@ -0,0 +1,90 @@
+package main
+
+import "math"
+
+// doubleDouble is a number held as the unevaluated sum of two
+// float64s, the low one less than an ulp of the high one, which gives
+// about 32 significant digits for not much more than the cost of
+// float64
+type doubleDouble struct {
+	hi, lo float64
+}
+
+// twoSum returns a+b and the rounding error of the sum
+func twoSum(a, b float64) (s, e float64) {
+	s = a + b
+	v := s - a
+	return s, (a - (s - v)) + (b - v)
+}
+
+// quickTwoSum returns a+b and the rounding error of the sum for
+// |a| >= |b|
+func quickTwoSum(a, b float64) (s, e float64) {
+	s = a + b
+	return s, b - (s - a)
+}
+
+// ddAdd returns a+b
+func ddAdd(a, b doubleDouble) doubleDouble {
+	s, e := twoSum(a.hi, b.hi)
+	t, f := twoSum(a.lo, b.lo)
+	e += t
+	s, e = quickTwoSum(s, e)
+	e += f
+	s, e = quickTwoSum(s, e)
+	return doubleDouble{s, e}
+}
+
+// ddSub returns a-b
+func ddSub(a, b doubleDouble) doubleDouble {
+	return ddAdd(a, doubleDouble{-b.hi, -b.lo})
+}
+
+// ddAddFloat returns a+b
+func ddAddFloat(a doubleDouble, b float64) doubleDouble {
+	s, e := twoSum(a.hi, b)
+	e += a.lo
+	s, e = quickTwoSum(s, e)
+	return doubleDouble{s, e}
+}
+
+// ddMul returns a*b
+func ddMul(a, b doubleDouble) doubleDouble {
+	p := a.hi * b.hi
+	e := math.FMA(a.hi, b.hi, -p)
+	e += a.hi*b.lo + a.lo*b.hi
+	p, e = quickTwoSum(p, e)
+	return doubleDouble{p, e}
+}
+
+// ddIterate iterates the point p, relative to origin, of the
+// Mandelbrot set in double-double, like mandelbrot does in float64
+//
+// The derivative only needs float64 so is worked out from the high
+// parts.
+func ddIterate(p complex128, maxDepth int) iteration {
+	var cx, cy doubleDouble
+	if origin != nil {
+		cx, cy = origin.ddRe, origin.ddIm
+	}
+	cx, cy = ddAddFloat(cx, real(p)), ddAddFloat(cy, imag(p))
+	var x, y doubleDouble
+	dz := complex(1, 0)
+	i := 0
+	for ; i < maxDepth; i++ {
+		x2, y2 := ddMul(x, x), ddMul(y, y)
+		if x2.hi+y2.hi >= 4 {
+			break
+		}
+		if i > 0 {
+			dz = 2 * complex(x.hi, y.hi) * dz
+			if real(dz)*real(dz)+imag(dz)*imag(dz) < interiorEpsilon {
+				return iteration{i: maxDepth, z: complex(x.hi, y.hi)}
+			}
+		}
+		xy := ddMul(x, y)
+		y = ddAdd(doubleDouble{2 * xy.hi, 2 * xy.lo}, cy)
+		x = ddAdd(ddSub(x2, y2), cx)
+	}
+	return iteration{i: i, z: complex(x.hi, y.hi)}
+}
@@ -0,0 +1,84 @@
+package main
+
+import "fmt"
+
+// Globals
+var (
+	dualPane      = false // set to show the Julia set under the mouse beside the set
+	paneJulia     complex128
+	sentPaneLines []uint64 // like sentLines for the Julia pane
+)
+
+// toggleDualPane splits the screen into the set on the left and the
+// Julia set of the point under the mouse on the right, or back
+func toggleDualPane() {
+	if !dualPane && !hasJulia(params.fractal) {
+		message = fmt.Sprintf(tr("The %s has no Julia sets"), tr(params.fractal.Title()))
+		return
+	}
+	dualPane = !dualPane
+	if dualPane && params.julia {
+		// The left pane is the set the Julia sets come from
+		toggleJulia(0)
+	}
+	paneJulia = absCenter()
+	// The panes are different sizes now so start afresh
+	clearImages()
+}
+
+// inJuliaPane returns true if the cell column x is in the Julia pane
+func inJuliaPane(x int) bool {
+	if !dualPane {
+		return false
+	}
+	_, _, _, cols, _, _ := getImageDimensions()
+	return x >= cols
+}
+
+// writeJuliaPane draws the Julia set of the point under the mouse, or
+// the last one if the mouse is over the Julia pane, to the right of
+// the set
+//
+// The left pane is the view as getImageDimensions shrinks it to half
+// the screen, and the Julia pane is the same size beside it. It is
+// left black if the left pane isn't showing a set with Julia sets.
+func writeJuliaPane() {
+	if !dualPane {
+		return
+	}
+	show := !params.julia && !densityMode() && hasJulia(params.fractal)
+	if !mouseSeen {
+		paneJulia = absCenter()
+	} else if !inJuliaPane(mouseX) {
+		paneJulia = absPoint(pointUnderMouse())
+	}
+	width, _, rows, cols, _, cellHeight := getImageDimensions()
+	// Draw at the resolution the set is being drawn at so animation
+	// keeps up
+	cellHeight = (cellHeight + renderScale - 1) / renderScale
+	width, height := width/renderScale, rows*cellHeight
+	jc, jr := juliaHome(params.fractal)
+	x0, y0, dx, dy := viewGrid(jc, jr, width, height)
+	data := make([]byte, 3*width*height)
+	c := paneJulia
+	forEachRow(height, func() bool { return !show }, func(y int) {
+		for x := 0; x < width; x++ {
+			it := escapeOrbit(iteration{z: complex(x0+dx*float64(x), y0+dy*float64(y)), dz: 1}, c, depth, true)
+			col := plotColor(it, depth, dx)
+			p := 3 * (y*width + x)
+			data[p], data[p+1], data[p+2] = col.R, col.G, col.B
+		}
+	})
+	rowSize := 3 * width
+	for h := 0; h < height; h += cellHeight {
+		row := h / cellHeight
+		fmt.Fprintf(screen, "\033[%d;%dH", row+1, cols+1)
+		sendRGBLine(&sentPaneLines, row, cols, data[h*rowSize:(h+cellHeight)*rowSize], width, cellHeight, cols)
+	}
+	fmt.Fprintf(screen, "\033[H")
+}
+
+// juliaPaneInfo describes the Julia pane for the info overlay
+func juliaPaneInfo() string {
+	return fmt.Sprintf(tr("• Julia pane of %.6g"), paneJulia)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Flags
+var (
+	eventsJSONFlag = flag.String("events-json", "", "Write a line of JSON for each navigation and render to this file, or fd:N for a file descriptor")
+)
+
+// Where the event stream goes, nil for nowhere
+var eventLog io.Writer
+
+// eventRecord is a line of the event stream
+type eventRecord struct {
+	Time     time.Time `json:"time"`
+	Event    string    `json:"event"`            // "navigate" or "render"
+	Action   string    `json:"action,omitempty"` // the input which caused a navigation
+	Re       float64   `json:"re"`               // center of the view
+	Im       float64   `json:"im"`
+	Radius   float64   `json:"radius"`
+	Depth    int       `json:"depth"`
+	Mode     string    `json:"mode"`               // "mandelbrot", "julia" or "flame"
+	Fractal  string    `json:"fractal,omitempty"`  // name of the fractal iterated, eg "burning-ship"
+	Param    float64   `json:"param,omitempty"`    // parameter of the fractal, eg the exponent of the Multibrot set
+	Formula  string    `json:"formula,omitempty"`  // formula of the formula fractal
+	Hybrid   string    `json:"hybrid,omitempty"`   // pattern of the hybrid fractal
+	JuliaRe  float64   `json:"julia_re,omitempty"` // parameter of the Julia set
+	JuliaIm  float64   `json:"julia_im,omitempty"`
+	RenderMs float64   `json:"render_ms,omitempty"` // time the render took
+	Width    int       `json:"width,omitempty"`     // size of the render in pixels
+	Height   int       `json:"height,omitempty"`
+}
+
+// openEventLog opens the destination of --events-json if set
+func openEventLog() error {
+	dest := *eventsJSONFlag
+	switch {
+	case dest == "":
+		return nil
+	case strings.HasPrefix(dest, "fd:"):
+		fd, err := strconv.Atoi(dest[3:])
+		if err != nil || fd < 0 {
+			return fmt.Errorf("bad file descriptor %q", dest)
+		}
+		eventLog = os.NewFile(uintptr(fd), dest)
+	default:
+		f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+		if err != nil {
+			return err
+		}
+		eventLog = f
+	}
+	return nil
+}
+
+// logEvent writes a record of the current view to the event stream
+func logEvent(r eventRecord) {
+	if eventLog == nil {
+		return
+	}
+	r.Time = time.Now()
+	r.Re, r.Im = real(absCenter()), imag(absCenter())
+	r.Radius = radius
+	r.Depth = depth
+	r.Mode = "mandelbrot"
+	if !densityMode() {
+		r.Fractal = params.fractal.Name()
+		r.Param = params.param
+		r.Formula = formulaText(params)
+		r.Hybrid = params.hybrid
+	}
+	if densityMode() {
+		r.Mode = densityName()
+	} else if params.julia {
+		r.Mode = "julia"
+		r.JuliaRe, r.JuliaIm = real(params.c), imag(params.c)
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	// Ignore errors so a reader going away doesn't stop the exploring
+	_, _ = eventLog.Write(append(data, '\n'))
+}
+
+// Names of the special keys in the event stream
+var keyNames = map[termbox.Key]string{
+	termbox.KeyArrowUp:    "up",
+	termbox.KeyArrowDown:  "down",
+	termbox.KeyArrowLeft:  "left",
+	termbox.KeyArrowRight: "right",
+	termbox.KeyPgup:       "pgup",
+	termbox.KeyPgdn:       "pgdn",
+	termbox.KeyBackspace:  "backspace",
+	termbox.KeyBackspace2: "backspace",
+	termbox.KeyEnter:      "enter",
+	termbox.KeyEsc:        "esc",
+	termbox.KeySpace:      "space",
+}
+
+// actionName returns a short name for the input in ev
+func actionName(ev termbox.Event) string {
+	switch ev.Type {
+	case termbox.EventKey:
+		if ev.Ch != 0 {
+			return "key:" + string(ev.Ch)
+		}
+		if name, ok := keyNames[ev.Key]; ok {
+			return "key:" + name
+		}
+		return fmt.Sprintf("key:0x%04x", uint16(ev.Key))
+	case termbox.EventMouse:
+		switch ev.Key {
+		case termbox.MouseLeft:
+			if ev.Mod&termbox.ModMotion != 0 {
+				return "mouse:drag"
+			}
+			return "mouse:left"
+		case termbox.MouseRight:
+			return "mouse:right"
+		case termbox.MouseMiddle:
+			return "mouse:middle"
+		case termbox.MouseRelease:
+			return "mouse:release"
+		case termbox.MouseWheelUp:
+			return "mouse:wheel-up"
+		case termbox.MouseWheelDown:
+			return "mouse:wheel-down"
+		}
+		return "mouse"
+	case termbox.EventResize:
+		return "resize"
+	case eventClick:
+		return "mouse:click"
+	case eventCommand:
+		return "command"
+	case eventFocusIn:
+		return "focus-in"
+	case eventFocusOut:
+		return "focus-out"
+	}
+	return "other"
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Constants
+const (
+	// Number of points tried when looking for somewhere to zoom into
+	exploreSamples = 200
+
+	// How often a new place to zoom into is chosen
+	exploreRetarget = 2 * time.Second
+
+	// Time constant for gliding the center towards the target
+	exploreGlide = time.Second
+
+	// Start again from the whole set below this radius as float64
+	// runs out of precision
+	exploreMinRadius = 1e-12
+)
+
+// Globals
+var (
+	exploring     = false    // set if auto-explore is engaged
+	exploreTarget complex128 // the point being zoomed into
+	lastRetarget  time.Time  // when exploreTarget was last chosen
+)
+
+// toggleExplore starts or stops exploring the set automatically
+func toggleExplore() {
+	exploring = !exploring
+	if exploring {
+		if !animating() {
+			lastTick = time.Now()
+		}
+		exploreTarget = center
+		if !retarget() {
+			restartExplore()
+		}
+	}
+}
+
+// retarget chooses a new point near the middle of the view to zoom
+// into, returning false if there is nowhere interesting left
+//
+// Of a random sample of points the one taking the most iterations to
+// escape is chosen as that is on the edge of the set where all the
+// detail is.
+func retarget() bool {
+	lastRetarget = time.Now()
+	best, bestI := exploreTarget, 0
+	for k := 0; k < exploreSamples; k++ {
+		p := exploreTarget + complex(radius*(rand.Float64()-0.5), radius*(rand.Float64()-0.5))
+		i := iterate(p, depth).i
+		if i < depth && i > bestI {
+			best, bestI = p, i
+		}
+	}
+	// Everything inside the set or a long way from it
+	if bestI < 8 {
+		return false
+	}
+	exploreTarget = best
+	return true
+}
+
+// restartExplore goes back to the whole set to explore somewhere else
+func restartExplore() {
+	reset()
+	exploreTarget = center
+	retarget()
+}
+
+// exploreStep moves the view on by dt towards the point being
+// explored, choosing new points as it zooms in
+func exploreStep(dt time.Duration) {
+	if radius < exploreMinRadius || time.Since(lastRetarget) > exploreRetarget && !retarget() {
+		restartExplore()
+	}
+	center += (exploreTarget - center) * complex(1-math.Exp(-float64(dt)/float64(exploreGlide)), 0)
+	radius *= math.Exp2(-math.Abs(*flyRate) * dt.Seconds())
+	// Deeper zooms need more iterations to show the detail
+	depth = autoDepthFor(radius)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"math/cmplx"
+)
+
+// stepOrbit carries on iterating it with c until it escapes,
+// converges or reaches maxDepth iterations, adding up the terms for
+// exponential coloring over the orbit
+//
+// The fractal's own iteration is called one step at a time so this
+// works for any fractal which doesn't need the history of z, though
+// slower than iterating it straight through. Points which escape add
+// exp(-|z|) and points which converge add exp(-1/|step|) so both end
+// up with a sum which grows smoothly with how long they took.
+func stepOrbit(it iteration, c complex128, maxDepth int) iteration {
+	converges := fractalConverges(params.fractal)
+	for it.i < maxDepth {
+		i, z := escape(it.z, c, it.i, it.i+1)
+		if i == it.i {
+			// Escaped, or converged with z the last step
+			if converges {
+				it.sum += math.Exp(-1 / cmplx.Abs(z))
+				it.z = z
+			}
+			break
+		}
+		if converges {
+			it.sum += math.Exp(-1 / cmplx.Abs(z-it.z))
+		} else {
+			it.sum += math.Exp(-cmplx.Abs(z))
+		}
+		it.i, it.z = i, z
+	}
+	return it
+}
+
+// expColor maps the sum of the exponential terms over the orbit of a
+// point to the gradient like the smooth iteration count, which it
+// follows closely without needing to know the escape radius or the
+// power of the fractal, and works the same for points which converge
+func expColor(i int, z complex128, sum float64, maxDepth int) color.RGBA {
+	if neededSum() != expSum {
+		// Sum not tracked for this fractal
+		return smoothColor(i, z, maxDepth)
+	}
+	return decomposeColor(paletteColor(sum/float64(maxDepth)), z)
+}
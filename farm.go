@@ -0,0 +1,501 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Flags
+var (
+	farmFlag        = flag.String("farm", "", "Comma separated host:port of machines running termbrot --serve-worker to share the calculation with")
+	serveWorkerFlag = flag.String("serve-worker", "", "Serve calculations to termbrot --farm on this address instead of drawing anything, eg :7070 for this machine only or 0.0.0.0:7070 for any")
+	farmSecretFlag  = flag.String("farm-secret", "", "Secret termbrot --farm must know to use a termbrot --serve-worker, set the same on both")
+)
+
+// Farm settings
+const (
+	// How long to wait to connect to a farm worker
+	farmDialTimeout = 5 * time.Second
+
+	// Tiles sent to a farm worker at once for each of its workers,
+	// enough to keep it busy over the round trips
+	farmTilesPerWorker = 2
+
+	// How long a farm worker gets to send a tile back before it is
+	// given up on and the tile is worked out locally
+	farmTileTimeout = time.Minute
+
+	// Largest tile a farm worker works out, in pixels, far more than
+	// a row of any frame
+	farmMaxPixels = 1 << 20
+
+	// Deepest tile a farm worker works out, as the reference orbit of
+	// a deep zoom takes memory for each iteration
+	farmMaxDepth = 1 << 24
+)
+
+// Globals
+var (
+	farmWorkers []string     // the --farm workers connected
+	farmConns   int64        // connections to them
+	farmLost    atomic.Int64 // connections to them lost
+)
+
+// farmChallenge is sent by a farm worker when something connects to
+// it, to be answered with a farmAuth
+type farmChallenge struct {
+	Nonce []byte
+}
+
+// farmAuth answers a farmChallenge with the nonce signed with the
+// --farm-secret
+type farmAuth struct {
+	MAC []byte
+}
+
+// farmHello is sent by a farm worker once the farmAuth is checked
+type farmHello struct {
+	Workers int    // calculations it runs at once
+	Err     string // why it won't work for the connection if it won't
+}
+
+// farmSettings is everything the calculation depends on apart from
+// the piece of the frame worked out, so a farm worker can work it out
+// the same way
+type farmSettings struct {
+	Fractal        string
+	Param          float64
+	Formula        string
+	Hybrid         string
+	Julia          bool
+	C              complex128
+	Sequence       []bool
+	OriginRe       *big.Float // origin if there is one
+	OriginIm       *big.Float
+	Center         complex128
+	Radius         float64
+	Kernel         kernel
+	PeriodDistance float64
+	Derivative     bool
+	Sum            orbitSum
+	Interior       interiorMode
+}
+
+// farmTile is a piece of a frame sent to a farm worker
+//
+// Subdivided tiles are Width x Height pixels starting at pixel Left,
+// Top of the grid from X0, Y0 with only the pixels not set in Known
+// worked out. The others are Width pixels of the row from X0, Y0 as
+// in calculateMandlebrotRectangle.
+type farmTile struct {
+	Settings  farmSettings
+	Subdivide bool
+	X0, Y0    float64
+	Dx, Dy    float64
+	Left, Top int
+	Width     int
+	Height    int
+	MaxDepth  int
+	Known     []bool
+}
+
+// farmIteration is an iteration sent back by a farm worker
+type farmIteration struct {
+	I      int
+	Z, DZ  complex128
+	Sum    float64
+	Filled bool
+}
+
+// farmResult is the results of a tile sent back by a farm worker
+type farmResult struct {
+	Iters []farmIteration
+	Err   string
+}
+
+// farmJob is a job a farm worker can do instead of a local worker
+type farmJob struct {
+	tile  farmTile
+	store func(its []farmIteration) // stores the results
+}
+
+// farming returns true if the calculation is shared with farm workers
+func farming() bool {
+	return farmConns > farmLost.Load()
+}
+
+// checkFarmFlag connects to the --farm workers
+//
+// Each worker gets enough connections to keep it busy, each of which
+// takes tiles from the worker pool like a local worker.
+func checkFarmFlag() error {
+	if *farmFlag == "" {
+		return nil
+	}
+	for _, addr := range strings.Split(*farmFlag, ",") {
+		conn, hello, err := dialFarm(addr)
+		if err != nil {
+			return fmt.Errorf("--farm %s: %w", addr, err)
+		}
+		farmWorkers = append(farmWorkers, addr)
+		conns := []net.Conn{conn}
+		for len(conns) < farmTilesPerWorker*hello.Workers {
+			conn, _, err := dialFarm(addr)
+			if err != nil {
+				return fmt.Errorf("--farm %s: %w", addr, err)
+			}
+			conns = append(conns, conn)
+		}
+		for _, conn := range conns {
+			farmConns++
+			pool.addRemote()
+			go runFarmConn(conn)
+		}
+	}
+	return nil
+}
+
+// dialFarm connects to the farm worker at addr
+//
+// Each message of the handshake is sent with an encoder of its own,
+// and neither end sends the next until it has read the last.
+func dialFarm(addr string) (net.Conn, farmHello, error) {
+	var challenge farmChallenge
+	var hello farmHello
+	conn, err := net.DialTimeout("tcp", addr, farmDialTimeout)
+	if err != nil {
+		return nil, hello, err
+	}
+	_ = conn.SetDeadline(time.Now().Add(farmDialTimeout))
+	if err := gob.NewDecoder(conn).Decode(&challenge); err != nil {
+		conn.Close()
+		return nil, hello, fmt.Errorf("not a termbrot --serve-worker: %w", err)
+	}
+	err = gob.NewEncoder(conn).Encode(farmAuth{MAC: farmMAC(challenge.Nonce)})
+	if err == nil {
+		err = gob.NewDecoder(conn).Decode(&hello)
+	}
+	if err == nil && hello.Err != "" {
+		err = errors.New(hello.Err)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, hello, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return conn, hello, nil
+}
+
+// farmMAC returns nonce signed with the --farm-secret
+func farmMAC(nonce []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(*farmSecretFlag))
+	mac.Write(nonce)
+	return mac.Sum(nil)
+}
+
+// runFarmConn sends the pool's tiles to a farm worker over conn
+//
+// If the connection fails or the farm worker takes longer than
+// farmTileTimeout the tile is worked out here instead, and the
+// connection is given up on.
+func runFarmConn(conn net.Conn) {
+	defer conn.Close()
+	// The hello was read with a decoder of its own, and the worker
+	// sends nothing else until asked
+	enc, dec := gob.NewEncoder(conn), gob.NewDecoder(conn)
+	for {
+		j := pool.takeFarm()
+		var res farmResult
+		_ = conn.SetDeadline(time.Now().Add(farmTileTimeout))
+		err := enc.Encode(&j.farm.tile)
+		if err == nil {
+			err = dec.Decode(&res)
+		}
+		if err == nil && res.Err != "" {
+			err = errors.New(res.Err)
+		}
+		if err != nil {
+			farmLost.Add(1)
+			j.fn(-1)
+			j.finish()
+			return
+		}
+		j.farm.store(res.Iters)
+		j.finish()
+	}
+}
+
+// newFarmJob returns the farm job of working out the tile t, or nil
+// if there are no farm workers
+//
+// The rest of the tile is filled in from the settings now, so it must
+// be called before the frame changes them.
+func newFarmJob(t farmTile, store func(its []farmIteration)) *farmJob {
+	if !farming() {
+		return nil
+	}
+	t.Settings = currentFarmSettings()
+	return &farmJob{tile: t, store: store}
+}
+
+// currentFarmSettings returns the settings the calculation is using
+func currentFarmSettings() farmSettings {
+	s := farmSettings{
+		Fractal:        params.fractal.Name(),
+		Param:          params.param,
+		Formula:        formulaText(params),
+		Hybrid:         params.hybrid,
+		Julia:          params.julia,
+		C:              params.c,
+		Sequence:       sequence,
+		Center:         center,
+		Radius:         radius,
+		Kernel:         currentKernel(),
+		PeriodDistance: periodDistance,
+		Derivative:     needsDerivative(),
+		Sum:            neededSum(),
+		Interior:       interior,
+	}
+	if origin != nil {
+		s.OriginRe, s.OriginIm = origin.re, origin.im
+	}
+	return s
+}
+
+// apply sets up the calculation to use the settings s
+//
+// The coloring is set so it needs what s says is needed.
+func (s *farmSettings) apply() error {
+	fractal, err := fractalByName(s.Fractal)
+	if err != nil {
+		return err
+	}
+	var f *formula
+	if _, ok := fractal.(formulaFractal); ok {
+		f, err = parseFormula(s.Formula)
+		if err != nil {
+			return err
+		}
+	}
+	if len(s.Sequence) == 0 {
+		return errors.New("no sequence")
+	}
+	params = fractalParams{fractal: fractal, param: s.Param, formula: f, hybrid: s.Hybrid, julia: s.Julia, c: s.C}
+	sequence = s.Sequence
+	origin = nil
+	if s.OriginRe != nil && s.OriginIm != nil {
+		origin = &deepPoint{re: s.OriginRe, im: s.OriginIm}
+		origin.round()
+	}
+	center, radius = s.Center, s.Radius
+	frameKernel, periodDistance = s.Kernel, s.PeriodDistance
+	layers, lighting, coloring = nil, s.Derivative, smoothColoring
+	switch s.Sum {
+	case stripeSum:
+		coloring = stripeColoring
+	case expSum:
+		coloring = expColoring
+	}
+	interior = s.Interior
+	return nil
+}
+
+// toFarm converts iterations to send back from a farm worker
+func toFarm(its []iteration) []farmIteration {
+	out := make([]farmIteration, len(its))
+	for k, it := range its {
+		out[k] = farmIteration{I: it.i, Z: it.z, DZ: it.dz, Sum: it.sum, Filled: it.filled}
+	}
+	return out
+}
+
+// fromFarm converts an iteration sent back by a farm worker
+func fromFarm(it farmIteration) iteration {
+	return iteration{i: it.I, z: it.Z, dz: it.DZ, sum: it.Sum, filled: it.Filled}
+}
+
+// farmInfo describes the farm workers for the info overlay
+func farmInfo() string {
+	return fmt.Sprintf(tr("• Farming out to %s, %d of %d connections working"), strings.Join(farmWorkers, ", "), farmConns-farmLost.Load(), farmConns)
+}
+
+// The settings the farm worker is using and the tiles it is working
+// out with them, guarded by tileMu
+var (
+	tileMu       sync.Mutex
+	tileIdle     = sync.NewCond(&tileMu)
+	tileSettings []byte
+	tilesRunning int
+)
+
+// serveWorker works out tiles for termbrot --farm on the
+// --serve-worker address until it fails
+//
+// An address without a host is served on localhost only, so serving
+// other machines has to be asked for.
+func serveWorker() error {
+	host, port, err := net.SplitHostPort(*serveWorkerFlag)
+	if err != nil {
+		return fmt.Errorf("--serve-worker: %w", err)
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	l, err := net.Listen("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Serving calculations with %d workers on %s\n", work.workers, l.Addr())
+	if *farmSecretFlag == "" && !l.Addr().(*net.TCPAddr).IP.IsLoopback() {
+		fmt.Printf("Warning: anyone who can connect to %s can use it - set --farm-secret to stop them\n", l.Addr())
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go serveFarmConn(conn)
+	}
+}
+
+// serveFarmConn works out the tiles sent over conn once it has
+// checked the --farm-secret
+func serveFarmConn(conn net.Conn) {
+	defer conn.Close()
+	nonce := make([]byte, sha256.Size)
+	if _, err := rand.Read(nonce); err != nil {
+		return
+	}
+	var auth farmAuth
+	_ = conn.SetDeadline(time.Now().Add(farmDialTimeout))
+	if err := gob.NewEncoder(conn).Encode(farmChallenge{Nonce: nonce}); err != nil {
+		return
+	}
+	if err := gob.NewDecoder(conn).Decode(&auth); err != nil {
+		return
+	}
+	hello := farmHello{Workers: work.workers}
+	if *farmSecretFlag != "" && !hmac.Equal(auth.MAC, farmMAC(nonce)) {
+		fmt.Printf("Refusing %s: wrong --farm-secret\n", conn.RemoteAddr())
+		hello = farmHello{Err: "wrong --farm-secret"}
+	}
+	if err := gob.NewEncoder(conn).Encode(hello); err != nil || hello.Err != "" {
+		return
+	}
+	_ = conn.SetDeadline(time.Time{})
+	enc, dec := gob.NewEncoder(conn), gob.NewDecoder(conn)
+	for {
+		var t farmTile
+		err := dec.Decode(&t)
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			fmt.Printf("Error reading tile from %s: %v\n", conn.RemoteAddr(), err)
+			return
+		}
+		res := calculateTile(&t)
+		_ = conn.SetWriteDeadline(time.Now().Add(farmTileTimeout))
+		if err := enc.Encode(&res); err != nil {
+			fmt.Printf("Error sending tile to %s: %v\n", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+// check returns an error if the tile t can't be worked out, before
+// anything is made for it, as it comes from anything which connects
+func (t *farmTile) check() error {
+	pixels := t.Width
+	if t.Subdivide {
+		if t.Height < 1 || t.Height > farmMaxPixels {
+			return fmt.Errorf("bad tile height %d", t.Height)
+		}
+		pixels *= t.Height
+	}
+	switch {
+	case t.Width < 1 || t.Width > farmMaxPixels || pixels > farmMaxPixels:
+		return fmt.Errorf("bad tile size %d x %d", t.Width, t.Height)
+	case t.MaxDepth < 1 || t.MaxDepth > farmMaxDepth:
+		return fmt.Errorf("bad tile depth %d", t.MaxDepth)
+	case t.Subdivide && len(t.Known) != pixels:
+		return fmt.Errorf("bad tile: %d known for %d pixels", len(t.Known), pixels)
+	}
+	return nil
+}
+
+// calculateTile works out the tile t
+//
+// The settings are globals, so tiles with the same settings are
+// worked out at once but one with different settings waits for the
+// others to finish first. A panic working it out is sent back as the
+// error rather than taking the farm worker down.
+func calculateTile(t *farmTile) (res farmResult) {
+	if err := t.check(); err != nil {
+		return farmResult{Err: err.Error()}
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			res = farmResult{Err: fmt.Sprintf("tile failed: %v", r)}
+		}
+	}()
+	if err := startTile(&t.Settings); err != nil {
+		return farmResult{Err: err.Error()}
+	}
+	defer func() {
+		tileMu.Lock()
+		tilesRunning--
+		tileIdle.Broadcast()
+		tileMu.Unlock()
+	}()
+
+	its := make([]iteration, t.Width*max(1, t.Height))
+	if !t.Subdivide {
+		calculateMandlebrotRectangle(t.X0, t.Y0, t.Dx, t.Width, t.MaxDepth, its)
+		return farmResult{Iters: toFarm(its)}
+	}
+	var wg sync.WaitGroup
+	s := &subdivision{x0: t.X0, y0: t.Y0, dx: t.Dx, dy: t.Dy, width: t.Width, left: t.Left, top: t.Top, maxDepth: t.MaxDepth, iters: its, known: slices.Clone(t.Known), worker: -1, wg: &wg}
+	s.rect(0, 0, t.Width, t.Height)
+	wg.Wait()
+	return farmResult{Iters: toFarm(its)}
+}
+
+// startTile waits until the settings s can be used then counts a tile
+// as running with them, applying them if they aren't already
+func startTile(s *farmSettings) error {
+	var key bytes.Buffer
+	if err := gob.NewEncoder(&key).Encode(s); err != nil {
+		return err
+	}
+	tileMu.Lock()
+	defer tileMu.Unlock()
+	for tilesRunning > 0 && !bytes.Equal(key.Bytes(), tileSettings) {
+		tileIdle.Wait()
+	}
+	if !bytes.Equal(key.Bytes(), tileSettings) {
+		// Cleared first so settings which fail part way through
+		// are applied again by the next tile
+		tileSettings = nil
+		if err := s.apply(); err != nil {
+			return err
+		}
+		tileSettings = key.Bytes()
+	}
+	tilesRunning++
+	return nil
+}
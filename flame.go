@@ -0,0 +1,290 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"math/rand"
+	"runtime"
+	"sync"
+)
+
+// Globals
+var (
+	flameMode = false
+	flame     *flameRenderer
+	flameDef  []flameXform
+)
+
+// Variations applied after the affine part of each transform
+const (
+	varLinear = iota
+	varSinusoidal
+	varSpherical
+	varSwirl
+	varHorseshoe
+	varPolar
+	varHeart
+	varDisc
+	numVariations
+)
+
+// Number of chaos game points each worker plots before taking the lock
+const flameBatch = 4096
+
+// Number of colors of the gradient the flame's color coordinates are
+// looked up in
+const flameColors = 1024
+
+// flameXform is one function of the iterated function system
+type flameXform struct {
+	weight     float64 // relative probability of choosing this transform
+	a, b, c    float64 // x' = a*x + b*y + c
+	d, e, f    float64 // y' = d*x + e*y + f
+	color      float64 // color coordinate in [0, 1]
+	variations [numVariations]float64
+}
+
+// apply the transform to the point
+func (xf *flameXform) apply(x, y float64) (float64, float64) {
+	tx := xf.a*x + xf.b*y + xf.c
+	ty := xf.d*x + xf.e*y + xf.f
+	r2 := tx*tx + ty*ty
+	r := math.Sqrt(r2)
+	theta := math.Atan2(tx, ty)
+	var nx, ny float64
+	for v, w := range xf.variations {
+		if w == 0 {
+			continue
+		}
+		var vx, vy float64
+		switch v {
+		case varLinear:
+			vx, vy = tx, ty
+		case varSinusoidal:
+			vx, vy = math.Sin(tx), math.Sin(ty)
+		case varSpherical:
+			vx, vy = tx/(r2+1e-10), ty/(r2+1e-10)
+		case varSwirl:
+			s, c := math.Sincos(r2)
+			vx, vy = tx*s-ty*c, tx*c+ty*s
+		case varHorseshoe:
+			vx, vy = (tx-ty)*(tx+ty)/(r+1e-10), 2*tx*ty/(r+1e-10)
+		case varPolar:
+			vx, vy = theta/math.Pi, r-1
+		case varHeart:
+			s, c := math.Sincos(theta * r)
+			vx, vy = r*s, -r*c
+		case varDisc:
+			s, c := math.Sincos(math.Pi * r)
+			vx, vy = theta/math.Pi*s, theta/math.Pi*c
+		}
+		nx += w * vx
+		ny += w * vy
+	}
+	return nx, ny
+}
+
+// newFlame makes a new random flame and restarts the renderer
+func newFlame() {
+	stopFlame()
+	n := 3 + rand.Intn(3)
+	flameDef = make([]flameXform, n)
+	for i := range flameDef {
+		xf := &flameDef[i]
+		xf.weight = 0.2 + rand.Float64()
+		xf.a, xf.b, xf.c = 2*rand.Float64()-1, 2*rand.Float64()-1, 2*rand.Float64()-1
+		xf.d, xf.e, xf.f = 2*rand.Float64()-1, 2*rand.Float64()-1, 2*rand.Float64()-1
+		xf.color = rand.Float64()
+		// Mix one or two variations
+		v1, v2 := rand.Intn(numVariations), rand.Intn(numVariations)
+		w := rand.Float64()
+		xf.variations[v1] += w
+		xf.variations[v2] += 1 - w
+	}
+}
+
+// flameRenderer accumulates the density of the chaos game on a flame
+// into a histogram, which is tone mapped for display.
+//
+// Rendering is progressive - the workers keep running in the
+// background and the image sharpens the longer it is left. The view
+// and the colors are copied when it starts so the workers don't read
+// the globals the main goroutine changes.
+type flameRenderer struct {
+	mu       sync.Mutex
+	width    int
+	height   int
+	center   complex128
+	radius   float64
+	dx, dy   float64                 // size of a pixel in set co-ordinates
+	coloring int                     // coloringID the colors were made for
+	offset   float64                 // paletteOffset the colors were made for
+	colors   [flameColors]color.RGBA // the gradient from color coordinate 0 to 1
+	hits     []float32               // number of points landing on each pixel
+	rgb      []float32               // sum of colors landing on each pixel
+	samples  int                     // number of points plotted
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// newFlameRenderer starts a renderer for the current view
+func newFlameRenderer(width, height int) *flameRenderer {
+	fr := &flameRenderer{
+		width:    width,
+		height:   height,
+		center:   absCenter(),
+		radius:   radius,
+		coloring: coloringID,
+		offset:   paletteOffset,
+		hits:     make([]float32, width*height),
+		rgb:      make([]float32, 3*width*height),
+		stop:     make(chan struct{}),
+	}
+	fr.dx, fr.dy = getSetSize(width, height)
+	for i := range fr.colors {
+		fr.colors[i] = gradientColor(float64(i) / (flameColors - 1))
+	}
+	for i := 0; i < runtime.NumCPU(); i++ {
+		fr.wg.Add(1)
+		go fr.worker(rand.Int63())
+	}
+	return fr
+}
+
+// worker plays the chaos game until stopped
+func (fr *flameRenderer) worker(seed int64) {
+	defer fr.wg.Done()
+	rng := rand.New(rand.NewSource(seed))
+	dx, dy := fr.dx, fr.dy
+	x0 := real(fr.center) - dx*float64(fr.width/2)
+	y0 := imag(fr.center) - dy*float64(fr.height/2)
+
+	var totalWeight float64
+	for i := range flameDef {
+		totalWeight += flameDef[i].weight
+	}
+
+	type point struct {
+		p   int
+		col float64
+	}
+	batch := make([]point, 0, flameBatch)
+	x, y, col := 2*rng.Float64()-1, 2*rng.Float64()-1, rng.Float64()
+	skip := 20
+	for {
+		select {
+		case <-fr.stop:
+			return
+		default:
+		}
+		batch = batch[:0]
+		for n := 0; n < flameBatch; n++ {
+			// Choose a transform according to the weights
+			w := rng.Float64() * totalWeight
+			xf := &flameDef[0]
+			for i := range flameDef {
+				xf = &flameDef[i]
+				w -= xf.weight
+				if w < 0 {
+					break
+				}
+			}
+			x, y = xf.apply(x, y)
+			col = (col + xf.color) / 2
+			if math.IsNaN(x) || math.IsNaN(y) || math.Abs(x) > 1e10 || math.Abs(y) > 1e10 {
+				// Diverged so restart the orbit
+				x, y, col = 2*rng.Float64()-1, 2*rng.Float64()-1, rng.Float64()
+				skip = 20
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			px := int((x - x0) / dx)
+			py := int((y - y0) / dy)
+			if px < 0 || px >= fr.width || py < 0 || py >= fr.height {
+				continue
+			}
+			batch = append(batch, point{p: py*fr.width + px, col: col})
+		}
+		fr.mu.Lock()
+		for _, pt := range batch {
+			c := fr.colors[int(pt.col*(flameColors-1)+0.5)]
+			fr.hits[pt.p]++
+			fr.rgb[3*pt.p+0] += float32(c.R)
+			fr.rgb[3*pt.p+1] += float32(c.G)
+			fr.rgb[3*pt.p+2] += float32(c.B)
+		}
+		fr.samples += len(batch)
+		fr.mu.Unlock()
+	}
+}
+
+// toneMap converts the histogram into RGB using log-density scaling
+func (fr *flameRenderer) toneMap() []byte {
+	const gamma = 2.2
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	var maxHits float32
+	for _, h := range fr.hits {
+		if h > maxHits {
+			maxHits = h
+		}
+	}
+	data := make([]byte, 3*len(fr.hits))
+	if maxHits == 0 {
+		return data
+	}
+	logMax := math.Log1p(float64(maxHits))
+	for p, h := range fr.hits {
+		if h == 0 {
+			continue
+		}
+		alpha := math.Log1p(float64(h)) / logMax
+		scale := math.Pow(alpha, 1/gamma) / float64(h)
+		for k := 0; k < 3; k++ {
+			data[3*p+k] = uint8(math.Min(float64(fr.rgb[3*p+k])*scale, 255))
+		}
+	}
+	return data
+}
+
+// shutdown stops the workers and waits for them to finish
+func (fr *flameRenderer) shutdown() {
+	close(fr.stop)
+	fr.wg.Wait()
+}
+
+// stopFlame stops any running flame renderer
+func stopFlame() {
+	if flame != nil {
+		flame.shutdown()
+		flame = nil
+	}
+}
+
+// flameSamples returns the number of points plotted so far
+func flameSamples() int {
+	if flame == nil {
+		return 0
+	}
+	flame.mu.Lock()
+	defer flame.mu.Unlock()
+	return flame.samples
+}
+
+// writeFlame displays the current state of the fractal flame,
+// (re)starting the renderer if the view has changed
+func writeFlame() {
+	width, height, _, cols, _, cellHeight := getImageDimensions()
+	imgWidth, imgHeight = width, height
+	if flameDef == nil {
+		newFlame()
+	}
+	if flame == nil || flame.width != width || flame.height != height || flame.center != absCenter() || flame.radius != radius || flame.coloring != coloringID || flame.offset != paletteOffset {
+		stopFlame()
+		flame = newFlameRenderer(width, height)
+	}
+	writeRGBFrame(flame.toneMap(), width, height, cols, cellHeight)
+}
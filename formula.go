@@ -0,0 +1,423 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Flags
+var (
+	formulaFlag = flag.String("formula", "", "Iterate this formula of z and c, eg \"z^3 + c*z + c\"")
+)
+
+func init() {
+	registerFractal(130, formulaFractal{})
+}
+
+// formulaFractal is the fractal of a formula given by the user
+type formulaFractal struct{}
+
+func (formulaFractal) Name() string                       { return "formula" }
+func (formulaFractal) Title() string                      { return "Formula" }
+func (formulaFractal) DefaultView() (complex128, float64) { return 0, 2 }
+
+func (formulaFractal) Iterate(z, c complex128, i, maxDepth int) (int, complex128) {
+	return params.formula.iterate(z, c, i, maxDepth)
+}
+
+// The formula iterated when the formula fractal is chosen
+var userFormula = mustParseFormula("z^2 + c")
+
+// formula is a compiled user defined iteration
+type formula struct {
+	text string                           // the formula as written
+	eval func(z, c complex128) complex128 // works out the next z
+}
+
+// The functions which can be used in formulas
+var formulaFuncs = map[string]func(complex128) complex128{
+	"sin":  cmplx.Sin,
+	"cos":  cmplx.Cos,
+	"tan":  cmplx.Tan,
+	"sinh": cmplx.Sinh,
+	"cosh": cmplx.Cosh,
+	"tanh": cmplx.Tanh,
+	"exp":  cmplx.Exp,
+	"log":  cmplx.Log,
+	"sqrt": cmplx.Sqrt,
+	"conj": cmplx.Conj,
+	"abs":  func(z complex128) complex128 { return complex(cmplx.Abs(z), 0) },
+	"re":   func(z complex128) complex128 { return complex(real(z), 0) },
+	"im":   func(z complex128) complex128 { return complex(imag(z), 0) },
+}
+
+// The constants which can be used in formulas
+var formulaConsts = map[string]complex128{
+	"i":  1i,
+	"pi": math.Pi,
+	"e":  math.E,
+}
+
+// checkFormulaFlag compiles --formula and switches to it if set
+func checkFormulaFlag() error {
+	if *formulaFlag == "" {
+		return nil
+	}
+	f, err := parseFormula(*formulaFlag)
+	if err != nil {
+		return fmt.Errorf("--formula: %w", err)
+	}
+	userFormula = f
+	setFractal(formulaFractal{})
+	return nil
+}
+
+// setFormula compiles text and iterates it, staying at the same view
+// if the formula fractal is showing already
+func setFormula(text string) error {
+	f, err := parseFormula(text)
+	if err != nil {
+		return err
+	}
+	userFormula = f
+	if _, ok := params.fractal.(formulaFractal); !ok {
+		setFractal(formulaFractal{})
+	}
+	params.formula = f
+	return nil
+}
+
+// formulaText returns the formula of the parameters, or "" if they
+// aren't of the formula fractal
+func formulaText(p fractalParams) string {
+	if p.formula == nil {
+		return ""
+	}
+	return p.formula.text
+}
+
+// iterate iterates the formula from iteration i until z escapes or
+// it reaches maxDepth iterations
+func (f *formula) iterate(z, c complex128, i, maxDepth int) (int, complex128) {
+	for ; i < maxDepth; i++ {
+		if real(z)*real(z)+imag(z)*imag(z) >= escapeRadius*escapeRadius {
+			break
+		}
+		z = f.eval(z, c)
+	}
+	return i, z
+}
+
+// mustParseFormula compiles a formula which is known to be good
+func mustParseFormula(text string) *formula {
+	f, err := parseFormula(text)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// parseFormula compiles the formula in text
+//
+// Formulas are made of z, c, numbers, the constants i, pi and e, the
+// operators + - * / and ^, brackets and the functions in
+// formulaFuncs. Numbers may be imaginary, eg 0.5i, or have an
+// exponent, eg 1e-3, and a multiplication may be left out, eg 2z or
+// zc.
+//
+// The formula is compiled into a tree of closures, working out
+// anything constant as it goes, and whole number powers are done by
+// multiplying as cmplx.Pow is slow.
+func parseFormula(text string) (*formula, error) {
+	p := &formulaParser{text: text}
+	p.next()
+	n, err := p.expr()
+	if err == nil && p.tok != "" {
+		err = p.errorf("unexpected %q", p.tok)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("formula %q: %w", text, err)
+	}
+	return &formula{text: text, eval: n.eval}, nil
+}
+
+// formulaNode is part of a compiled formula
+type formulaNode struct {
+	eval     func(z, c complex128) complex128
+	constant bool       // set if it doesn't depend on z or c
+	value    complex128 // the value if constant
+}
+
+// constNode makes a node with a constant value
+func constNode(v complex128) formulaNode {
+	return formulaNode{
+		eval:     func(z, c complex128) complex128 { return v },
+		constant: true,
+		value:    v,
+	}
+}
+
+// binaryNode makes a node of op applied to a and b, working it out now
+// if both are constant
+func binaryNode(a, b formulaNode, op func(x, y complex128) complex128) formulaNode {
+	if a.constant && b.constant {
+		return constNode(op(a.value, b.value))
+	}
+	ea, eb := a.eval, b.eval
+	return formulaNode{eval: func(z, c complex128) complex128 { return op(ea(z, c), eb(z, c)) }}
+}
+
+// funcNode makes a node of fn applied to a, working it out now if a is
+// constant
+func funcNode(a formulaNode, fn func(complex128) complex128) formulaNode {
+	if a.constant {
+		return constNode(fn(a.value))
+	}
+	ea := a.eval
+	return formulaNode{eval: func(z, c complex128) complex128 { return fn(ea(z, c)) }}
+}
+
+// powNode makes a node of a raised to the power b
+func powNode(a, b formulaNode) formulaNode {
+	n := int(real(b.value))
+	if !b.constant || imag(b.value) != 0 || float64(n) != real(b.value) || n < 1 || n > 64 {
+		return binaryNode(a, b, cmplx.Pow)
+	}
+	return funcNode(a, func(x complex128) complex128 {
+		// Raise to the power by repeated squaring
+		result := complex(1, 0)
+		for k := n; k > 0; k >>= 1 {
+			if k&1 != 0 {
+				result *= x
+			}
+			x *= x
+		}
+		return result
+	})
+}
+
+// formulaParser is a recursive descent parser of formulas
+type formulaParser struct {
+	text string
+	pos  int    // position after the current token
+	tok  string // the current token, "" at the end
+	at   int    // position of the current token
+}
+
+// errorf makes an error at the current token
+func (p *formulaParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%s at character %d", fmt.Sprintf(format, args...), utf8.RuneCountInString(p.text[:p.at])+1)
+}
+
+// next reads the next token into p.tok
+//
+// The tokens are numbers, with an i on the end if imaginary, names and
+// single characters. Names run together are split into z, c, the
+// constants and the functions, longest first, so zc is z times c but
+// cos is a function. The e of an exponent is part of the number, so
+// 1e without the digits of the exponent is a bad number rather than
+// 1 times e, unless it starts a function, as in 2exp(z).
+func (p *formulaParser) next() {
+	for p.pos < len(p.text) && p.text[p.pos] == ' ' {
+		p.pos++
+	}
+	p.at = p.pos
+	if p.pos >= len(p.text) {
+		p.tok = ""
+		return
+	}
+	isDigit := func(i int) bool { return i < len(p.text) && p.text[i] >= '0' && p.text[i] <= '9' }
+	end := p.pos
+	switch {
+	case isDigit(end) || p.text[end] == '.':
+		for isDigit(end) || end < len(p.text) && p.text[end] == '.' {
+			end++
+		}
+		if end < len(p.text) && (p.text[end] == 'e' || p.text[end] == 'E') && p.knownName(end) <= 1 {
+			end++
+			if end < len(p.text) && (p.text[end] == '+' || p.text[end] == '-') {
+				end++
+			}
+			for isDigit(end) {
+				end++
+			}
+		}
+		if end < len(p.text) && p.text[end] == 'i' && !isLetterAt(p.text, end+1) {
+			end++
+		}
+	case isLetterAt(p.text, end):
+		if n := p.knownName(end); n > 0 {
+			end += n
+			break
+		}
+		for isLetterAt(p.text, end) {
+			_, n := utf8.DecodeRuneInString(p.text[end:])
+			end += n
+		}
+	default:
+		_, n := utf8.DecodeRuneInString(p.text[end:])
+		end += n
+	}
+	p.tok, p.pos = p.text[p.at:end], end
+}
+
+// knownName returns the length of the longest of z, c, the constants
+// and the functions which the text starts with at i in any case, or 0
+// if it starts with none of them
+func (p *formulaParser) knownName(i int) int {
+	longest := 0
+	try := func(name string) {
+		if n := len(name); n > longest && i+n <= len(p.text) && strings.EqualFold(p.text[i:i+n], name) {
+			longest = n
+		}
+	}
+	try("z")
+	try("c")
+	for name := range formulaConsts {
+		try(name)
+	}
+	for name := range formulaFuncs {
+		try(name)
+	}
+	return longest
+}
+
+// isLetterAt returns true if s has a letter at i
+func isLetterAt(s string, i int) bool {
+	if i >= len(s) {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(s[i:])
+	return unicode.IsLetter(r)
+}
+
+// expr parses terms added together
+func (p *formulaParser) expr() (formulaNode, error) {
+	a, err := p.term()
+	for err == nil && (p.tok == "+" || p.tok == "-") {
+		op := p.tok
+		p.next()
+		var b formulaNode
+		b, err = p.term()
+		if op == "+" {
+			a = binaryNode(a, b, func(x, y complex128) complex128 { return x + y })
+		} else {
+			a = binaryNode(a, b, func(x, y complex128) complex128 { return x - y })
+		}
+	}
+	return a, err
+}
+
+// term parses factors multiplied together, using a multiplication
+// if there is nothing between them
+func (p *formulaParser) term() (formulaNode, error) {
+	a, err := p.unary()
+	for err == nil {
+		op := p.tok
+		var b formulaNode
+		switch {
+		case op == "*" || op == "/":
+			p.next()
+			b, err = p.unary()
+		case op == "(" || isLetterAt(op, 0) || op != "" && (op[0] >= '0' && op[0] <= '9' || op[0] == '.'):
+			op = "*"
+			b, err = p.power()
+		default:
+			return a, nil
+		}
+		if op == "*" {
+			a = binaryNode(a, b, func(x, y complex128) complex128 { return x * y })
+		} else {
+			a = binaryNode(a, b, func(x, y complex128) complex128 { return x / y })
+		}
+	}
+	return a, err
+}
+
+// unary parses a factor with a sign in front
+func (p *formulaParser) unary() (formulaNode, error) {
+	switch p.tok {
+	case "-":
+		p.next()
+		a, err := p.unary()
+		return funcNode(a, func(x complex128) complex128 { return -x }), err
+	case "+":
+		p.next()
+		return p.unary()
+	}
+	return p.power()
+}
+
+// power parses a primary raised to a power, which binds to the right
+// so z^-2 and 2^3^2 work as expected
+func (p *formulaParser) power() (formulaNode, error) {
+	a, err := p.primary()
+	if err != nil || p.tok != "^" {
+		return a, err
+	}
+	p.next()
+	b, err := p.unary()
+	return powNode(a, b), err
+}
+
+// primary parses a number, variable, constant, function call or
+// bracketed formula
+func (p *formulaParser) primary() (formulaNode, error) {
+	tok := p.tok
+	switch {
+	case tok == "":
+		return formulaNode{}, p.errorf("formula ended early")
+	case tok == "(":
+		p.next()
+		a, err := p.expr()
+		if err != nil {
+			return a, err
+		}
+		if p.tok != ")" {
+			return a, p.errorf("missing )")
+		}
+		p.next()
+		return a, nil
+	case tok == "z" || tok == "Z":
+		p.next()
+		return formulaNode{eval: func(z, c complex128) complex128 { return z }}, nil
+	case tok == "c" || tok == "C":
+		p.next()
+		return formulaNode{eval: func(z, c complex128) complex128 { return c }}, nil
+	case tok[0] >= '0' && tok[0] <= '9' || tok[0] == '.':
+		imaginary := strings.HasSuffix(tok, "i")
+		f, err := strconv.ParseFloat(strings.TrimSuffix(tok, "i"), 64)
+		if err != nil {
+			return formulaNode{}, p.errorf("bad number %q", tok)
+		}
+		p.next()
+		if imaginary {
+			return constNode(complex(0, f)), nil
+		}
+		return constNode(complex(f, 0)), nil
+	}
+	if !isLetterAt(tok, 0) {
+		return formulaNode{}, p.errorf("unexpected %q", tok)
+	}
+	name := strings.ToLower(tok)
+	if v, ok := formulaConsts[name]; ok {
+		p.next()
+		return constNode(v), nil
+	}
+	fn, ok := formulaFuncs[name]
+	if !ok {
+		return formulaNode{}, p.errorf("unknown %q", tok)
+	}
+	p.next()
+	if p.tok != "(" {
+		return formulaNode{}, p.errorf("missing ( after %s", name)
+	}
+	a, err := p.primary()
+	return funcNode(a, fn), err
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"math/cmplx"
+	"strings"
+	"testing"
+)
+
+func TestParseFormulaImplicitMultiplication(t *testing.T) {
+	z, c := complex(0.3, -0.7), complex(-1.1, 0.4)
+	for _, test := range []struct {
+		in   string
+		want complex128
+	}{
+		{"zc", z * c},
+		{"cz", c * z},
+		{"ZC", z * c},
+		{"2z", 2 * z},
+		{"2zc", 2 * z * c},
+		{"z c", z * c},
+		{"z^2c", z * z * c},
+		{"cz^2", c * z * z},
+		{"z(z+1)", z * (z + 1)},
+		{"(z)(c)", z * c},
+		{"3sin(z)", 3 * cmplx.Sin(z)},
+		{"zsin(c)", z * cmplx.Sin(c)},
+		{"cos(z)", cmplx.Cos(z)},
+		{"cosh(z)", cmplx.Cosh(z)},
+		{"conj(z)c", cmplx.Conj(z) * c},
+		{"2exp(z)", 2 * cmplx.Exp(z)},
+		{"piz", math.Pi * z},
+		{"ez", math.E * z},
+		{"iz", 1i * z},
+		{"0.5iz", 0.5i * z},
+		{"1e3z", 1000 * z},
+		{"2.5e-1c", 0.25 * c},
+		{"1E+1z", 10 * z},
+	} {
+		f, err := parseFormula(test.in)
+		if err != nil {
+			t.Errorf("%q: %v", test.in, err)
+			continue
+		}
+		if got := f.eval(z, c); cmplx.Abs(got-test.want) > 1e-12 {
+			t.Errorf("%q: got %v, want %v", test.in, got, test.want)
+		}
+	}
+}
+
+func TestParseFormulaErrors(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want string
+	}{
+		{"1e", `bad number "1e" at character 1`},
+		{"z + 2E", `bad number "2E" at character 5`},
+		{"1e+", `bad number "1e+" at character 1`},
+		{"1e-z", `bad number "1e-" at character 1`},
+		{"foo(z)", `unknown "foo" at character 1`},
+		{"zfoo", `unknown "foo" at character 2`},
+		{"sinz", `missing ( after sin at character 4`},
+		{"z²", `unexpected "²" at character 2`},
+		{"é + z", `unknown "é" at character 1`},
+		{"z × c", `unexpected "×" at character 3`},
+		{"z^", `formula ended early at character 3`},
+	} {
+		_, err := parseFormula(test.in)
+		if err == nil {
+			t.Errorf("%q: no error, want %q", test.in, test.want)
+			continue
+		}
+		if !strings.HasSuffix(err.Error(), test.want) {
+			t.Errorf("%q: got error %q, want %q", test.in, err, test.want)
+		}
+	}
+}
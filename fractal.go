@@ -0,0 +1,384 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"image/color"
+	"math"
+	"math/cmplx"
+	"slices"
+	"strings"
+)
+
+// Flags
+var (
+	fractalFlag = flag.String("fractal", "mandelbrot", "Fractal to start with - mandelbrot, burning-ship, celtic, buffalo, multibrot, tricorn, lambda, nova, phoenix, lyapunov, magnet1, magnet2, collatz, formula for --formula or hybrid for --hybrid")
+	paramFlag   = flag.Float64("param", 0, "Parameter of the fractal - the exponent of multibrot, the relaxation of nova or p of phoenix")
+)
+
+// Fractal is one of the fractals which can be plotted
+//
+// Each is a type of its own in a file of its own, which registers it
+// from an init function with registerFractal, so adding a fractal
+// only needs a new file. Fractals which differ from the Mandelbrot set
+// in more than these implement the optional methods below as well.
+type Fractal interface {
+	// Name returns the name used in flags, bookmarks and commands
+	Name() string
+
+	// Title returns the name shown in the info overlay
+	Title() string
+
+	// Iterate iterates z with c from iteration i until z escapes, or
+	// converges for fractals which do, or it reaches maxDepth
+	// iterations, returning the iteration count and final z the same
+	// as mandelbrot
+	Iterate(z, c complex128, i, maxDepth int) (int, complex128)
+
+	// DefaultView returns the center and radius of the view reset
+	// goes to
+	DefaultView() (complex128, float64)
+}
+
+// Optional methods of a Fractal
+type (
+	// startFractal starts z of its Mandelbrot style set at Start
+	// rather than 0
+	startFractal interface{ Start() complex128 }
+
+	// juliaViewFractal has a view of its Julia sets for reset to go
+	// to other than the default
+	juliaViewFractal interface {
+		JuliaView() (complex128, float64)
+	}
+
+	// convergingFractal has its points colored by how fast they
+	// converge rather than escape if Converges
+	convergingFractal interface{ Converges() bool }
+
+	// historyFractal needs more than z to iterate if History, so
+	// can't be carried on from where it got to
+	historyFractal interface{ History() bool }
+
+	// noJuliaFractal has no Julia sets if NoJulia
+	noJuliaFractal interface{ NoJulia() bool }
+
+	// paramFractal has a parameter which can be changed
+	paramFractal interface{ Param() *fractalParam }
+
+	// powerFractal is iterated with an exponent other than 2
+	powerFractal interface{ Power() float64 }
+
+	// colorFractal isn't colored by how fast its points escape or
+	// converge so has its own coloring
+	colorFractal interface{ Color(it iteration) color.RGBA }
+)
+
+// Size of z at which the points of the Mandelbrot set and the
+// fractals like it have escaped
+const escapeRadius = 2
+
+// fractalParam describes the parameter of a fractal which can be
+// changed from the keyboard
+type fractalParam struct {
+	name     string  // shown in the info overlay, eg "exponent"
+	initial  float64 // value when the fractal is chosen
+	min, max float64 // range of values allowed
+	step     float64 // change for each key press
+}
+
+// registeredFractal is a fractal which can be chosen
+type registeredFractal struct {
+	Fractal
+	order int // where it comes in the cycle of fractals
+}
+
+// The fractals which can be chosen in the order they are cycled
+// through, filled in by registerFractal
+var fractals []registeredFractal
+
+// registerFractal adds the fractal f to those which can be chosen,
+// order saying where it comes in the cycle of them
+//
+// The fractals register themselves from their own files so the order
+// is a number rather than a place in a list, spaced out in tens to
+// leave room for new fractals in between.
+func registerFractal(order int, f Fractal) {
+	for _, r := range fractals {
+		if r.Name() == f.Name() || r.order == order {
+			panic(fmt.Sprintf("fractal %q registered at %d clashes with %q at %d", f.Name(), order, r.Name(), r.order))
+		}
+	}
+	i, _ := slices.BinarySearchFunc(fractals, order, func(r registeredFractal, order int) int {
+		return cmp.Compare(r.order, order)
+	})
+	fractals = slices.Insert(fractals, i, registeredFractal{Fractal: f, order: order})
+}
+
+// isMandelbrot returns true if f is the Mandelbrot set, which has
+// kernels and colorings of its own
+func isMandelbrot(f Fractal) bool {
+	_, ok := f.(mandelbrotFractal)
+	return ok
+}
+
+// fractalStart returns the z the iteration of the Mandelbrot style
+// set of f starts at
+func fractalStart(f Fractal) complex128 {
+	if s, ok := f.(startFractal); ok {
+		return s.Start()
+	}
+	return 0
+}
+
+// fractalConverges returns true if the points of f are colored by how fast
+// they converge
+func fractalConverges(f Fractal) bool {
+	c, ok := f.(convergingFractal)
+	return ok && c.Converges()
+}
+
+// needsHistory returns true if f can't be carried on from z
+func needsHistory(f Fractal) bool {
+	h, ok := f.(historyFractal)
+	return ok && h.History()
+}
+
+// hasJulia returns true if f has Julia sets
+func hasJulia(f Fractal) bool {
+	n, ok := f.(noJuliaFractal)
+	return !ok || !n.NoJulia()
+}
+
+// fractalParamOf returns the parameter of f which can be changed, or
+// nil if it has none
+func fractalParamOf(f Fractal) *fractalParam {
+	if p, ok := f.(paramFractal); ok {
+		return p.Param()
+	}
+	return nil
+}
+
+// Constants for fractals whose points converge
+const (
+	// A point has converged when a step is smaller than this
+	convergedStep = 1e-6
+
+	// A point is taken to be diverging when it gets this big
+	divergedSize = 1e10
+)
+
+// convergedCount returns the iteration count i at which a point
+// converged made continuous from the size of the last step, which
+// squares each iteration as it converges
+func convergedCount(i int, step complex128) float64 {
+	return float64(i) - math.Log(math.Log(cmplx.Abs(step))/math.Log(convergedStep))/math.Log(2)
+}
+
+// fractalParams decide which set is plotted
+//
+// Plots are only reused for the same parameters so they are part of
+// the identity of plots and tiles.
+type fractalParams struct {
+	fractal Fractal    // the fractal iterated
+	param   float64    // the parameter of the fractal if it has one, eg the exponent of the Multibrot set
+	formula *formula   // the formula iterated by the formula fractal
+	hybrid  string     // the pattern of steps of the hybrid fractal
+	julia   bool       // set for the Julia set of c rather than the Mandelbrot set
+	c       complex128 // the parameter of the Julia set
+}
+
+// Globals
+var (
+	params    = fractalParams{fractal: mandelbrotFractal{}} // the set being plotted
+	juliaFrom view                                          // the Mandelbrot view the Julia set was picked from
+)
+
+// iterate iterates the point p of the set from the start, returning
+// the iteration count and final z
+//
+// p is relative to origin like center. The deep zoom kernels work
+// from that, the others need the point itself.
+func iterate(p complex128, maxDepth int) iteration {
+	if origin != nil {
+		switch currentKernel() {
+		case kernelDouble:
+			return ddIterate(p, maxDepth)
+		case kernelPerturb:
+			return perturbIterate(p, maxDepth)
+		case kernelBig:
+			return bigIterate(p, maxDepth)
+		}
+		p = absPoint(p)
+	}
+	if params.julia {
+		return escapeOrbit(iteration{z: p, dz: 1}, params.c, maxDepth, true)
+	}
+	return escapeOrbit(iteration{z: fractalStart(params.fractal)}, p, maxDepth, false)
+}
+
+// iterateFrom carries on iterating the point p of the set from where
+// it got to in it
+//
+// Fractals which need the history of z are iterated from the start
+// again instead, as are points whose sum over the orbit was replaced
+// by their interior value, points of deep zooms, whose z isn't
+// precise enough to carry on from, and points which were filled in
+// without iterating them.
+func iterateFrom(it iteration, p complex128, maxDepth int) iteration {
+	if needsHistory(params.fractal) || needsInterior() && neededSum() != noSum || origin != nil || it.filled {
+		return iterate(p, maxDepth)
+	}
+	c := p
+	if params.julia {
+		c = params.c
+	}
+	return escapeOrbit(it, c, maxDepth, params.julia)
+}
+
+// toggleJulia switches to the Julia set for the point c of the
+// Mandelbrot set, or back to the Mandelbrot set view it was picked from
+func toggleJulia(c complex128) {
+	if params.julia {
+		v := juliaFrom
+		v.params = params
+		v.params.julia, v.params.c = false, 0
+		setView(v)
+		return
+	}
+	if !hasJulia(params.fractal) {
+		message = fmt.Sprintf(tr("The %s has no Julia sets"), tr(params.fractal.Title()))
+		return
+	}
+	juliaFrom = currentView()
+	params.julia, params.c = true, absPoint(c)
+	center, radius = homeView()
+	origin = nil
+}
+
+// homeView returns the whole view of the set being plotted
+func homeView() (complex128, float64) {
+	if params.julia {
+		return juliaHome(params.fractal)
+	}
+	return params.fractal.DefaultView()
+}
+
+// juliaHome returns the whole view of the Julia sets of the fractal
+func juliaHome(f Fractal) (complex128, float64) {
+	if j, ok := f.(juliaViewFractal); ok {
+		return j.JuliaView()
+	}
+	return 0, 2
+}
+
+// fractalByName returns the fractal called name
+func fractalByName(name string) (Fractal, error) {
+	var names []string
+	for _, f := range fractals {
+		if f.Name() == name {
+			return f.Fractal, nil
+		}
+		names = append(names, f.Name())
+	}
+	return nil, fmt.Errorf("unknown fractal %q - use one of %s", name, strings.Join(names, ", "))
+}
+
+// checkFractalFlag sets the fractal from --fractal and --param
+func checkFractalFlag() error {
+	f, err := fractalByName(*fractalFlag)
+	if err != nil {
+		return fmt.Errorf("--fractal: %w", err)
+	}
+	setFractal(f)
+	paramSet := false
+	flag.Visit(func(f *flag.Flag) {
+		paramSet = paramSet || f.Name == "param"
+	})
+	if !paramSet {
+		return nil
+	}
+	fp := fractalParamOf(f)
+	if fp == nil {
+		return fmt.Errorf("--param: the %s fractal has no parameter", *fractalFlag)
+	}
+	if !(*paramFlag >= fp.min && *paramFlag <= fp.max) {
+		return fmt.Errorf("--param: the %s must be from %g to %g", fp.name, fp.min, fp.max)
+	}
+	params.param = *paramFlag
+	return nil
+}
+
+// setFractal switches to the fractal f, starting from its whole view
+func setFractal(f Fractal) {
+	params = fractalParams{fractal: f}
+	switch f.(type) {
+	case formulaFractal:
+		params.formula = userFormula
+	case hybridFractal:
+		params.hybrid = userHybrid
+	}
+	if fp := fractalParamOf(f); fp != nil {
+		params.param = fp.initial
+	}
+	reset()
+}
+
+// changeParam changes the parameter of the fractal by steps
+func changeParam(steps int) {
+	fp := fractalParamOf(params.fractal)
+	if fp == nil {
+		message = fmt.Sprintf(tr("The %s has no parameter to change"), tr(params.fractal.Title()))
+		return
+	}
+	params.param = max(fp.min, min(fp.max, params.param+float64(steps)*fp.step))
+	// Keep to whole steps despite rounding errors
+	params.param = math.Round(params.param/fp.step) * fp.step
+	message = tr(params.fractal.Title()) + paramName()
+}
+
+// fractalPower returns the exponent of the iteration
+func fractalPower() float64 {
+	if p, ok := params.fractal.(powerFractal); ok {
+		return p.Power()
+	}
+	return 2
+}
+
+// nextFractal cycles to the next fractal
+func nextFractal() {
+	i := slices.IndexFunc(fractals, func(r registeredFractal) bool {
+		return r.Fractal == params.fractal
+	})
+	setFractal(fractals[(i+1)%len(fractals)].Fractal)
+	message = fmt.Sprintf(tr("Fractal %s"), tr(params.fractal.Title()))
+}
+
+// fractalName describes the set being plotted for the info overlay
+func fractalName() string {
+	if params.julia {
+		s := fmt.Sprintf(tr("• Julia set of %g"), params.c)
+		if !isMandelbrot(params.fractal) {
+			s += " (" + tr(params.fractal.Title()) + paramName() + ")"
+		}
+		return s
+	}
+	return "• " + tr(params.fractal.Title()) + paramName()
+}
+
+// paramName describes the parameter of the fractal if it has one, or
+// the formula of the formula fractal or the pattern of the hybrid
+func paramName() string {
+	switch params.fractal.(type) {
+	case formulaFractal:
+		return " " + params.formula.text
+	case hybridFractal:
+		return " " + params.hybrid
+	}
+	fp := fractalParamOf(params.fractal)
+	if fp == nil {
+		return ""
+	}
+	return fmt.Sprintf(", %s %g", tr(fp.name), params.param)
+}
@@ -0,0 +1,44 @@
+package main
+
+// Rows of each band of a frame iterated on the GPU, so the GPU only
+// gets a few launches a frame but the first bands can be sent to the
+// terminal while the rest are worked out
+const gpuBandHeight = 256
+
+// gpuUsable returns true if the GPU kernel can be used, as it is set
+// up and working and the coloring only needs the iteration counts and
+// final z of the points like the SIMD kernel
+func gpuUsable() bool {
+	return gpuReady() && !needsDerivative() && neededSum() == noSum
+}
+
+// gpuFrames returns true if frames are iterated a band at a time on
+// the GPU rather than split into rows and tiles for the workers
+func gpuFrames() bool {
+	return currentKernel() == kernelGPU && gpuUsable()
+}
+
+// iterateGridGPU iterates the pixels of the band of height rows from
+// row top of the grid with top left at x0, y0 which aren't covered on
+// the GPU in one go, setting their results in iters
+//
+// It must only be called from one goroutine at a time as it has the
+// GPU to itself. covered may be nil if none of them are.
+func iterateGridGPU(x0, y0, dx, dy float64, width, top, height, maxDepth int, iters []iteration, covered []bool) {
+	ps := make([]complex128, 0, width*height)
+	idx := make([]int, 0, width*height)
+	for y := 0; y < height; y++ {
+		fy := y0 + dy*float64(top+y)
+		for x := 0; x < width; x++ {
+			if p := y*width + x; covered == nil || !covered[p] {
+				ps = append(ps, complex(x0+dx*float64(x), fy))
+				idx = append(idx, p)
+			}
+		}
+	}
+	its := make([]iteration, len(ps))
+	iterateBatch(ps, maxDepth, its, mandelbrotsGPU)
+	for j, p := range idx {
+		iters[p] = its[j]
+	}
+}
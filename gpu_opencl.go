@@ -0,0 +1,56 @@
+//go:build opencl
+
+package main
+
+import (
+	"sync/atomic"
+
+	"github.com/ncw/termbrot/internal/opencl"
+)
+
+// Globals
+var (
+	gpu       *opencl.Device // set up by initGPU
+	gpuFailed atomic.Bool    // set if the GPU failed so isn't used any more
+)
+
+// initGPU sets up the GPU kernel
+func initGPU() error {
+	var err error
+	gpu, err = opencl.Open(interiorEpsilon, periodFirstCheck)
+	return err
+}
+
+// gpuReady returns true if the GPU kernel is set up and working
+func gpuReady() bool {
+	return gpu != nil && !gpuFailed.Load()
+}
+
+// gpuName returns the name of the device the GPU kernel runs on
+func gpuName() string {
+	if gpu == nil {
+		return ""
+	}
+	return gpu.Name()
+}
+
+// mandelbrotsGPU iterates the points z with c from iteration 0 on the
+// GPU like mandelbrot does, leaving their final z in z and their
+// iterations in is
+//
+// If the GPU fails they are iterated on the CPU instead, and so are
+// all the points after as it isn't used again. It must only be called
+// from one goroutine at a time.
+func mandelbrotsGPU(z, c []complex128, maxDepth int, is []int) {
+	if len(z) > 0 && maxDepth >= 1 && gpuReady() {
+		counts := make([]int64, len(z))
+		if err := gpu.Mandelbrot(z, c, maxDepth, cycleEpsilon(), counts); err == nil {
+			for k, n := range counts {
+				is[k] = int(n)
+			}
+			return
+		}
+		gpuFailed.Store(true)
+	}
+	mandelbrots(z, c, maxDepth, is)
+}
@@ -0,0 +1,34 @@
+//go:build opencl
+
+package main
+
+import "testing"
+
+func TestMandelbrotsGPU(t *testing.T) {
+	if err := initGPU(); err != nil {
+		t.Skipf("no OpenCL device: %v", err)
+	}
+	const (
+		width, height = 96, 64
+		maxDepth      = 2000
+	)
+	periodDistance = periodTolerance * 3.0 / width
+	var z, c []complex128
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			z = append(z, 0)
+			c = append(c, complex(-2+3*float64(x)/width, -1.2+2.4*float64(y)/height))
+		}
+	}
+	is := make([]int, len(z))
+	mandelbrotsGPU(z, c, maxDepth, is)
+	if gpuFailed.Load() {
+		t.Fatal("the GPU failed")
+	}
+	for k := range c {
+		i, want := mandelbrot(0, c[k], 0, maxDepth)
+		if is[k] != i || z[k] != want {
+			t.Errorf("%v: got %d iterations to %v, want %d to %v", c[k], is[k], z[k], i, want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+//go:build !opencl
+
+package main
+
+import "errors"
+
+// initGPU sets up the GPU kernel, which is only built with -tags opencl
+func initGPU() error {
+	return errors.New("the gpu kernel needs termbrot built with -tags opencl")
+}
+
+// gpuReady returns true if the GPU kernel is set up and working, which
+// it never is without OpenCL
+func gpuReady() bool {
+	return false
+}
+
+// gpuName returns the name of the device the GPU kernel runs on
+func gpuName() string {
+	return ""
+}
+
+// mandelbrotsGPU iterates the points z with c from iteration 0 like
+// mandelbrot does, leaving their final z in z and their iterations in
+// is, which without OpenCL is done on the CPU
+func mandelbrotsGPU(z, c []complex128, maxDepth int, is []int) {
+	mandelbrots(z, c, maxDepth, is)
+}
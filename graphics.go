@@ -0,0 +1,213 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Flags
+var (
+	graphicsFlag = flag.String("graphics", "auto", "How to send the images to the terminal - kitty, iterm, sixel, blocks of colored text, braille for text without colors or auto to ask the terminal what it can do")
+)
+
+// graphicsProtocol is how the images are sent to the terminal
+type graphicsProtocol int
+
+// The graphics protocols
+const (
+	kittyGraphics   graphicsProtocol = iota // the kitty graphics protocol
+	itermGraphics                           // iTerm2 inline images
+	sixelGraphics                           // DEC sixels
+	blockGraphics                           // colored half blocks of text, for terminals without images
+	brailleGraphics                         // braille dots of text, for terminals without colors either
+)
+
+// Names of the graphics protocols indexed by graphicsProtocol
+var graphicsNames = []string{"kitty", "iterm", "sixel", "blocks", "braille"}
+
+// Terminals which speak sixel but not the kitty protocol, by the
+// start of $TERM
+var sixelTerminals = []string{"foot", "mlterm", "yaft", "contour"}
+
+// Terminals which show no images, or don't pass them on from the
+// terminal they run in, by the start of $TERM
+var blockTerminals = []string{"screen", "tmux"}
+
+// The graphics protocol in use
+var graphics = kittyGraphics
+
+// Queries sent to the terminal to find out what it can do. Every
+// terminal answers the request for its device attributes, so that goes
+// last and its answer marks the end of the others.
+const (
+	kittyQuery            = "\033_Gi=31,s=1,v=1,a=q,t=d,f=24;AAAA\033\\" // a 1 x 1 image checked but not stored
+	versionQuery          = "\033[>q"                                    // XTVERSION
+	deviceAttributesQuery = "\033[c"                                     // DA1
+)
+
+// How long to wait for the terminal to answer the queries
+const probeTimeout = time.Second
+
+// Answers to the queries
+var (
+	kittyAnswer            = regexp.MustCompile(`\033_Gi=31;OK\033\\`)
+	versionAnswer          = regexp.MustCompile(`\033P>\|([^\033]*)\033\\`)
+	deviceAttributesAnswer = regexp.MustCompile(`\033\[\?([0-9;]*)c`)
+)
+
+// checkGraphicsFlag sets the graphics protocol from --graphics
+//
+// With auto the terminal is asked what it can do by probeGraphics
+// once it is known termbrot is going to draw in it.
+func checkGraphicsFlag() error {
+	if *graphicsFlag == "auto" {
+		return nil
+	}
+	for i, n := range graphicsNames {
+		if n == *graphicsFlag {
+			graphics = graphicsProtocol(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("--graphics must be auto or one of %s not %q", strings.Join(graphicsNames, ", "), *graphicsFlag)
+}
+
+// probeGraphics picks the graphics protocol for the terminal if
+// --graphics is auto
+func probeGraphics() {
+	if *graphicsFlag != "auto" {
+		return
+	}
+	answers, err := queryTerminal(kittyQuery + versionQuery + deviceAttributesQuery)
+	if err != nil {
+		graphics = guessGraphics()
+		return
+	}
+	graphics = pickGraphics(answers)
+}
+
+// pickGraphics picks the best graphics protocol the terminal says it
+// has in its answers to the queries
+//
+// The kitty protocol is best, then iTerm2's which iTerm2 tells by
+// name, then sixel which the terminal says it has in its device
+// attributes. Otherwise the terminals which say they have 24 bit color,
+// or which know their version, which are all new enough to, get
+// blocks and the rest braille.
+func pickGraphics(answers []byte) graphicsProtocol {
+	if kittyAnswer.Match(answers) {
+		return kittyGraphics
+	}
+	version := ""
+	if m := versionAnswer.FindSubmatch(answers); m != nil {
+		version = string(m[1])
+	}
+	if strings.HasPrefix(version, "iTerm2") {
+		return itermGraphics
+	}
+	if m := deviceAttributesAnswer.FindSubmatch(answers); m != nil {
+		for _, attribute := range strings.Split(string(m[1]), ";") {
+			if attribute == "4" {
+				return sixelGraphics
+			}
+		}
+	}
+	colorTerm := os.Getenv("COLORTERM")
+	if version != "" || colorTerm == "truecolor" || colorTerm == "24bit" {
+		return blockGraphics
+	}
+	return brailleGraphics
+}
+
+// queryTerminal sends the queries to the terminal and returns its
+// answers, which end with the answer to the last, which must be
+// deviceAttributesQuery
+//
+// The queries go to /dev/tty and the answers are read from it, so
+// they reach the terminal even with stdin or stdout redirected. It is
+// put in raw mode while reading them so they aren't echoed and don't
+// need a return. It returns an error if there is no terminal or it
+// doesn't answer in probeTimeout.
+func queryTerminal(queries string) ([]byte, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer tty.Close()
+	fd := int(tty.Fd())
+	old, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return nil, err
+	}
+	raw := *old
+	raw.Lflag &^= unix.ICANON | unix.ECHO
+	// Return from reads after a tenth of a second without input
+	raw.Cc[unix.VMIN], raw.Cc[unix.VTIME] = 0, 1
+	err = unix.IoctlSetTermios(fd, ioctlSetTermios, &raw)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = unix.IoctlSetTermios(fd, ioctlSetTermios, old)
+	}()
+	_, err = tty.WriteString(queries)
+	if err != nil {
+		return nil, err
+	}
+	var answers []byte
+	buf := make([]byte, 256)
+	for deadline := time.Now().Add(probeTimeout); time.Now().Before(deadline); {
+		n, err := unix.Read(fd, buf)
+		if err != nil && err != unix.EINTR {
+			return nil, err
+		}
+		answers = append(answers, buf[:max(n, 0)]...)
+		if deviceAttributesAnswer.Match(answers) {
+			return answers, nil
+		}
+	}
+	return nil, fmt.Errorf("no answer from the terminal in %v", probeTimeout)
+}
+
+// guessGraphics guesses the graphics protocol for a terminal which
+// doesn't answer the queries from $TERM, using the kitty protocol
+// unless it is known not to work
+func guessGraphics() graphicsProtocol {
+	term := os.Getenv("TERM")
+	for _, t := range sixelTerminals {
+		if strings.HasPrefix(term, t) {
+			return sixelGraphics
+		}
+	}
+	for _, t := range blockTerminals {
+		if strings.HasPrefix(term, t) {
+			return blockGraphics
+		}
+	}
+	return kittyGraphics
+}
+
+// scaleToCells scales raw RGB data for one line of the screen to the
+// pixels of cols cells, returning it and its new size
+func scaleToCells(data []byte, width, height, cols int) ([]byte, int, int) {
+	_, _, _, _, cellWidth, cellHeight := getImageDimensions()
+	w, h := cols*cellWidth, cellHeight
+	if w == width && h == height {
+		return data, w, h
+	}
+	scaled := make([]byte, 3*w*h)
+	for y := 0; y < h; y++ {
+		sy := y * height / h
+		for x := 0; x < w; x++ {
+			sx := x * width / w
+			copy(scaled[3*(y*w+x):3*(y*w+x)+3], data[3*(sy*width+sx):])
+		}
+	}
+	return scaled, w, h
+}
@@ -0,0 +1,82 @@
+package main
+
+// Only remember this many views
+const maxHistory = 1000
+
+// view is a location in the set
+type view struct {
+	center complex128
+	origin *deepPoint // the point center is relative to, see origin
+	radius float64
+	depth  int
+	params fractalParams
+}
+
+// Globals
+var (
+	history []view // views visited before the current one, newest last
+	future  []view // views undone, most recently undone last
+
+	previous     view // the view before the last move, for jumping back
+	havePrevious bool
+)
+
+// currentView returns the view being displayed
+func currentView() view {
+	return view{center: center, origin: origin, radius: radius, depth: depth, params: params}
+}
+
+// setView changes the view being displayed
+func setView(v view) {
+	center, origin, radius, depth, params = v.center, v.origin, v.radius, v.depth, v.params
+}
+
+// pushHistory remembers v as the view before the current one
+func pushHistory(v view) {
+	previous, havePrevious = v, true
+	if len(history) > 0 && history[len(history)-1] == v {
+		return
+	}
+	history = append(history, v)
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+	future = future[:0]
+}
+
+// undo goes back to the previous view, returning false if there isn't one
+func undo() bool {
+	if len(history) == 0 {
+		return false
+	}
+	future = append(future, currentView())
+	previous, havePrevious = currentView(), true
+	setView(history[len(history)-1])
+	history = history[:len(history)-1]
+	return true
+}
+
+// redo goes forward to the view last undone, returning false if there isn't one
+func redo() bool {
+	if len(future) == 0 {
+		return false
+	}
+	history = append(history, currentView())
+	previous, havePrevious = currentView(), true
+	setView(future[len(future)-1])
+	future = future[:len(future)-1]
+	return true
+}
+
+// jumpBack swaps to the view before the last move, returning false if
+// there isn't one. Doing it again swaps back.
+//
+// The move adds the view jumped from to the history, so it becomes
+// the one to jump back to next.
+func jumpBack() bool {
+	if !havePrevious || previous == currentView() {
+		return false
+	}
+	setView(previous)
+	return true
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// Flags
+var (
+	hybridFlag = flag.String("hybrid", "", "Iterate the hybrid fractal of this pattern of M (Mandelbrot), B (Burning Ship), T (Tricorn), C (Celtic) and U (Buffalo) steps, eg MMB")
+)
+
+func init() {
+	registerFractal(140, hybridFractal{})
+}
+
+// hybridFractal is the hybrid fractal of a pattern of the steps of
+// the Mandelbrot set and the fractals like it
+type hybridFractal struct{}
+
+func (hybridFractal) Name() string                       { return "hybrid" }
+func (hybridFractal) Title() string                      { return "Hybrid" }
+func (hybridFractal) DefaultView() (complex128, float64) { return complex(-0.4, 0), 2 }
+
+func (hybridFractal) Iterate(z, c complex128, i, maxDepth int) (int, complex128) {
+	return hybrid(params.hybrid, z, c, i, maxDepth)
+}
+
+// The pattern iterated when the hybrid fractal is chosen
+var userHybrid = "MMB"
+
+// The letters of the steps of hybrid patterns
+const hybridSteps = "MBTCU"
+
+// checkHybridPattern checks and normalises a hybrid pattern
+func checkHybridPattern(s string) (string, error) {
+	pattern := strings.ToUpper(s)
+	if pattern == "" || strings.Trim(pattern, hybridSteps) != "" {
+		return "", fmt.Errorf("hybrid pattern must be made of %s not %q", hybridSteps, s)
+	}
+	return pattern, nil
+}
+
+// checkHybridFlag checks --hybrid and switches to it if set
+func checkHybridFlag() error {
+	if *hybridFlag == "" {
+		return nil
+	}
+	pattern, err := checkHybridPattern(*hybridFlag)
+	if err != nil {
+		return fmt.Errorf("--hybrid: %w", err)
+	}
+	userHybrid = pattern
+	setFractal(hybridFractal{})
+	return nil
+}
+
+// setHybrid iterates the hybrid pattern, staying at the same view if
+// the hybrid fractal is showing already
+func setHybrid(pattern string) error {
+	pattern, err := checkHybridPattern(pattern)
+	if err != nil {
+		return err
+	}
+	userHybrid = pattern
+	if _, ok := params.fractal.(hybridFractal); !ok {
+		setFractal(hybridFractal{})
+	}
+	params.hybrid = pattern
+	return nil
+}
+
+// hybrid iterates the hybrid fractal from iteration i until it
+// escapes or reaches maxDepth iterations
+//
+// Each iteration is the step of the pattern for that iteration, with
+// the pattern repeating, so the same point iterates the same way
+// wherever it is carried on from. The steps are those of the fractals
+// of their own.
+func hybrid(pattern string, z, c complex128, i, maxDepth int) (int, complex128) {
+	x, y := real(z), imag(z)
+	cx, cy := real(c), imag(c)
+	for ; i < maxDepth; i++ {
+		if x*x+y*y >= escapeRadius*escapeRadius {
+			break
+		}
+		switch pattern[i%len(pattern)] {
+		case 'M':
+			x, y = x*x-y*y+cx, 2*x*y+cy
+		case 'B':
+			x, y = burningShipStep(x, y, cx, cy)
+		case 'T':
+			x, y = tricornStep(x, y, cx, cy)
+		case 'C':
+			x, y = celticStep(x, y, cx, cy)
+		case 'U':
+			x, y = buffaloStep(x, y, cx, cy)
+		}
+	}
+	return i, complex(x, y)
+}
@@ -0,0 +1,613 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// The messages of the user interface translated from English, keyed
+// by language code then the English text or format string.
+//
+// Anything missing from a catalog is shown in English.
+var catalogs = map[string]map[string]string{
+	"de": {
+		"Terminal Mandlebrot by ncw":                            "Terminal-Mandelbrot von ncw",
+		"• =/- or left/right click to zoom, +/_ to zoom finely": "• =/- oder Links-/Rechtsklick zum Zoomen, +/_ für feines Zoomen",
+		"• drag or flick with the mouse to pan":                 "• mit der Maus ziehen oder schnippen zum Verschieben",
+		"• [/] to change depth, | to set it from the zoom":      "• [/] ändert die Tiefe, | setzt sie nach dem Zoom",
+		"• h/i toggle help/info, </> to change the text size":   "• h/i Hilfe/Info ein/aus, </> ändert die Textgröße",
+		"• q/ESC/c-C to quit":                                   "• q/ESC/c-C zum Beenden",
+		"• r to reset":                                          "• r zum Zurücksetzen",
+		"• u/backspace to undo, U to redo":                      "• u/Rücktaste macht rückgängig, U stellt wieder her",
+		"• space to start/stop fly-in zoom":                     "• Leertaste startet/stoppt den Flug hinein",
+		"• Center %s":                                           "• Mitte %s",
+		"• Radius %g":                                           "• Radius %g",
+		"• Flame samples %d":                                    "• Flammenproben %d",
+		"• Depth %d (refined to %d)":                            "• Tiefe %d (verfeinert auf %d)",
+		"• Depth %d":                                            "• Tiefe %d",
+		"• Antialiased with %d samples":                         "• Kantengeglättet mit %d Proben",
+		"• Fly-in %.2f doublings/s at 1/%d resolution":          "• Flug %.2f Verdopplungen/s bei 1/%d Auflösung",
+		"• Time %s (%d x %d)":                                   "• Zeit %s (%d x %d)",
+		"• Memory %s of %s (%d tiles, %d prefetched)":           "• Speicher %s von %s (%d Kacheln, %d vorab berechnet)",
+		"Zoom to radius:":                                       "Zoomen auf Radius:",
+		"bad radius %q":                                         "ungültiger Radius %q",
+		"Theme %s":                                              "Farbschema %s",
+		"Mandelbrot set":                                        "Mandelbrot-Menge",
+		"• Julia set of %g":                                     "• Julia-Menge von %g",
+		"Bookmark failed: %v":                                   "Lesezeichen fehlgeschlagen: %v",
+		"Bookmarked %g":                                         "Lesezeichen für %g gesetzt",
+		"• ←↑↓→ to pan, with shift/alt for fine steps, ctrl for coarse":   "• ←↑↓→ zum Verschieben, mit Umschalt/Alt fein, mit Strg grob",
+		"• ` or ctrl-^ to jump back to the last view and again to return": "• ` oder Strg-^ springt zur letzten Ansicht und wieder zurück",
+		"Journal failed: %v":        "Tagebuch fehlgeschlagen: %v",
+		"Note:":                     "Notiz:",
+		"Note saved in the journal": "Notiz im Tagebuch gespeichert",
+		"• b or ctrl-click to bookmark, B to list the bookmarks":           "• b oder Strg-Klick setzt ein Lesezeichen, B listet die Lesezeichen",
+		"• a to write a note in the journal":                               "• a schreibt eine Notiz ins Tagebuch",
+		"Bookmarked without a thumbnail: %v":                               "Lesezeichen ohne Vorschaubild gesetzt: %v",
+		"No bookmarks yet - press b to add one":                            "Noch keine Lesezeichen - b fügt eins hinzu",
+		"radius %g, depth %d":                                              "Radius %g, Tiefe %d",
+		" - Julia set of %g":                                               " - Julia-Menge von %g",
+		"Bookmarks - ↑↓ to choose, enter to go, x to delete, esc to close": "Lesezeichen - ↑↓ wählt, Enter springt hin, x löscht, Esc schließt",
+		"Save palette as:":                                                 "Palette speichern unter:",
+		"Palette saved to %s":                                              "Palette in %s gespeichert",
+		"Reading the recent sessions failed: %v":                           "Lesen der letzten Sitzungen fehlgeschlagen: %v",
+		" - recent session":                                                " - frühere Sitzung",
+		"Start from - ↑↓ to choose, enter to go, x to delete, esc for the whole set": "Start bei - ↑↓ wählt, Enter springt hin, x löscht, Esc für die ganze Menge",
+		"• Fixed point kernel": "• Festkomma-Kernel",
+		"• Float32 kernel":     "• Float32-Kernel",
+		"Coloring %s":          "Färbung %s",
+		"• e to start/stop exploring automatically": "• e startet/stoppt die automatische Erkundung",
+		"• %d workers":                             "• %d Worker",
+		"• Tuning workers, trying %d":              "• Worker werden abgestimmt, versuche %d",
+		"• %d workers, tuned at %.3g iterations/s": "• %d Worker, abgestimmt auf %.3g Iterationen/s",
+		"• double click to center, middle click or j for the Julia set under the mouse": "• Doppelklick zentriert, Mittelklick oder j für die Julia-Menge unter der Maus",
+		"• m to change the fractal, f toggle fractal flame, F for a new flame":          "• m wechselt das Fraktal, f Fraktalflamme ein/aus, F für eine neue Flamme",
+		"Fractal %s":    "Fraktal %s",
+		"Burning Ship":  "Burning Ship",
+		"Multibrot set": "Multibrot-Menge",
+		"Tricorn":       "Tricorn",
+		"• (/) to change the parameter of the fractal": "• (/) ändert den Parameter des Fraktals",
+		"The %s has no parameter to change":            "%s hat keinen Parameter zum Ändern",
+		"exponent":                                     "Exponent",
+		"relaxation":                                   "Relaxation",
+		"Nova":                                         "Nova",
+		"Phoenix":                                      "Phönix",
+		"p":                                            "p",
+		"Lyapunov fractal":                             "Ljapunow-Fraktal",
+		"The %s has no Julia sets":                     "%s hat keine Julia-Mengen",
+		"Magnet type I":                                "Magnet Typ I",
+		"Magnet type II":                               "Magnet Typ II",
+		"• Buddhabrot samples %d":                      "• Buddhabrot-Stichproben %d",
+		"• 1/2/3 to pick the red/green/blue layer of the Nebulabrot, {/} to change its limit": "• 1/2/3 wählt die rote/grüne/blaue Ebene des Nebulabrots, {/} ändert ihre Grenze",
+		"• Nebulabrot samples %d":        "• Nebulabrot-Stichproben %d",
+		"• Red, green and blue limits%s": "• Grenzen für Rot, Grün und Blau%s",
+		"• Exposure %.3g":                "• Belichtung %.3g",
+		"• g for the Buddhabrot, Nebulabrot, anti-Buddhabrot or the set, ,/. for exposure": "• g für Buddhabrot, Nebulabrot, Anti-Buddhabrot oder die Menge, ,/. Belichtung",
+		"• Anti-Buddhabrot samples %d": "• Anti-Buddhabrot-Stichproben %d",
+		"Celtic":                       "Keltisch",
+		"Buffalo":                      "Büffel",
+		"Lambda set":                   "Lambda-Menge",
+		"Formula":                      "Formel",
+		"Hybrid":                       "Hybrid",
+		"• J to start/stop morphing the Julia set, K to change its path, ;/' for speed": "• J startet/stoppt das Morphen der Julia-Menge, K ändert den Pfad, ;/' das Tempo",
+		"Morph path %s":   "Morph-Pfad %s",
+		"Morph period %v": "Morph-Dauer %v",
+		"• v to split the screen with the Julia set under the mouse on the right": "• v teilt den Bildschirm, rechts die Julia-Menge unter der Maus",
+		"• Julia pane of %.6g":   "• Julia-Bereich von %.6g",
+		"Collatz fractal":        "Collatz-Fraktal",
+		"Palette %s":             "Palette %s",
+		"Load palette from:":     "Palette laden aus:",
+		"Palette loaded from %s": "Palette aus %s geladen",
+		"• x to mix the gradient in RGB, HSV or OKLCH": "• x mischt den Verlauf in RGB, HSV oder OKLCH",
+		"• Palette %s mixed in %s":                     "• Palette %s gemischt in %s",
+		"Gradient mixed in %s":                         "Verlauf gemischt in %s",
+		"• n/N to change the density of the cyclic coloring, s/S to shift the palette": "• n/N ändert die Dichte der zyklischen Färbung, s/S verschiebt die Palette",
+		"Palette density %.3g":                  "Palettendichte %.3g",
+		"Palette offset %.3g":                   "Palettenversatz %.3g",
+		"• Palette density %.3g, offset %.3g":   "• Palettendichte %.3g, Versatz %.3g",
+		"• C to start/stop cycling the palette": "• C startet/stoppt das Durchlaufen der Palette",
+		"Palette cycling on":                    "Palettendurchlauf ein",
+		"Palette cycling off":                   "Palettendurchlauf aus",
+		"Lighting on":                           "Beleuchtung an",
+		"Lighting off":                          "Beleuchtung aus",
+		"Light from %g°":                        "Licht von %g°",
+		"• Lit from %g°":                        "• Beleuchtet von %g°",
+		"• L to light the set as a surface, w/W to turn the light round":                                                                  "• L beleuchtet die Menge als Fläche, w/W dreht das Licht",
+		"• c to change the coloring - smooth, by angle, by angle and depth, by histogram, cyclic, by distance, by stripes or exponential": "• c wechselt die Färbung - glatt, nach Winkel, nach Winkel und Tiefe, nach Histogramm, zyklisch, nach Abstand, mit Streifen oder exponentiell",
+		"Interior %s": "Inneres %s",
+		"• I to change the coloring inside the set - black, by modulus, by angle, by period or by distance": "• I wechselt die Färbung im Inneren der Menge - schwarz, nach Betrag, nach Winkel, nach Periode oder nach Abstand",
+		"Decompose %s": "Zerlegung %s",
+		"• d toggle binary decompose, D to switch it between binary and tinted, o toggle outlines of the cardioid and bulbs": "• d schaltet die binäre Zerlegung um, D wechselt zwischen binär und getönt, o schaltet die Umrisse der Kardioide und Knospen um",
+		"Random palette - P to save it": "Zufällige Palette - P speichert sie",
+		"• t to change the overlay theme, p to change the palette, R for a random one, P to save it, l to load one": "• t wechselt das Overlay-Design, p die Palette, R macht eine zufällige, P speichert sie, l lädt eine",
+		"unknown palette format %q - use .map, .ugr, .json or an image":                                             "unbekanntes Palettenformat %q - .map, .ugr, .json oder ein Bild verwenden",
+		"Adjusting %s, now %.3g": "Einstellung %s, jetzt %.3g",
+		"• A to pick gamma, brightness, contrast or saturation, 9/0 to lower/raise it": "• A wählt Gamma, Helligkeit, Kontrast oder Sättigung, 9/0 senkt/erhöht den Wert",
+		"• Gamma %.3g, brightness %.3g, contrast %.3g, saturation %.3g":                "• Gamma %.3g, Helligkeit %.3g, Kontrast %.3g, Sättigung %.3g",
+		"Tone %s": "Ton %s",
+		"• M to draw in color, gray or black and white": "• M zeichnet in Farbe, Grau oder Schwarzweiß",
+		"• Layers %s":                                       "• Ebenen %s",
+		"• Big float kernel with %d bits":                   "• Big-Float-Kernel mit %d Bit",
+		"• Perturbation kernel with %d bits":                "• Störungskernel mit %d Bit",
+		"• Double-double kernel":                            "• Double-Double-Kernel",
+		"• Float64 kernel":                                  "• Float64-Kernel",
+		"Auto depth on - depth %d":                          "Automatische Tiefe an - Tiefe %d",
+		"Auto depth off":                                    "Automatische Tiefe aus",
+		"Auto depth off - depth %d":                         "Automatische Tiefe aus - Tiefe %d",
+		"• Auto depth %d":                                   "• Automatische Tiefe %d",
+		"• Auto depth %d (refined to %d)":                   "• Automatische Tiefe %d (verfeinert auf %d)",
+		"• SIMD kernel with %s":                             "• SIMD-Kernel mit %s",
+		"• Farming out to %s, %d of %d connections working": "• Verteilt auf %s, %d von %d Verbindungen aktiv",
+		"• z to zoom to a radius, Z to zoom onto the minibrot in the view": "• z zum Zoomen auf einen Radius, Z zum Zoomen auf das Mini-Apfelmännchen im Bild",
+		"Julia sets have no minibrots to find":                             "Julia-Mengen haben keine Mini-Apfelmännchen",
+		"The %s has no minibrots to find":                                  "%s hat keine Mini-Apfelmännchen",
+		"No minibrot found in the view - zoom in or raise the depth":       "Kein Mini-Apfelmännchen im Bild gefunden - hineinzoomen oder die Tiefe erhöhen",
+		"Newton's method didn't find the minibrot of period %d":            "Das Newton-Verfahren hat das Mini-Apfelmännchen der Periode %d nicht gefunden",
+		"The minibrot of period %d is too small to zoom onto":              "Das Mini-Apfelmännchen der Periode %d ist zu klein zum Hineinzoomen",
+		"Minibrot of period %d, size %.3g":                                 "Mini-Apfelmännchen der Periode %d, Größe %.3g",
+		"• GPU kernel on %s":                                               "• GPU-Kernel auf %s",
+		"unknown palette format %q - use .map, .ugr or .json":              "unbekanntes Palettenformat %q - .map, .ugr oder .json verwenden",
+	},
+	"es": {
+		"Terminal Mandlebrot by ncw":                            "Mandelbrot de terminal por ncw",
+		"• =/- or left/right click to zoom, +/_ to zoom finely": "• =/- o clic izquierdo/derecho para ampliar, +/_ para ampliar con precisión",
+		"• drag or flick with the mouse to pan":                 "• arrastra o lanza con el ratón para desplazar",
+		"• [/] to change depth, | to set it from the zoom":      "• [/] para cambiar la profundidad, | para ajustarla al zoom",
+		"• h/i toggle help/info, </> to change the text size":   "• h/i muestra ayuda/información, </> cambia el tamaño del texto",
+		"• q/ESC/c-C to quit":                                   "• q/ESC/c-C para salir",
+		"• r to reset":                                          "• r para reiniciar",
+		"• u/backspace to undo, U to redo":                      "• u/retroceso para deshacer, U para rehacer",
+		"• space to start/stop fly-in zoom":                     "• espacio inicia/detiene el vuelo hacia dentro",
+		"• Center %s":                                           "• Centro %s",
+		"• Radius %g":                                           "• Radio %g",
+		"• Flame samples %d":                                    "• Muestras de llama %d",
+		"• Depth %d (refined to %d)":                            "• Profundidad %d (refinada a %d)",
+		"• Depth %d":                                            "• Profundidad %d",
+		"• Antialiased with %d samples":                         "• Suavizado con %d muestras",
+		"• Fly-in %.2f doublings/s at 1/%d resolution":          "• Vuelo %.2f duplicaciones/s a 1/%d de resolución",
+		"• Time %s (%d x %d)":                                   "• Tiempo %s (%d x %d)",
+		"• Memory %s of %s (%d tiles, %d prefetched)":           "• Memoria %s de %s (%d teselas, %d precalculadas)",
+		"Zoom to radius:":                                       "Ampliar al radio:",
+		"bad radius %q":                                         "radio no válido %q",
+		"Theme %s":                                              "Tema %s",
+		"Mandelbrot set":                                        "Conjunto de Mandelbrot",
+		"• Julia set of %g":                                     "• Conjunto de Julia de %g",
+		"Bookmark failed: %v":                                   "Falló el marcador: %v",
+		"Bookmarked %g":                                         "Marcador guardado en %g",
+		"• ←↑↓→ to pan, with shift/alt for fine steps, ctrl for coarse":   "• ←↑↓→ para desplazar, con mayús/alt pasos finos, con ctrl gruesos",
+		"• ` or ctrl-^ to jump back to the last view and again to return": "• ` o ctrl-^ vuelve a la vista anterior y otra vez para regresar",
+		"Journal failed: %v":        "Falló el diario: %v",
+		"Note:":                     "Nota:",
+		"Note saved in the journal": "Nota guardada en el diario",
+		"• b or ctrl-click to bookmark, B to list the bookmarks":           "• b o ctrl-clic guarda un marcador, B lista los marcadores",
+		"• a to write a note in the journal":                               "• a escribe una nota en el diario",
+		"Bookmarked without a thumbnail: %v":                               "Marcador guardado sin miniatura: %v",
+		"No bookmarks yet - press b to add one":                            "Aún no hay marcadores - pulsa b para añadir uno",
+		"radius %g, depth %d":                                              "radio %g, profundidad %d",
+		" - Julia set of %g":                                               " - conjunto de Julia de %g",
+		"Bookmarks - ↑↓ to choose, enter to go, x to delete, esc to close": "Marcadores - ↑↓ para elegir, intro para ir, x para borrar, esc para cerrar",
+		"Save palette as:":                                                 "Guardar paleta como:",
+		"Palette saved to %s":                                              "Paleta guardada en %s",
+		"Reading the recent sessions failed: %v":                           "Error al leer las sesiones recientes: %v",
+		" - recent session":                                                " - sesión reciente",
+		"Start from - ↑↓ to choose, enter to go, x to delete, esc for the whole set": "Empezar en - ↑↓ para elegir, intro para ir, x para borrar, esc para el conjunto entero",
+		"• Fixed point kernel": "• Núcleo de punto fijo",
+		"• Float32 kernel":     "• Núcleo float32",
+		"Coloring %s":          "Coloreado %s",
+		"• e to start/stop exploring automatically": "• e inicia/detiene la exploración automática",
+		"• %d workers":                             "• %d trabajadores",
+		"• Tuning workers, trying %d":              "• Ajustando trabajadores, probando %d",
+		"• %d workers, tuned at %.3g iterations/s": "• %d trabajadores, ajustados a %.3g iteraciones/s",
+		"• double click to center, middle click or j for the Julia set under the mouse": "• doble clic para centrar, clic central o j para el conjunto de Julia bajo el ratón",
+		"• m to change the fractal, f toggle fractal flame, F for a new flame":          "• m cambia el fractal, f activa la llama fractal, F para una nueva llama",
+		"Fractal %s":    "Fractal %s",
+		"Burning Ship":  "Barco en llamas",
+		"Multibrot set": "Conjunto de Multibrot",
+		"Tricorn":       "Tricornio",
+		"• (/) to change the parameter of the fractal": "• (/) cambia el parámetro del fractal",
+		"The %s has no parameter to change":            "%s no tiene ningún parámetro que cambiar",
+		"exponent":                                     "exponente",
+		"relaxation":                                   "relajación",
+		"Nova":                                         "Nova",
+		"Phoenix":                                      "Fénix",
+		"p":                                            "p",
+		"Lyapunov fractal":                             "Fractal de Lyapunov",
+		"The %s has no Julia sets":                     "%s no tiene conjuntos de Julia",
+		"Magnet type I":                                "Magnet tipo I",
+		"Magnet type II":                               "Magnet tipo II",
+		"• Buddhabrot samples %d":                      "• Muestras del Buddhabrot %d",
+		"• 1/2/3 to pick the red/green/blue layer of the Nebulabrot, {/} to change its limit": "• 1/2/3 para elegir la capa roja/verde/azul del Nebulabrot, {/} para cambiar su límite",
+		"• Nebulabrot samples %d":        "• Muestras del Nebulabrot %d",
+		"• Red, green and blue limits%s": "• Límites rojo, verde y azul%s",
+		"• Exposure %.3g":                "• Exposición %.3g",
+		"• g for the Buddhabrot, Nebulabrot, anti-Buddhabrot or the set, ,/. for exposure": "• g para el Buddhabrot, Nebulabrot, anti-Buddhabrot o el conjunto, ,/. exposición",
+		"• Anti-Buddhabrot samples %d": "• Muestras del anti-Buddhabrot %d",
+		"Celtic":                       "Celta",
+		"Buffalo":                      "Búfalo",
+		"Lambda set":                   "Conjunto lambda",
+		"Formula":                      "Fórmula",
+		"Hybrid":                       "Híbrido",
+		"• J to start/stop morphing the Julia set, K to change its path, ;/' for speed": "• J inicia/detiene la transformación del conjunto de Julia, K cambia su camino, ;/' la velocidad",
+		"Morph path %s":   "Camino de transformación %s",
+		"Morph period %v": "Periodo de transformación %v",
+		"• v to split the screen with the Julia set under the mouse on the right": "• v divide la pantalla con el conjunto de Julia bajo el ratón a la derecha",
+		"• Julia pane of %.6g":   "• Panel de Julia de %.6g",
+		"Collatz fractal":        "Fractal de Collatz",
+		"Palette %s":             "Paleta %s",
+		"Load palette from:":     "Cargar paleta de:",
+		"Palette loaded from %s": "Paleta cargada de %s",
+		"• x to mix the gradient in RGB, HSV or OKLCH": "• x mezcla el degradado en RGB, HSV u OKLCH",
+		"• Palette %s mixed in %s":                     "• Paleta %s mezclada en %s",
+		"Gradient mixed in %s":                         "Degradado mezclado en %s",
+		"• n/N to change the density of the cyclic coloring, s/S to shift the palette": "• n/N cambia la densidad del coloreado cíclico, s/S desplaza la paleta",
+		"Palette density %.3g":                  "Densidad de la paleta %.3g",
+		"Palette offset %.3g":                   "Desplazamiento de la paleta %.3g",
+		"• Palette density %.3g, offset %.3g":   "• Densidad de la paleta %.3g, desplazamiento %.3g",
+		"• C to start/stop cycling the palette": "• C inicia/detiene el ciclo de la paleta",
+		"Palette cycling on":                    "Ciclo de la paleta activado",
+		"Palette cycling off":                   "Ciclo de la paleta desactivado",
+		"Lighting on":                           "Iluminación activada",
+		"Lighting off":                          "Iluminación desactivada",
+		"Light from %g°":                        "Luz desde %g°",
+		"• Lit from %g°":                        "• Iluminado desde %g°",
+		"• L to light the set as a surface, w/W to turn the light round":                                                                  "• L ilumina el conjunto como una superficie, w/W gira la luz",
+		"• c to change the coloring - smooth, by angle, by angle and depth, by histogram, cyclic, by distance, by stripes or exponential": "• c cambia el coloreado - suave, por ángulo, por ángulo y profundidad, por histograma, cíclico, por distancia, por franjas o exponencial",
+		"Interior %s": "Interior %s",
+		"• I to change the coloring inside the set - black, by modulus, by angle, by period or by distance": "• I cambia el coloreado del interior del conjunto - negro, por módulo, por ángulo, por período o por distancia",
+		"Decompose %s": "Descomposición %s",
+		"• d toggle binary decompose, D to switch it between binary and tinted, o toggle outlines of the cardioid and bulbs": "• d activa la descomposición binaria, D la cambia entre binaria y teñida, o activa los contornos del cardioide y los bulbos",
+		"Random palette - P to save it": "Paleta aleatoria - P para guardarla",
+		"• t to change the overlay theme, p to change the palette, R for a random one, P to save it, l to load one": "• t cambia el tema de la capa, p cambia la paleta, R crea una aleatoria, P la guarda, l carga una",
+		"unknown palette format %q - use .map, .ugr, .json or an image":                                             "formato de paleta desconocido %q - usa .map, .ugr, .json o una imagen",
+		"Adjusting %s, now %.3g": "Ajustando %s, ahora %.3g",
+		"• A to pick gamma, brightness, contrast or saturation, 9/0 to lower/raise it": "• A elige gamma, brillo, contraste o saturación, 9/0 lo baja/sube",
+		"• Gamma %.3g, brightness %.3g, contrast %.3g, saturation %.3g":                "• Gamma %.3g, brillo %.3g, contraste %.3g, saturación %.3g",
+		"Tone %s": "Tono %s",
+		"• M to draw in color, gray or black and white": "• M dibuja en color, gris o blanco y negro",
+		"• Layers %s":                                       "• Capas %s",
+		"• Big float kernel with %d bits":                   "• Núcleo big float con %d bits",
+		"• Perturbation kernel with %d bits":                "• Núcleo de perturbación con %d bits",
+		"• Double-double kernel":                            "• Núcleo double-double",
+		"• Float64 kernel":                                  "• Núcleo float64",
+		"Auto depth on - depth %d":                          "Profundidad automática activada - profundidad %d",
+		"Auto depth off":                                    "Profundidad automática desactivada",
+		"Auto depth off - depth %d":                         "Profundidad automática desactivada - profundidad %d",
+		"• Auto depth %d":                                   "• Profundidad automática %d",
+		"• Auto depth %d (refined to %d)":                   "• Profundidad automática %d (refinada a %d)",
+		"• SIMD kernel with %s":                             "• Núcleo SIMD con %s",
+		"• Farming out to %s, %d of %d connections working": "• Repartiendo con %s, %d de %d conexiones funcionando",
+		"• z to zoom to a radius, Z to zoom onto the minibrot in the view": "• z para ampliar a un radio, Z para ampliar al minibrot de la vista",
+		"Julia sets have no minibrots to find":                             "Los conjuntos de Julia no tienen minibrots",
+		"The %s has no minibrots to find":                                  "%s no tiene minibrots",
+		"No minibrot found in the view - zoom in or raise the depth":       "No se encontró ningún minibrot en la vista - amplía o sube la profundidad",
+		"Newton's method didn't find the minibrot of period %d":            "El método de Newton no encontró el minibrot de periodo %d",
+		"The minibrot of period %d is too small to zoom onto":              "El minibrot de periodo %d es demasiado pequeño para ampliarlo",
+		"Minibrot of period %d, size %.3g":                                 "Minibrot de periodo %d, tamaño %.3g",
+		"• GPU kernel on %s":                                               "• Núcleo de GPU en %s",
+		"unknown palette format %q - use .map, .ugr or .json":              "formato de paleta desconocido %q - usa .map, .ugr o .json",
+	},
+	"fr": {
+		"Terminal Mandlebrot by ncw":                            "Mandelbrot en terminal par ncw",
+		"• =/- or left/right click to zoom, +/_ to zoom finely": "• =/- ou clic gauche/droit pour zoomer, +/_ pour zoomer finement",
+		"• drag or flick with the mouse to pan":                 "• glisser ou lancer avec la souris pour se déplacer",
+		"• [/] to change depth, | to set it from the zoom":      "• [/] pour changer la profondeur, | pour la régler selon le zoom",
+		"• h/i toggle help/info, </> to change the text size":   "• h/i affiche l'aide/les infos, </> change la taille du texte",
+		"• q/ESC/c-C to quit":                                   "• q/ESC/c-C pour quitter",
+		"• r to reset":                                          "• r pour réinitialiser",
+		"• u/backspace to undo, U to redo":                      "• u/retour arrière pour annuler, U pour rétablir",
+		"• space to start/stop fly-in zoom":                     "• espace lance/arrête le vol vers l'intérieur",
+		"• Center %s":                                           "• Centre %s",
+		"• Radius %g":                                           "• Rayon %g",
+		"• Flame samples %d":                                    "• Échantillons de flamme %d",
+		"• Depth %d (refined to %d)":                            "• Profondeur %d (affinée à %d)",
+		"• Depth %d":                                            "• Profondeur %d",
+		"• Antialiased with %d samples":                         "• Anticrénelé avec %d échantillons",
+		"• Fly-in %.2f doublings/s at 1/%d resolution":          "• Vol %.2f doublements/s à 1/%d de résolution",
+		"• Time %s (%d x %d)":                                   "• Temps %s (%d x %d)",
+		"• Memory %s of %s (%d tiles, %d prefetched)":           "• Mémoire %s sur %s (%d tuiles, %d préchargées)",
+		"Zoom to radius:":                                       "Zoomer sur le rayon :",
+		"bad radius %q":                                         "rayon invalide %q",
+		"Theme %s":                                              "Thème %s",
+		"Mandelbrot set":                                        "Ensemble de Mandelbrot",
+		"• Julia set of %g":                                     "• Ensemble de Julia de %g",
+		"Bookmark failed: %v":                                   "Échec du signet : %v",
+		"Bookmarked %g":                                         "Signet ajouté à %g",
+		"• ←↑↓→ to pan, with shift/alt for fine steps, ctrl for coarse":   "• ←↑↓→ pour se déplacer, avec maj/alt par petits pas, ctrl par grands pas",
+		"• ` or ctrl-^ to jump back to the last view and again to return": "• ` ou ctrl-^ revient à la vue précédente, et encore pour y retourner",
+		"Journal failed: %v":        "Échec du journal : %v",
+		"Note:":                     "Note :",
+		"Note saved in the journal": "Note enregistrée dans le journal",
+		"• b or ctrl-click to bookmark, B to list the bookmarks":           "• b ou ctrl-clic ajoute un signet, B liste les signets",
+		"• a to write a note in the journal":                               "• a écrit une note dans le journal",
+		"Bookmarked without a thumbnail: %v":                               "Signet ajouté sans vignette : %v",
+		"No bookmarks yet - press b to add one":                            "Pas encore de signets - appuyez sur b pour en ajouter un",
+		"radius %g, depth %d":                                              "rayon %g, profondeur %d",
+		" - Julia set of %g":                                               " - ensemble de Julia de %g",
+		"Bookmarks - ↑↓ to choose, enter to go, x to delete, esc to close": "Signets - ↑↓ pour choisir, entrée pour y aller, x pour supprimer, échap pour fermer",
+		"Save palette as:":                                                 "Enregistrer la palette sous :",
+		"Palette saved to %s":                                              "Palette enregistrée dans %s",
+		"Reading the recent sessions failed: %v":                           "Échec de la lecture des sessions récentes : %v",
+		" - recent session":                                                " - session récente",
+		"Start from - ↑↓ to choose, enter to go, x to delete, esc for the whole set": "Commencer à - ↑↓ pour choisir, entrée pour y aller, x pour supprimer, échap pour l'ensemble entier",
+		"• Fixed point kernel": "• Noyau en virgule fixe",
+		"• Float32 kernel":     "• Noyau float32",
+		"Coloring %s":          "Coloration %s",
+		"• e to start/stop exploring automatically": "• e démarre/arrête l'exploration automatique",
+		"• %d workers":                             "• %d travailleurs",
+		"• Tuning workers, trying %d":              "• Réglage des travailleurs, essai de %d",
+		"• %d workers, tuned at %.3g iterations/s": "• %d travailleurs, réglés à %.3g itérations/s",
+		"• double click to center, middle click or j for the Julia set under the mouse": "• double clic pour centrer, clic du milieu ou j pour l'ensemble de Julia sous la souris",
+		"• m to change the fractal, f toggle fractal flame, F for a new flame":          "• m change de fractale, f active la flamme fractale, F pour une nouvelle flamme",
+		"Fractal %s":    "Fractale %s",
+		"Burning Ship":  "Burning Ship",
+		"Multibrot set": "Ensemble de Multibrot",
+		"Tricorn":       "Tricorne",
+		"• (/) to change the parameter of the fractal": "• (/) change le paramètre de la fractale",
+		"The %s has no parameter to change":            "%s n'a aucun paramètre à changer",
+		"exponent":                                     "exposant",
+		"relaxation":                                   "relaxation",
+		"Nova":                                         "Nova",
+		"Phoenix":                                      "Phénix",
+		"p":                                            "p",
+		"Lyapunov fractal":                             "Fractale de Lyapunov",
+		"The %s has no Julia sets":                     "%s n'a pas d'ensembles de Julia",
+		"Magnet type I":                                "Magnet type I",
+		"Magnet type II":                               "Magnet type II",
+		"• Buddhabrot samples %d":                      "• Échantillons du Buddhabrot %d",
+		"• 1/2/3 to pick the red/green/blue layer of the Nebulabrot, {/} to change its limit": "• 1/2/3 pour choisir la couche rouge/verte/bleue du Nebulabrot, {/} pour changer sa limite",
+		"• Nebulabrot samples %d":        "• Échantillons du Nebulabrot %d",
+		"• Red, green and blue limits%s": "• Limites rouge, vert et bleu%s",
+		"• Exposure %.3g":                "• Exposition %.3g",
+		"• g for the Buddhabrot, Nebulabrot, anti-Buddhabrot or the set, ,/. for exposure": "• g pour le Buddhabrot, Nebulabrot, anti-Buddhabrot ou l'ensemble, ,/. exposition",
+		"• Anti-Buddhabrot samples %d": "• Échantillons de l'anti-Buddhabrot %d",
+		"Celtic":                       "Celtique",
+		"Buffalo":                      "Buffle",
+		"Lambda set":                   "Ensemble lambda",
+		"Formula":                      "Formule",
+		"Hybrid":                       "Hybride",
+		"• J to start/stop morphing the Julia set, K to change its path, ;/' for speed": "• J démarre/arrête la transformation de l'ensemble de Julia, K change son chemin, ;/' la vitesse",
+		"Morph path %s":   "Chemin de transformation %s",
+		"Morph period %v": "Période de transformation %v",
+		"• v to split the screen with the Julia set under the mouse on the right": "• v partage l'écran avec l'ensemble de Julia sous la souris à droite",
+		"• Julia pane of %.6g":   "• Panneau de Julia de %.6g",
+		"Collatz fractal":        "Fractale de Collatz",
+		"Palette %s":             "Palette %s",
+		"Load palette from:":     "Charger la palette depuis :",
+		"Palette loaded from %s": "Palette chargée depuis %s",
+		"• x to mix the gradient in RGB, HSV or OKLCH": "• x mélange le dégradé en RGB, HSV ou OKLCH",
+		"• Palette %s mixed in %s":                     "• Palette %s mélangée en %s",
+		"Gradient mixed in %s":                         "Dégradé mélangé en %s",
+		"• n/N to change the density of the cyclic coloring, s/S to shift the palette": "• n/N change la densité de la coloration cyclique, s/S décale la palette",
+		"Palette density %.3g":                  "Densité de la palette %.3g",
+		"Palette offset %.3g":                   "Décalage de la palette %.3g",
+		"• Palette density %.3g, offset %.3g":   "• Densité de la palette %.3g, décalage %.3g",
+		"• C to start/stop cycling the palette": "• C lance/arrête le défilement de la palette",
+		"Palette cycling on":                    "Défilement de la palette activé",
+		"Palette cycling off":                   "Défilement de la palette désactivé",
+		"Lighting on":                           "Éclairage activé",
+		"Lighting off":                          "Éclairage désactivé",
+		"Light from %g°":                        "Lumière depuis %g°",
+		"• Lit from %g°":                        "• Éclairé depuis %g°",
+		"• L to light the set as a surface, w/W to turn the light round":                                                                  "• L éclaire l'ensemble comme une surface, w/W fait tourner la lumière",
+		"• c to change the coloring - smooth, by angle, by angle and depth, by histogram, cyclic, by distance, by stripes or exponential": "• c change la coloration - lisse, par angle, par angle et profondeur, par histogramme, cyclique, par distance, par rayures ou exponentielle",
+		"Interior %s": "Intérieur %s",
+		"• I to change the coloring inside the set - black, by modulus, by angle, by period or by distance": "• I change la coloration de l'intérieur de l'ensemble - noir, par module, par angle, par période ou par distance",
+		"Decompose %s": "Décomposition %s",
+		"• d toggle binary decompose, D to switch it between binary and tinted, o toggle outlines of the cardioid and bulbs": "• d active la décomposition binaire, D la fait passer de binaire à teintée, o active les contours de la cardioïde et des bulbes",
+		"Random palette - P to save it": "Palette aléatoire - P pour l'enregistrer",
+		"• t to change the overlay theme, p to change the palette, R for a random one, P to save it, l to load one": "• t change le thème de la surcouche, p change la palette, R en crée une aléatoire, P l'enregistre, l en charge une",
+		"unknown palette format %q - use .map, .ugr, .json or an image":                                             "format de palette inconnu %q - utilisez .map, .ugr, .json ou une image",
+		"Adjusting %s, now %.3g": "Réglage de %s, maintenant %.3g",
+		"• A to pick gamma, brightness, contrast or saturation, 9/0 to lower/raise it": "• A choisit le gamma, la luminosité, le contraste ou la saturation, 9/0 le baisse/l'augmente",
+		"• Gamma %.3g, brightness %.3g, contrast %.3g, saturation %.3g":                "• Gamma %.3g, luminosité %.3g, contraste %.3g, saturation %.3g",
+		"Tone %s": "Ton %s",
+		"• M to draw in color, gray or black and white": "• M dessine en couleur, en gris ou en noir et blanc",
+		"• Layers %s":                                       "• Calques %s",
+		"• Big float kernel with %d bits":                   "• Noyau big float à %d bits",
+		"• Perturbation kernel with %d bits":                "• Noyau de perturbation à %d bits",
+		"• Double-double kernel":                            "• Noyau double-double",
+		"• Float64 kernel":                                  "• Noyau float64",
+		"Auto depth on - depth %d":                          "Profondeur automatique activée - profondeur %d",
+		"Auto depth off":                                    "Profondeur automatique désactivée",
+		"Auto depth off - depth %d":                         "Profondeur automatique désactivée - profondeur %d",
+		"• Auto depth %d":                                   "• Profondeur automatique %d",
+		"• Auto depth %d (refined to %d)":                   "• Profondeur automatique %d (affinée à %d)",
+		"• SIMD kernel with %s":                             "• Noyau SIMD avec %s",
+		"• Farming out to %s, %d of %d connections working": "• Réparti sur %s, %d connexions sur %d actives",
+		"• z to zoom to a radius, Z to zoom onto the minibrot in the view": "• z pour zoomer à un rayon, Z pour zoomer sur le minibrot de la vue",
+		"Julia sets have no minibrots to find":                             "Les ensembles de Julia n'ont pas de minibrots",
+		"The %s has no minibrots to find":                                  "%s n'a pas de minibrots",
+		"No minibrot found in the view - zoom in or raise the depth":       "Aucun minibrot trouvé dans la vue - zoomez ou augmentez la profondeur",
+		"Newton's method didn't find the minibrot of period %d":            "La méthode de Newton n'a pas trouvé le minibrot de période %d",
+		"The minibrot of period %d is too small to zoom onto":              "Le minibrot de période %d est trop petit pour zoomer dessus",
+		"Minibrot of period %d, size %.3g":                                 "Minibrot de période %d, taille %.3g",
+		"• GPU kernel on %s":                                               "• Noyau GPU sur %s",
+		"unknown palette format %q - use .map, .ugr or .json":              "format de palette inconnu %q - utilisez .map, .ugr ou .json",
+	},
+	"ru": {
+		"Terminal Mandlebrot by ncw":                            "Мандельброт в терминале от ncw",
+		"• =/- or left/right click to zoom, +/_ to zoom finely": "• =/- или левый/правый щелчок для масштаба, +/_ для точного масштаба",
+		"• drag or flick with the mouse to pan":                 "• тяните или бросайте мышью для перемещения",
+		"• [/] to change depth, | to set it from the zoom":      "• [/] меняет глубину, | задаёт её по увеличению",
+		"• h/i toggle help/info, </> to change the text size":   "• h/i справка/информация, </> меняет размер текста",
+		"• q/ESC/c-C to quit":                                   "• q/ESC/c-C для выхода",
+		"• r to reset":                                          "• r для сброса",
+		"• u/backspace to undo, U to redo":                      "• u/backspace отменяет, U повторяет",
+		"• space to start/stop fly-in zoom":                     "• пробел запускает/останавливает полёт внутрь",
+		"• Center %s":                                           "• Центр %s",
+		"• Radius %g":                                           "• Радиус %g",
+		"• Flame samples %d":                                    "• Выборок пламени %d",
+		"• Depth %d (refined to %d)":                            "• Глубина %d (уточнена до %d)",
+		"• Depth %d":                                            "• Глубина %d",
+		"• Antialiased with %d samples":                         "• Сглажено по %d выборкам",
+		"• Fly-in %.2f doublings/s at 1/%d resolution":          "• Полёт %.2f удвоений/с при разрешении 1/%d",
+		"• Time %s (%d x %d)":                                   "• Время %s (%d x %d)",
+		"• Memory %s of %s (%d tiles, %d prefetched)":           "• Память %s из %s (%d плиток, %d заранее)",
+		"Zoom to radius:":                                       "Масштаб до радиуса:",
+		"bad radius %q":                                         "неверный радиус %q",
+		"Theme %s":                                              "Тема %s",
+		"Mandelbrot set":                                        "Множество Мандельброта",
+		"• Julia set of %g":                                     "• Множество Жюлиа для %g",
+		"Bookmark failed: %v":                                   "Не удалось сохранить закладку: %v",
+		"Bookmarked %g":                                         "Закладка на %g",
+		"• ←↑↓→ to pan, with shift/alt for fine steps, ctrl for coarse":   "• ←↑↓→ для перемещения, с shift/alt мелкий шаг, с ctrl крупный",
+		"• ` or ctrl-^ to jump back to the last view and again to return": "• ` или ctrl-^ к прошлому виду и обратно",
+		"Journal failed: %v":        "Ошибка журнала: %v",
+		"Note:":                     "Заметка:",
+		"Note saved in the journal": "Заметка сохранена в журнале",
+		"• b or ctrl-click to bookmark, B to list the bookmarks":           "• b или ctrl-щелчок для закладки, B для списка закладок",
+		"• a to write a note in the journal":                               "• a для заметки в журнале",
+		"Bookmarked without a thumbnail: %v":                               "Закладка сохранена без миниатюры: %v",
+		"No bookmarks yet - press b to add one":                            "Закладок пока нет - нажмите b, чтобы добавить",
+		"radius %g, depth %d":                                              "радиус %g, глубина %d",
+		" - Julia set of %g":                                               " - множество Жюлиа для %g",
+		"Bookmarks - ↑↓ to choose, enter to go, x to delete, esc to close": "Закладки - ↑↓ выбор, enter перейти, x удалить, esc закрыть",
+		"Save palette as:":                                                 "Сохранить палитру как:",
+		"Palette saved to %s":                                              "Палитра сохранена в %s",
+		"Reading the recent sessions failed: %v":                           "Не удалось прочитать последние сеансы: %v",
+		" - recent session":                                                " - недавний сеанс",
+		"Start from - ↑↓ to choose, enter to go, x to delete, esc for the whole set": "Начать с - ↑↓ выбор, enter перейти, x удалить, esc всё множество",
+		"• Fixed point kernel": "• Ядро с фиксированной точкой",
+		"• Float32 kernel":     "• Ядро float32",
+		"Coloring %s":          "Раскраска %s",
+		"• e to start/stop exploring automatically": "• e включает/выключает автоматическое исследование",
+		"• %d workers":                             "• %d потоков",
+		"• Tuning workers, trying %d":              "• Подбор числа потоков, пробую %d",
+		"• %d workers, tuned at %.3g iterations/s": "• %d потоков, подобрано при %.3g итераций/с",
+		"• double click to center, middle click or j for the Julia set under the mouse": "• двойной щелчок центрирует, средний щелчок или j для множества Жюлиа под мышью",
+		"• m to change the fractal, f toggle fractal flame, F for a new flame":          "• m меняет фрактал, f фрактальное пламя, F для нового пламени",
+		"Fractal %s":    "Фрактал %s",
+		"Burning Ship":  "Горящий корабль",
+		"Multibrot set": "Множество Мультиброта",
+		"Tricorn":       "Трикорн",
+		"• (/) to change the parameter of the fractal": "• (/) меняет параметр фрактала",
+		"The %s has no parameter to change":            "У фрактала %s нет параметра",
+		"exponent":                                     "показатель",
+		"relaxation":                                   "релаксация",
+		"Nova":                                         "Нова",
+		"Phoenix":                                      "Феникс",
+		"p":                                            "p",
+		"Lyapunov fractal":                             "Фрактал Ляпунова",
+		"The %s has no Julia sets":                     "У фрактала %s нет множеств Жюлиа",
+		"Magnet type I":                                "Магнит тип I",
+		"Magnet type II":                               "Магнит тип II",
+		"• Buddhabrot samples %d":                      "• Выборки Буддаброта %d",
+		"• 1/2/3 to pick the red/green/blue layer of the Nebulabrot, {/} to change its limit": "• 1/2/3 — выбрать красный/зелёный/синий слой Небулаброта, {/} — изменить его предел",
+		"• Nebulabrot samples %d":        "• Выборки Небулаброта %d",
+		"• Red, green and blue limits%s": "• Пределы красного, зелёного и синего%s",
+		"• Exposure %.3g":                "• Экспозиция %.3g",
+		"• g for the Buddhabrot, Nebulabrot, anti-Buddhabrot or the set, ,/. for exposure": "• g — Буддаброт, Небулаброт, анти-Буддаброт или множество, ,/. — экспозиция",
+		"• Anti-Buddhabrot samples %d": "• Выборки анти-Буддаброта %d",
+		"Celtic":                       "Кельтский",
+		"Buffalo":                      "Буйвол",
+		"Lambda set":                   "Лямбда-множество",
+		"Formula":                      "Формула",
+		"Hybrid":                       "Гибрид",
+		"• J to start/stop morphing the Julia set, K to change its path, ;/' for speed": "• J — запустить/остановить морфинг множества Жюлиа, K — сменить путь, ;/' — скорость",
+		"Morph path %s":   "Путь морфинга %s",
+		"Morph period %v": "Период морфинга %v",
+		"• v to split the screen with the Julia set under the mouse on the right": "• v — разделить экран, справа множество Жюлиа под мышью",
+		"• Julia pane of %.6g":   "• Панель Жюлиа для %.6g",
+		"Collatz fractal":        "Фрактал Коллатца",
+		"Palette %s":             "Палитра %s",
+		"Load palette from:":     "Загрузить палитру из:",
+		"Palette loaded from %s": "Палитра загружена из %s",
+		"• x to mix the gradient in RGB, HSV or OKLCH": "• x смешивает градиент в RGB, HSV или OKLCH",
+		"• Palette %s mixed in %s":                     "• Палитра %s, смешение в %s",
+		"Gradient mixed in %s":                         "Градиент смешивается в %s",
+		"• n/N to change the density of the cyclic coloring, s/S to shift the palette": "• n/N меняет плотность циклической раскраски, s/S сдвигает палитру",
+		"Palette density %.3g":                  "Плотность палитры %.3g",
+		"Palette offset %.3g":                   "Сдвиг палитры %.3g",
+		"• Palette density %.3g, offset %.3g":   "• Плотность палитры %.3g, сдвиг %.3g",
+		"• C to start/stop cycling the palette": "• C запускает/останавливает цикл палитры",
+		"Palette cycling on":                    "Цикл палитры включён",
+		"Palette cycling off":                   "Цикл палитры выключен",
+		"Lighting on":                           "Освещение включено",
+		"Lighting off":                          "Освещение выключено",
+		"Light from %g°":                        "Свет с %g°",
+		"• Lit from %g°":                        "• Освещено с %g°",
+		"• L to light the set as a surface, w/W to turn the light round":                                                                  "• L освещает множество как поверхность, w/W поворачивает свет",
+		"• c to change the coloring - smooth, by angle, by angle and depth, by histogram, cyclic, by distance, by stripes or exponential": "• c меняет раскраску - плавная, по углу, по углу и глубине, по гистограмме, циклическая, по расстоянию, полосами или экспоненциальная",
+		"Interior %s": "Внутренность %s",
+		"• I to change the coloring inside the set - black, by modulus, by angle, by period or by distance": "• I меняет раскраску внутри множества - чёрная, по модулю, по углу, по периоду или по расстоянию",
+		"Decompose %s": "Разложение %s",
+		"• d toggle binary decompose, D to switch it between binary and tinted, o toggle outlines of the cardioid and bulbs": "• d включает двоичное разложение, D переключает его между двоичным и тонированным, o включает контуры кардиоиды и почек",
+		"Random palette - P to save it": "Случайная палитра - P чтобы сохранить",
+		"• t to change the overlay theme, p to change the palette, R for a random one, P to save it, l to load one": "• t меняет тему наложения, p меняет палитру, R создаёт случайную, P сохраняет её, l загружает",
+		"unknown palette format %q - use .map, .ugr, .json or an image":                                             "неизвестный формат палитры %q - используйте .map, .ugr, .json или изображение",
+		"Adjusting %s, now %.3g": "Настройка %s, сейчас %.3g",
+		"• A to pick gamma, brightness, contrast or saturation, 9/0 to lower/raise it": "• A выбирает гамму, яркость, контраст или насыщенность, 9/0 уменьшает/увеличивает",
+		"• Gamma %.3g, brightness %.3g, contrast %.3g, saturation %.3g":                "• Гамма %.3g, яркость %.3g, контраст %.3g, насыщенность %.3g",
+		"Tone %s": "Тон %s",
+		"• M to draw in color, gray or black and white": "• M рисует в цвете, в оттенках серого или чёрно-белым",
+		"• Layers %s":                                       "• Слои %s",
+		"• Big float kernel with %d bits":                   "• Ядро big float, %d бит",
+		"• Perturbation kernel with %d bits":                "• Ядро возмущений, %d бит",
+		"• Double-double kernel":                            "• Ядро double-double",
+		"• Float64 kernel":                                  "• Ядро float64",
+		"Auto depth on - depth %d":                          "Автоглубина включена - глубина %d",
+		"Auto depth off":                                    "Автоглубина выключена",
+		"Auto depth off - depth %d":                         "Автоглубина выключена - глубина %d",
+		"• Auto depth %d":                                   "• Автоглубина %d",
+		"• Auto depth %d (refined to %d)":                   "• Автоглубина %d (уточнена до %d)",
+		"• SIMD kernel with %s":                             "• SIMD-ядро с %s",
+		"• Farming out to %s, %d of %d connections working": "• Раздача на %s, работают %d из %d соединений",
+		"• z to zoom to a radius, Z to zoom onto the minibrot in the view": "• z — приблизить до радиуса, Z — приблизить миниброт в поле зрения",
+		"Julia sets have no minibrots to find":                             "У множеств Жюлиа нет минибротов",
+		"The %s has no minibrots to find":                                  "У фрактала %s нет минибротов",
+		"No minibrot found in the view - zoom in or raise the depth":       "Минибротов в поле зрения не найдено — приблизьте или увеличьте глубину",
+		"Newton's method didn't find the minibrot of period %d":            "Метод Ньютона не нашёл миниброт периода %d",
+		"The minibrot of period %d is too small to zoom onto":              "Миниброт периода %d слишком мал для приближения",
+		"Minibrot of period %d, size %.3g":                                 "Миниброт периода %d, размер %.3g",
+		"• GPU kernel on %s":                                               "• Ядро GPU на %s",
+		"unknown palette format %q - use .map, .ugr or .json":              "неизвестный формат палитры %q - используйте .map, .ugr или .json",
+	},
+}
+
+// The catalog for the language in use, nil for English
+var catalog map[string]string
+
+// setLanguage selects the catalog for lang, which may be a locale like
+// fr_FR.UTF-8, returning false if there isn't one.
+func setLanguage(lang string) bool {
+	lang = strings.ToLower(lang)
+	if i := strings.IndexAny(lang, "_.@-"); i >= 0 {
+		lang = lang[:i]
+	}
+	if lang == "en" || lang == "c" || lang == "posix" {
+		catalog = nil
+		return true
+	}
+	c, ok := catalogs[lang]
+	if ok {
+		catalog = c
+	}
+	return ok
+}
+
+// chooseLanguage selects the language from the config or the locale
+// of the environment
+func chooseLanguage() error {
+	if cfg.Language != "" {
+		if !setLanguage(cfg.Language) {
+			return fmt.Errorf("unknown language %q", cfg.Language)
+		}
+		return nil
+	}
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if lang := os.Getenv(env); lang != "" {
+			setLanguage(lang)
+			return nil
+		}
+	}
+	return nil
+}
+
+// tr returns the translation of the English text s
+func tr(s string) string {
+	if t, ok := catalog[s]; ok {
+		return t
+	}
+	return s
+}
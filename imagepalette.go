@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/color"
+	_ "image/jpeg" // so image.Decode reads JPEGs
+	"sort"
+)
+
+// Flags
+var (
+	paletteImageFlag = flag.String("palette-from-image", "", "Make the palette to start with from the main colors of this PNG or JPEG")
+)
+
+// Settings for making palettes from images
+const (
+	imagePaletteColors = 8     // number of colors taken from the image
+	imageSamples       = 65536 // most pixels looked at
+)
+
+// parseImagePalette makes a gradient from the main colors of the PNG
+// or JPEG image in data, running from the darkest to the lightest
+//
+// The colors are found by median cut - the pixels are split in two at
+// the median of the channel which varies most, then the box of pixels
+// with the widest channel is split the same way, until there are
+// imagePaletteColors boxes, and each color is the average of a box.
+func parseImagePalette(data []byte) ([]gradientStop, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	step := 1
+	for (b.Dx()/step)*(b.Dy()/step) > imageSamples {
+		step++
+	}
+	var pixels [][3]uint8
+	for y := b.Min.Y; y < b.Max.Y; y += step {
+		for x := b.Min.X; x < b.Max.X; x += step {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			if c.A == 0 {
+				continue
+			}
+			pixels = append(pixels, [3]uint8{c.R, c.G, c.B})
+		}
+	}
+	colors := medianCut(pixels, imagePaletteColors)
+	sort.Slice(colors, func(i, j int) bool {
+		li, _, _ := rgbToOklch(colors[i])
+		lj, _, _ := rgbToOklch(colors[j])
+		return li < lj
+	})
+	return evenStops(colors), nil
+}
+
+// medianCut returns up to n colors which stand for the pixels
+func medianCut(pixels [][3]uint8, n int) []color.RGBA {
+	if len(pixels) == 0 {
+		return nil
+	}
+	boxes := [][][3]uint8{pixels}
+	for len(boxes) < n {
+		best, bestChannel, bestRange := -1, 0, 0
+		for i, box := range boxes {
+			channel, r := widestChannel(box)
+			if r > bestRange {
+				best, bestChannel, bestRange = i, channel, r
+			}
+		}
+		if best < 0 {
+			// Every box is a single color
+			break
+		}
+		box := boxes[best]
+		sort.Slice(box, func(i, j int) bool { return box[i][bestChannel] < box[j][bestChannel] })
+		boxes[best] = box[:len(box)/2]
+		boxes = append(boxes, box[len(box)/2:])
+	}
+	colors := make([]color.RGBA, len(boxes))
+	for i, box := range boxes {
+		var sum [3]int
+		for _, p := range box {
+			for k := range sum {
+				sum[k] += int(p[k])
+			}
+		}
+		n := len(box)
+		colors[i] = color.RGBA{uint8((sum[0] + n/2) / n), uint8((sum[1] + n/2) / n), uint8((sum[2] + n/2) / n), 255}
+	}
+	return colors
+}
+
+// widestChannel returns which channel of the pixels varies most and
+// by how much
+func widestChannel(pixels [][3]uint8) (channel, width int) {
+	lo, hi := [3]uint8{255, 255, 255}, [3]uint8{}
+	for _, p := range pixels {
+		for k := range p {
+			lo[k], hi[k] = min(lo[k], p[k]), max(hi[k], p[k])
+		}
+	}
+	for k := range lo {
+		if int(hi[k])-int(lo[k]) > width {
+			channel, width = k, int(hi[k])-int(lo[k])
+		}
+	}
+	return channel, width
+}
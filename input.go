@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Modifiers termbox doesn't report which are decoded from the raw
+// input and added to the events
+const (
+	modShift termbox.Modifier = 1 << 5
+	modCtrl  termbox.Modifier = 1 << 6
+)
+
+// Escape sequences to turn on and off the kitty keyboard protocol so
+// keys with modifiers can be told apart, eg Ctrl+= from =
+const (
+	enableKeyboardProtocol  = "\033[>1u"
+	disableKeyboardProtocol = "\033[<u"
+)
+
+// Escape sequences to turn on and off reporting of the terminal
+// gaining and losing focus as ESC [ I and ESC [ O
+const (
+	enableFocusReporting  = "\033[?1004h"
+	disableFocusReporting = "\033[?1004l"
+)
+
+// Escape sequences to turn on and off reporting of the mouse moving
+// with no button down, so the point under it is known
+const (
+	enableMouseMotion  = "\033[?1003h"
+	disableMouseMotion = "\033[?1003l"
+)
+
+// The types of the events sent when the terminal gains and loses
+// focus, when the mouse moves with no button down and when a click
+// turns out not to be a double click
+const (
+	eventFocusIn termbox.EventType = 101 + iota
+	eventFocusOut
+	eventHover
+	eventClick
+)
+
+// inputEvent is an event with the time it arrived
+type inputEvent struct {
+	termbox.Event
+	t time.Time
+}
+
+// Set while the terminal has the focus
+var focused = true
+
+// focusChanged notes the terminal gaining or losing the focus
+//
+// While it hasn't got it no animation frames or idle work are done so
+// termbrot doesn't use any CPU in a background tab.
+func focusChanged(in bool) {
+	if in && !focused {
+		// Carry on animating from where it stopped
+		lastTick = time.Now()
+	}
+	focused = in
+}
+
+// How long to wait for the rest of an escape sequence before taking
+// what has arrived of it as keys typed, eg Esc then [
+const sequenceTimeout = 50 * time.Millisecond
+
+// pollEvents reads the terminal input and sends the events to events
+//
+// The input is read raw so that the modifiers of mouse events can be
+// decoded, which termbox throws away. Everything else is parsed by
+// termbox as usual.
+func pollEvents() {
+	input := make(chan []byte)
+	go readInput(input)
+	var buf []byte
+	for {
+		var timeout <-chan time.Time
+		if len(buf) > 0 {
+			timeout = time.After(sequenceTimeout)
+		}
+		expired := false
+		select {
+		case in := <-input:
+			buf = append(buf, in...)
+		case <-timeout:
+			expired = true
+		}
+		var evs []termbox.Event
+		evs, buf = parseEvents(buf, expired)
+		now := time.Now()
+		for _, ev := range evs {
+			events <- inputEvent{Event: ev, t: now}
+		}
+	}
+}
+
+// readInput reads the raw terminal input and sends it to input,
+// sending any other events straight to events
+func readInput(input chan<- []byte) {
+	raw := make([]byte, 256)
+	for {
+		ev := termbox.PollRawEvent(raw)
+		if ev.Type != termbox.EventRaw {
+			events <- inputEvent{Event: ev, t: time.Now()}
+			continue
+		}
+		input <- slices.Clone(raw[:ev.N])
+	}
+}
+
+// parseEvents parses the complete events at the start of buf,
+// returning them and the rest of buf
+//
+// If expired is set the rest of an unfinished sequence isn't coming,
+// so what there is of it is taken as keys, ESC as Esc.
+func parseEvents(buf []byte, expired bool) ([]termbox.Event, []byte) {
+	var evs []termbox.Event
+	for len(buf) > 0 {
+		ev, n := parseInput(buf)
+		if n == 0 {
+			if !expired {
+				// Incomplete so wait for the rest
+				break
+			}
+			ev, n = expireInput(buf)
+		}
+		buf = buf[n:]
+		if ev.Type != termbox.EventNone {
+			evs = append(evs, ev)
+		}
+	}
+	return evs, buf
+}
+
+// expireInput takes the first byte of the unfinished sequence in buf
+// as Esc if it is ESC, or skips it otherwise, eg if it is part of a
+// UTF-8 character
+func expireInput(buf []byte) (termbox.Event, int) {
+	if buf[0] == '\033' {
+		return termbox.Event{Type: termbox.EventKey, Key: termbox.KeyEsc}, 1
+	}
+	return termbox.Event{Type: termbox.EventNone}, 1
+}
+
+// parseInput parses the first event from buf returning it and the
+// number of bytes used, or 0 if buf doesn't hold a complete event yet
+func parseInput(buf []byte) (termbox.Event, int) {
+	if bytes.HasPrefix(buf, []byte("\033[<")) {
+		return parseSGRMouse(buf)
+	}
+	if bytes.HasPrefix(buf, []byte("\033[I")) {
+		return termbox.Event{Type: eventFocusIn}, 3
+	}
+	if bytes.HasPrefix(buf, []byte("\033[O")) {
+		return termbox.Event{Type: eventFocusOut}, 3
+	}
+	if bytes.HasPrefix(buf, []byte("\033[")) {
+		if ev, n, ok := parseCSIKey(buf); ok {
+			return ev, n
+		}
+	}
+	if !utf8.FullRune(buf) {
+		return termbox.Event{}, 0
+	}
+	ev := termbox.ParseEvent(buf)
+	if ev.N == 0 {
+		// Not recognised so skip it
+		return termbox.Event{Type: termbox.EventNone}, 1
+	}
+	return ev, ev.N
+}
+
+// parseSGRMouse parses an xterm 1006 mouse report
+//
+//	ESC [ < button ; x ; y M (or m for a release)
+//
+// The button has 4 added for Shift, 8 for Alt and 16 for Ctrl, 32 for
+// motion and 64 for the wheel.
+func parseSGRMouse(buf []byte) (termbox.Event, int) {
+	end := bytes.IndexAny(buf, "Mm")
+	if end < 0 {
+		if len(buf) > 32 {
+			// Too long to be a mouse report
+			return termbox.Event{Type: termbox.EventNone}, len(buf)
+		}
+		return termbox.Event{}, 0
+	}
+	n := end + 1
+	none := termbox.Event{Type: termbox.EventNone}
+	fields := strings.Split(string(buf[3:end]), ";")
+	if len(fields) != 3 {
+		return none, n
+	}
+	var v [3]int
+	for i, field := range fields {
+		var err error
+		v[i], err = strconv.Atoi(field)
+		if err != nil {
+			return none, n
+		}
+	}
+	b := v[0]
+	if b&32 != 0 && b&3 == 3 {
+		// Motion with no button down
+		return termbox.Event{Type: eventHover, MouseX: v[1] - 1, MouseY: v[2] - 1}, n
+	}
+	ev := termbox.Event{Type: termbox.EventMouse, MouseX: v[1] - 1, MouseY: v[2] - 1}
+	switch {
+	case buf[end] == 'm':
+		ev.Key = termbox.MouseRelease
+	case b&64 != 0 && b&1 == 0:
+		ev.Key = termbox.MouseWheelUp
+	case b&64 != 0:
+		ev.Key = termbox.MouseWheelDown
+	case b&3 == 0:
+		ev.Key = termbox.MouseLeft
+	case b&3 == 1:
+		ev.Key = termbox.MouseMiddle
+	case b&3 == 2:
+		ev.Key = termbox.MouseRight
+	default:
+		ev.Key = termbox.MouseRelease
+	}
+	if b&4 != 0 {
+		ev.Mod |= modShift
+	}
+	if b&8 != 0 {
+		ev.Mod |= termbox.ModAlt
+	}
+	if b&16 != 0 {
+		ev.Mod |= modCtrl
+	}
+	if b&32 != 0 {
+		ev.Mod |= termbox.ModMotion
+	}
+	return ev, n
+}
+
+// decodeModifiers turns the modifier parameter of a key escape
+// sequence, which is 1 plus bits for Shift, Alt and Ctrl, into Mod bits
+func decodeModifiers(m int) (mod termbox.Modifier) {
+	m--
+	if m&1 != 0 {
+		mod |= modShift
+	}
+	if m&2 != 0 {
+		mod |= termbox.ModAlt
+	}
+	if m&4 != 0 {
+		mod |= modCtrl
+	}
+	return mod
+}
+
+// Keys sent as ESC [ n ~ by number n
+var tildeKeys = map[int]termbox.Key{
+	2: termbox.KeyInsert,
+	3: termbox.KeyDelete,
+	5: termbox.KeyPgup,
+	6: termbox.KeyPgdn,
+}
+
+// Keys sent as ESC [ 1 ; m X by final character X
+var letterKeys = map[byte]termbox.Key{
+	'A': termbox.KeyArrowUp,
+	'B': termbox.KeyArrowDown,
+	'C': termbox.KeyArrowRight,
+	'D': termbox.KeyArrowLeft,
+	'H': termbox.KeyHome,
+	'F': termbox.KeyEnd,
+}
+
+// Keys sent by the kitty keyboard protocol as ESC [ code u
+var codeKeys = map[int]termbox.Key{
+	9:   termbox.KeyTab,
+	13:  termbox.KeyEnter,
+	27:  termbox.KeyEsc,
+	127: termbox.KeyBackspace2,
+}
+
+// parseCSIKey parses the key escape sequences with modifiers which
+// termbox doesn't understand, and those of the kitty keyboard
+// protocol
+//
+//	ESC [ 1 ; m A     arrow keys with modifiers m, or just ESC [ A
+//	ESC [ n ; m ~     page up and down etc with modifiers m
+//	ESC [ code ; m u  any key as a unicode code point
+//
+// It returns false if buf doesn't hold one of these, leaving it to
+// termbox, and 0 bytes used if the sequence isn't all there yet, as
+// termbox would take the start of it for Esc.
+func parseCSIKey(buf []byte) (termbox.Event, int, bool) {
+	// Find the final byte of the sequence
+	end := 2
+	for end < len(buf) && (buf[end] >= '0' && buf[end] <= '9' || buf[end] == ';' || buf[end] == ':') {
+		end++
+	}
+	if end >= len(buf) {
+		if len(buf) > 32 {
+			// Too long to be a key
+			return termbox.Event{Type: termbox.EventNone}, len(buf), true
+		}
+		return termbox.Event{}, 0, true
+	}
+	final := buf[end]
+	var p []int
+	for _, field := range strings.Split(string(buf[2:end]), ";") {
+		// Ignore any sub parameters after a colon
+		field, _, _ = strings.Cut(field, ":")
+		v, _ := strconv.Atoi(field)
+		p = append(p, v)
+	}
+	mods := 1
+	if len(p) > 1 {
+		mods = p[1]
+	}
+	ev := termbox.Event{Type: termbox.EventKey, Mod: decodeModifiers(mods)}
+	switch final {
+	case 'u':
+		code := p[0]
+		if key, ok := codeKeys[code]; ok {
+			ev.Key = key
+		} else if ev.Mod&modCtrl != 0 && code >= 'a' && code <= 'z' {
+			ev.Key = termbox.KeyCtrlA + termbox.Key(code-'a')
+		} else if code == ' ' {
+			ev.Key = termbox.KeySpace
+		} else {
+			ev.Ch = rune(code)
+		}
+	case '~':
+		key, ok := tildeKeys[p[0]]
+		if !ok {
+			return termbox.Event{}, 0, false
+		}
+		ev.Key = key
+	default:
+		key, ok := letterKeys[final]
+		if !ok {
+			return termbox.Event{}, 0, false
+		}
+		ev.Key = key
+	}
+	return ev, end + 1, true
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nsf/termbox-go"
+)
+
+func TestParseInputCSIKey(t *testing.T) {
+	for _, test := range []struct {
+		in  string
+		n   int
+		key termbox.Key
+		ch  rune
+		mod termbox.Modifier
+	}{
+		{in: "\033[A", n: 3, key: termbox.KeyArrowUp},
+		{in: "\033[1;5A", n: 6, key: termbox.KeyArrowUp, mod: modCtrl},
+		{in: "\033[1;2D", n: 6, key: termbox.KeyArrowLeft, mod: modShift},
+		{in: "\033[5;3~", n: 6, key: termbox.KeyPgup, mod: termbox.ModAlt},
+		{in: "\033[27u", n: 5, key: termbox.KeyEsc},
+		{in: "\033[97;3u", n: 7, ch: 'a', mod: termbox.ModAlt},
+		{in: "\033[122;5u", n: 8, key: termbox.KeyCtrlZ, mod: modCtrl},
+		{in: "\033[1;5Ax", n: 6, key: termbox.KeyArrowUp, mod: modCtrl},
+		// Not finished yet so nothing is used
+		{in: "\033[", n: 0},
+		{in: "\033[1", n: 0},
+		{in: "\033[1;5", n: 0},
+		{in: "\033[122;5", n: 0},
+	} {
+		ev, n := parseInput([]byte(test.in))
+		if n != test.n {
+			t.Errorf("%q: used %d bytes, want %d", test.in, n, test.n)
+			continue
+		}
+		if n == 0 {
+			continue
+		}
+		if ev.Type != termbox.EventKey || ev.Key != test.key || ev.Ch != test.ch || ev.Mod != test.mod {
+			t.Errorf("%q: got type %d key %#x ch %q mod %d, want key %#x ch %q mod %d", test.in, ev.Type, ev.Key, ev.Ch, ev.Mod, test.key, test.ch, test.mod)
+		}
+	}
+}
+
+// A key split across reads after the CSI must be parsed once the rest
+// arrives, not taken for Esc which quits. A lone ESC is the Esc key.
+func TestParseInputSplitCSIKey(t *testing.T) {
+	in := "\033[1;5C"
+	for split := 2; split < len(in); split++ {
+		buf := []byte(in[:split])
+		var got []termbox.Event
+		for _, more := range []string{"", in[split:]} {
+			buf = append(buf, more...)
+			for len(buf) > 0 {
+				ev, n := parseInput(buf)
+				if n == 0 {
+					break
+				}
+				buf = buf[n:]
+				got = append(got, ev)
+			}
+		}
+		if len(got) != 1 || got[0].Key != termbox.KeyArrowRight || got[0].Mod != modCtrl {
+			t.Errorf("split at %d: got %+v, want one Ctrl+Right", split, got)
+		}
+	}
+}
+
+func TestParseInputTooLongCSI(t *testing.T) {
+	in := "\033[1;1;1;1;1;1;1;1;1;1;1;1;1;1;1;1;1"
+	ev, n := parseInput([]byte(in))
+	if n != len(in) || ev.Type != termbox.EventNone {
+		t.Errorf("got %+v using %d bytes, want it skipped", ev, n)
+	}
+}
+
+// An unfinished sequence whose rest never comes is taken as the keys
+// typed once it expires, rather than holding up the input.
+func TestParseEventsExpired(t *testing.T) {
+	buf := []byte("\033[1;")
+	evs, rest := parseEvents(buf, false)
+	if len(evs) != 0 || len(rest) != len(buf) {
+		t.Fatalf("got %+v leaving %q, want nothing until it expires", evs, rest)
+	}
+	evs, rest = parseEvents(rest, true)
+	if len(rest) != 0 {
+		t.Errorf("left %q, want everything used", rest)
+	}
+	want := []termbox.Event{
+		{Type: termbox.EventKey, Key: termbox.KeyEsc},
+		{Type: termbox.EventKey, Ch: '['},
+		{Type: termbox.EventKey, Ch: '1'},
+		{Type: termbox.EventKey, Ch: ';'},
+	}
+	if len(evs) != len(want) {
+		t.Fatalf("got %d events %+v, want %d", len(evs), evs, len(want))
+	}
+	for i, ev := range evs {
+		if ev.Type != want[i].Type || ev.Key != want[i].Key || ev.Ch != want[i].Ch {
+			t.Errorf("event %d: got type %d key %#x ch %q, want key %#x ch %q", i, ev.Type, ev.Key, ev.Ch, want[i].Key, want[i].Ch)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"math"
+	"math/cmplx"
+	"strings"
+)
+
+// Flags
+var (
+	interiorFlag = flag.String("interior", "black", "Coloring of the inside of the set - black, modulus, angle, period or distance")
+)
+
+// interiorMode is how the points inside the set are colored
+type interiorMode int
+
+// The interior modes
+const (
+	blackInterior    interiorMode = iota // all black
+	modulusInterior                      // by |z| at the end
+	angleInterior                        // by the angle of z at the end
+	periodInterior                       // by the period of the cycle the orbit is pulled into
+	distanceInterior                     // by the estimated distance to the edge of the set
+)
+
+// Names of the interior modes indexed by interiorMode
+var interiorNames = []string{"black", "modulus", "angle", "period", "distance"}
+
+// Settings for finding the cycle the orbit of a point inside the set
+// is pulled into
+const (
+	maxPeriod     = 1024  // longest cycle looked for
+	periodEpsilon = 1e-12 // squared distance z must come back within to have gone round the cycle
+	newtonSteps   = 8     // steps of Newton's method to pin the cycle down for the distance
+)
+
+// Settings for coloring the inside of the set
+const (
+	interiorWidth  = 32.0               // distance in pixels over which the inside goes from light to dark
+	goldenFraction = 0.6180339887498949 // fraction of the way round the palette each period moves on
+)
+
+// The interior mode in use
+var interior = blackInterior
+
+// checkInteriorFlag sets the interior mode from --interior
+func checkInteriorFlag() error {
+	if err := setInterior(*interiorFlag); err != nil {
+		return fmt.Errorf("--interior: %w", err)
+	}
+	return nil
+}
+
+// setInterior selects the interior mode called name
+func setInterior(name string) error {
+	for i, n := range interiorNames {
+		if n == name {
+			if interior != interiorMode(i) {
+				interior = interiorMode(i)
+				coloringID++
+				recolor()
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown interior %q - use one of %s", name, strings.Join(interiorNames, ", "))
+}
+
+// nextInterior cycles to the next interior mode
+func nextInterior() {
+	_ = setInterior(interiorNames[(int(interior)+1)%len(interiorNames)])
+	message = fmt.Sprintf(tr("Interior %s"), interiorNames[interior])
+}
+
+// flatInterior returns true if the points inside the set are all
+// colored the same, so the points found to be inside without
+// iterating them don't need their orbits
+func flatInterior() bool {
+	return interior == blackInterior
+}
+
+// needsInterior returns true if the interior mode needs the cycle of
+// the points inside the set found and the fractal being drawn has it
+// worked out
+//
+// The interior distance is only worked out for the Mandelbrot set
+// itself, not its Julia sets, and neither is worked out by the deep
+// zoom kernels.
+func needsInterior() bool {
+	if !isMandelbrot(params.fractal) || origin != nil {
+		return false
+	}
+	return interior == periodInterior || interior == distanceInterior && !params.julia
+}
+
+// interiorValue returns the period of the cycle the orbit of a point
+// inside the set which has got to z is pulled into, or with the
+// distance interior the estimated distance to the edge of the set,
+// or 0 if no cycle was found
+func interiorValue(z, c complex128) float64 {
+	period := findPeriod(z, c)
+	if period == 0 || interior != distanceInterior {
+		return float64(period)
+	}
+	return interiorDistance(z, c, period)
+}
+
+// findPeriod returns the period of the cycle z is on, or 0 if it
+// doesn't come back to itself within maxPeriod iterations
+func findPeriod(z, c complex128) int {
+	w := z
+	for p := 1; p <= maxPeriod; p++ {
+		w = w*w + c
+		if d := w - z; real(d)*real(d)+imag(d)*imag(d) < periodEpsilon {
+			return p
+		}
+	}
+	return 0
+}
+
+// interiorDistance estimates the distance from c to the edge of the
+// component of the set it is inside, whose cycle z is near with
+// period given
+//
+// The cycle is pinned down with Newton's method first as the estimate
+// needs the derivatives round it exactly.
+func interiorDistance(z, c complex128, period int) float64 {
+	for k := 0; k < newtonSteps; k++ {
+		w, dz := z, complex(1, 0)
+		for p := 0; p < period; p++ {
+			dz = 2 * w * dz
+			w = w*w + c
+		}
+		if dz == 1 {
+			break
+		}
+		z -= (w - z) / (dz - 1)
+	}
+	// Derivatives of the cycle by z and c and their derivatives by z
+	dz, dc, dzdz, dcdz := complex(1, 0), complex(0, 0), complex(0, 0), complex(0, 0)
+	for p := 0; p < period; p++ {
+		dcdz = 2 * (z*dcdz + dz*dc)
+		dc = 2*z*dc + 1
+		dzdz = 2 * (dz*dz + z*dzdz)
+		dz = 2 * z * dz
+		z = z*z + c
+	}
+	abs := cmplx.Abs(dz)
+	return (1 - abs*abs) / cmplx.Abs(dcdz+dzdz*dc/(1-dz))
+}
+
+// interiorColor returns the color of a point inside the set, with
+// pixels of size pixel
+func interiorColor(it iteration, pixel float64) color.RGBA {
+	switch interior {
+	case modulusInterior:
+		return paletteColor(math.Min(cmplx.Abs(it.z)/2, 1))
+	case angleInterior:
+		return paletteColor((cmplx.Phase(it.z) + math.Pi) / (2 * math.Pi))
+	case periodInterior:
+		if !needsInterior() || it.sum == 0 {
+			break
+		}
+		// Spread the periods round the palette by the golden ratio
+		// so neighbouring components differ
+		return paletteColor(math.Mod(it.sum*goldenFraction, 1))
+	case distanceInterior:
+		if !needsInterior() || it.sum == 0 {
+			break
+		}
+		return paletteColor(1 - math.Tanh(it.sum/(interiorWidth*pixel)))
+	}
+	return color.RGBA{0, 0, 0, 255}
+}
@@ -0,0 +1,296 @@
+//go:build opencl
+
+// Package opencl iterates the points of the Mandelbrot set on a GPU,
+// or any other OpenCL device with double precision, for termbrot's
+// gpu kernel
+//
+// It is only built with -tags opencl as it needs cgo, the OpenCL
+// headers and an OpenCL driver, which termbrot doesn't otherwise.
+package opencl
+
+/*
+#cgo CFLAGS: -DCL_TARGET_OPENCL_VERSION=120
+#cgo !darwin LDFLAGS: -lOpenCL
+#cgo darwin LDFLAGS: -framework OpenCL
+#include <stdlib.h>
+#ifdef __APPLE__
+#include <OpenCL/opencl.h>
+#else
+#include <CL/cl.h>
+#endif
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// The OpenCL C source of the kernel
+//
+// It is termbrot's mandelbrot without the unrolling, one point to each
+// work item. Contracting into fused multiply-adds is turned off so the
+// results are rounded the same as in Go.
+const source = `
+#pragma OPENCL EXTENSION cl_khr_fp64 : enable
+#pragma OPENCL FP_CONTRACT OFF
+
+__kernel void mandelbrot(__global double2 *zs, __global const double2 *cs, __global long *counts,
+	const long maxDepth, const double eps, const double interiorEpsilon, const long firstCheck)
+{
+	size_t k = get_global_id(0);
+	double x = zs[k].x, y = zs[k].y;
+	double cx = cs[k].x, cy = cs[k].y;
+	double x2 = x*x, y2 = y*y;
+	double dx = 1, dy = 0;
+	double sx = x, sy = y;
+	long check = firstCheck;
+	long i = 0;
+	if (i < maxDepth && x2+y2 < 4) {
+		// No derivative to start from yet
+		y = 2*x*y + cy;
+		x = x2 - y2 + cx;
+		x2 = x*x;
+		y2 = y*y;
+		i++;
+	}
+	for (; i < maxDepth; i++) {
+		if (x2+y2 >= 4) {
+			break;
+		}
+		double ndx = 2*(x*dx - y*dy);
+		dy = 2*(x*dy + y*dx);
+		dx = ndx;
+		if (dx*dx+dy*dy < interiorEpsilon) {
+			i = maxDepth;
+			break;
+		}
+		y = 2*x*y + cy;
+		x = x2 - y2 + cx;
+		x2 = x*x;
+		y2 = y*y;
+		if (i == check) {
+			sx = x;
+			sy = y;
+			check *= 2;
+		} else if ((x-sx)*(x-sx) + (y-sy)*(y-sy) <= eps) {
+			i = maxDepth;
+			break;
+		}
+	}
+	zs[k] = (double2)(x, y);
+	counts[k] = i;
+}
+`
+
+// Device is an OpenCL device set up to iterate points
+//
+// It isn't safe for concurrent use, as the buffers and the arguments
+// of the kernel are kept on the device between calls, so it should be
+// given as many points at a time as possible from one goroutine.
+type Device struct {
+	name    string
+	context C.cl_context
+	queue   C.cl_command_queue
+	kernel  C.cl_kernel
+
+	// The buffers for the points, big enough for size of them
+	z, c, counts C.cl_mem
+	size         int
+}
+
+// clError returns an error if status from the OpenCL call what isn't
+// success
+func clError(status C.cl_int, what string) error {
+	if status != C.CL_SUCCESS {
+		return fmt.Errorf("OpenCL %s failed with error %d", what, int(status))
+	}
+	return nil
+}
+
+// Open sets up the first OpenCL device with double precision,
+// preferring GPUs to the other devices OpenCL has
+//
+// Points which get their derivative below interiorEpsilon are inside
+// the set, and the orbit is first saved for the periodicity check
+// after firstCheck iterations, as in mandelbrot.
+func Open(interiorEpsilon float64, firstCheck int) (*Device, error) {
+	var platforms [16]C.cl_platform_id
+	var nPlatforms C.cl_uint
+	err := clError(C.clGetPlatformIDs(C.cl_uint(len(platforms)), &platforms[0], &nPlatforms), "clGetPlatformIDs")
+	if err != nil {
+		return nil, err
+	}
+	for _, kind := range []C.cl_device_type{C.CL_DEVICE_TYPE_GPU, C.CL_DEVICE_TYPE_ALL} {
+		for _, platform := range platforms[:nPlatforms] {
+			var devices [16]C.cl_device_id
+			var n C.cl_uint
+			if C.clGetDeviceIDs(platform, kind, C.cl_uint(len(devices)), &devices[0], &n) != C.CL_SUCCESS {
+				continue
+			}
+			for _, device := range devices[:n] {
+				var fp C.cl_device_fp_config
+				if C.clGetDeviceInfo(device, C.CL_DEVICE_DOUBLE_FP_CONFIG, C.size_t(unsafe.Sizeof(fp)), unsafe.Pointer(&fp), nil) == C.CL_SUCCESS && fp != 0 {
+					return newDevice(device, interiorEpsilon, firstCheck)
+				}
+			}
+		}
+	}
+	return nil, errors.New("no OpenCL device with double precision")
+}
+
+// newDevice compiles the kernel for device
+//
+// Nothing is released if it fails, as termbrot stops then.
+func newDevice(device C.cl_device_id, interiorEpsilon float64, firstCheck int) (*Device, error) {
+	g := &Device{name: deviceInfo(device, C.CL_DEVICE_NAME)}
+	var status C.cl_int
+	g.context = C.clCreateContext(nil, 1, &device, nil, nil, &status)
+	if err := clError(status, "clCreateContext"); err != nil {
+		return nil, err
+	}
+	g.queue = C.clCreateCommandQueue(g.context, device, 0, &status)
+	if err := clError(status, "clCreateCommandQueue"); err != nil {
+		return nil, err
+	}
+	text := C.CString(source)
+	defer C.free(unsafe.Pointer(text))
+	program := C.clCreateProgramWithSource(g.context, 1, &text, nil, &status)
+	if err := clError(status, "clCreateProgramWithSource"); err != nil {
+		return nil, err
+	}
+	if err := clError(C.clBuildProgram(program, 1, &device, nil, nil, nil), "clBuildProgram"); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, buildLog(program, device))
+	}
+	name := C.CString("mandelbrot")
+	defer C.free(unsafe.Pointer(name))
+	g.kernel = C.clCreateKernel(program, name, &status)
+	if err := clError(status, "clCreateKernel"); err != nil {
+		return nil, err
+	}
+	eps, first := C.cl_double(interiorEpsilon), C.cl_long(firstCheck)
+	if err := g.setArg(5, unsafe.Sizeof(eps), unsafe.Pointer(&eps)); err != nil {
+		return nil, err
+	}
+	if err := g.setArg(6, unsafe.Sizeof(first), unsafe.Pointer(&first)); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// deviceInfo returns the string param of device
+func deviceInfo(device C.cl_device_id, param C.cl_device_info) string {
+	var size C.size_t
+	if C.clGetDeviceInfo(device, param, 0, nil, &size) != C.CL_SUCCESS || size == 0 {
+		return "unknown device"
+	}
+	buf := make([]byte, size)
+	if C.clGetDeviceInfo(device, param, size, unsafe.Pointer(&buf[0]), nil) != C.CL_SUCCESS {
+		return "unknown device"
+	}
+	return strings.TrimRight(string(buf), "\x00")
+}
+
+// buildLog returns what went wrong compiling program for device
+func buildLog(program C.cl_program, device C.cl_device_id) string {
+	var size C.size_t
+	if C.clGetProgramBuildInfo(program, device, C.CL_PROGRAM_BUILD_LOG, 0, nil, &size) != C.CL_SUCCESS || size == 0 {
+		return "no build log"
+	}
+	buf := make([]byte, size)
+	if C.clGetProgramBuildInfo(program, device, C.CL_PROGRAM_BUILD_LOG, size, unsafe.Pointer(&buf[0]), nil) != C.CL_SUCCESS {
+		return "no build log"
+	}
+	return strings.TrimSpace(strings.TrimRight(string(buf), "\x00"))
+}
+
+// setArg sets argument i of the kernel to the size bytes at p
+func (g *Device) setArg(i int, size uintptr, p unsafe.Pointer) error {
+	return clError(C.clSetKernelArg(g.kernel, C.cl_uint(i), C.size_t(size), p), "clSetKernelArg")
+}
+
+// grow makes the buffers big enough for n points
+func (g *Device) grow(n int) error {
+	for _, m := range []C.cl_mem{g.z, g.c, g.counts} {
+		if m != nil {
+			C.clReleaseMemObject(m)
+		}
+	}
+	g.z, g.c, g.counts, g.size = nil, nil, nil, 0
+	var status C.cl_int
+	for i, b := range []struct {
+		m     *C.cl_mem
+		flags C.cl_mem_flags
+		size  int
+	}{
+		{&g.z, C.CL_MEM_READ_WRITE, 16},
+		{&g.c, C.CL_MEM_READ_ONLY, 16},
+		{&g.counts, C.CL_MEM_WRITE_ONLY, 8},
+	} {
+		*b.m = C.clCreateBuffer(g.context, b.flags, C.size_t(n*b.size), nil, &status)
+		if err := clError(status, "clCreateBuffer"); err != nil {
+			return err
+		}
+		// Passed from a copy as cgo can't be given pointers into g
+		m := *b.m
+		if err := g.setArg(i, unsafe.Sizeof(m), unsafe.Pointer(&m)); err != nil {
+			return err
+		}
+	}
+	g.size = n
+	return nil
+}
+
+// Name returns the name of the device
+func (g *Device) Name() string {
+	return g.name
+}
+
+// Mandelbrot iterates the points z with c from iteration 0 on the
+// device like mandelbrot does with the periodicity check of eps,
+// leaving their final z in z and their iterations in counts
+//
+// The points are all iterated by one launch of the kernel, waiting
+// only for the results to be read back. z is left alone if it fails.
+// There must be at least one point and maxDepth must be at least 1.
+func (g *Device) Mandelbrot(z, c []complex128, maxDepth int, eps float64, counts []int64) error {
+	n := len(z)
+	if n > g.size {
+		if err := g.grow(n); err != nil {
+			return err
+		}
+	}
+	zBytes, countBytes := C.size_t(16*n), C.size_t(8*n)
+	// The queue is in order, so only the last read needs to block
+	err := clError(C.clEnqueueWriteBuffer(g.queue, g.z, C.CL_FALSE, 0, zBytes, unsafe.Pointer(&z[0]), 0, nil, nil), "clEnqueueWriteBuffer")
+	if err == nil {
+		err = clError(C.clEnqueueWriteBuffer(g.queue, g.c, C.CL_FALSE, 0, zBytes, unsafe.Pointer(&c[0]), 0, nil, nil), "clEnqueueWriteBuffer")
+	}
+	depth, cycle := C.cl_long(maxDepth), C.cl_double(eps)
+	if err == nil {
+		err = g.setArg(3, unsafe.Sizeof(depth), unsafe.Pointer(&depth))
+	}
+	if err == nil {
+		err = g.setArg(4, unsafe.Sizeof(cycle), unsafe.Pointer(&cycle))
+	}
+	global := C.size_t(n)
+	if err == nil {
+		err = clError(C.clEnqueueNDRangeKernel(g.queue, g.kernel, 1, nil, &global, nil, 0, nil, nil), "clEnqueueNDRangeKernel")
+	}
+	out := make([]complex128, n)
+	if err == nil {
+		err = clError(C.clEnqueueReadBuffer(g.queue, g.counts, C.CL_FALSE, 0, countBytes, unsafe.Pointer(&counts[0]), 0, nil, nil), "clEnqueueReadBuffer")
+	}
+	if err == nil {
+		err = clError(C.clEnqueueReadBuffer(g.queue, g.z, C.CL_TRUE, 0, zBytes, unsafe.Pointer(&out[0]), 0, nil, nil), "clEnqueueReadBuffer")
+	}
+	if err != nil {
+		// Wait for anything still queued which uses the points
+		C.clFinish(g.queue)
+		return err
+	}
+	copy(z, out)
+	return nil
+}
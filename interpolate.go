@@ -0,0 +1,197 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// Flags
+var (
+	interpolateFlag = flag.String("interpolate", "rgb", "Color space the gradient is mixed in - rgb, hsv or oklch")
+)
+
+// interpolation is the color space the gradient is mixed in between
+// its stops
+type interpolation int
+
+// The interpolations
+const (
+	rgbInterpolation   interpolation = iota // straight lines between the colors, which go muddy in the middle
+	hsvInterpolation                        // round the hue circle keeping the saturation up
+	oklchInterpolation                      // round the hue of a perceptually even space, keeping the brightness even too
+)
+
+// Names of the interpolations indexed by interpolation
+var interpolationNames = []string{"rgb", "hsv", "oklch"}
+
+// The interpolation in use
+var gradientSpace = rgbInterpolation
+
+// checkInterpolateFlag sets the interpolation from --interpolate
+func checkInterpolateFlag() error {
+	if err := setInterpolation(*interpolateFlag); err != nil {
+		return fmt.Errorf("--interpolate: %w", err)
+	}
+	return nil
+}
+
+// setInterpolation selects the interpolation called name
+func setInterpolation(name string) error {
+	for i, n := range interpolationNames {
+		if n == name {
+			if gradientSpace != interpolation(i) {
+				gradientSpace = interpolation(i)
+				coloringID++
+				recolor()
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown interpolation %q - use one of %s", name, strings.Join(interpolationNames, ", "))
+}
+
+// nextInterpolation cycles to the next interpolation
+func nextInterpolation() {
+	_ = setInterpolation(interpolationNames[(int(gradientSpace)+1)%len(interpolationNames)])
+	message = fmt.Sprintf(tr("Gradient mixed in %s"), interpolationName())
+}
+
+// interpolationName returns the name of the color space the gradient
+// is mixed in for messages, eg "OKLCH"
+func interpolationName() string {
+	return strings.ToUpper(interpolationNames[gradientSpace])
+}
+
+// mixColors returns the color frac of the way from c1 to c2 in the
+// color space of the interpolation in use
+func mixColors(c1, c2 color.RGBA, frac float64) color.RGBA {
+	switch gradientSpace {
+	case hsvInterpolation:
+		h1, s1, v1 := rgbToHSV(c1)
+		h2, s2, v2 := rgbToHSV(c2)
+		h1, h2 = sameHue(h1, s1, h2, s2)
+		return hsvToRGB(mixHue(h1, h2, frac), lerp(s1, s2, frac), lerp(v1, v2, frac))
+	case oklchInterpolation:
+		l1, ch1, h1 := rgbToOklch(c1)
+		l2, ch2, h2 := rgbToOklch(c2)
+		h1, h2 = sameHue(h1, ch1, h2, ch2)
+		return oklchToRGB(lerp(l1, l2, frac), lerp(ch1, ch2, frac), mixHue(h1, h2, frac))
+	}
+	r := uint8(float64(c1.R)*(1-frac) + float64(c2.R)*frac)
+	g := uint8(float64(c1.G)*(1-frac) + float64(c2.G)*frac)
+	b := uint8(float64(c1.B)*(1-frac) + float64(c2.B)*frac)
+	return color.RGBA{r, g, b, 255}
+}
+
+// lerp returns the number frac of the way from a to b
+func lerp(a, b, frac float64) float64 {
+	return a + (b-a)*frac
+}
+
+// sameHue gives a gray, which has no hue of its own, the hue of the
+// other color so mixing them doesn't sweep round the hue circle
+func sameHue(h1, chroma1, h2, chroma2 float64) (float64, float64) {
+	const gray = 1e-4
+	switch {
+	case chroma1 < gray:
+		return h2, h2
+	case chroma2 < gray:
+		return h1, h1
+	}
+	return h1, h2
+}
+
+// mixHue returns the hue in turns frac of the way from h1 to h2 the
+// short way round the hue circle
+func mixHue(h1, h2, frac float64) float64 {
+	d := h2 - h1
+	d -= math.Round(d)
+	h := h1 + d*frac
+	return h - math.Floor(h)
+}
+
+// rgbToHSV converts col into its hue in turns, saturation and value
+func rgbToHSV(col color.RGBA) (h, s, v float64) {
+	r, g, b := float64(col.R)/255, float64(col.G)/255, float64(col.B)/255
+	v = max(r, g, b)
+	chroma := v - min(r, g, b)
+	if v > 0 {
+		s = chroma / v
+	}
+	switch {
+	case chroma == 0:
+	case v == r:
+		h = (g - b) / chroma
+	case v == g:
+		h = 2 + (b-r)/chroma
+	default:
+		h = 4 + (r-g)/chroma
+	}
+	h /= 6
+	return h - math.Floor(h), s, v
+}
+
+// hsvToRGB converts a hue in turns, saturation and value into RGB
+func hsvToRGB(h, s, v float64) color.RGBA {
+	channel := func(n float64) uint8 {
+		k := math.Mod(n+6*h, 6)
+		return uint8(255*(v-v*s*max(0, min(k, 4-k, 1))) + 0.5)
+	}
+	return color.RGBA{channel(5), channel(3), channel(1), 255}
+}
+
+// toLinear converts an sRGB channel from 0 to 255 to linear light
+func toLinear(c uint8) float64 {
+	x := float64(c) / 255
+	if x <= 0.04045 {
+		return x / 12.92
+	}
+	return math.Pow((x+0.055)/1.055, 2.4)
+}
+
+// fromLinear converts linear light to an sRGB channel from 0 to 255
+func fromLinear(x float64) uint8 {
+	x = min(max(x, 0), 1)
+	if x <= 0.0031308 {
+		x *= 12.92
+	} else {
+		x = 1.055*math.Pow(x, 1/2.4) - 0.055
+	}
+	return uint8(255*x + 0.5)
+}
+
+// rgbToOklch converts col into the lightness, chroma and hue in turns
+// of the OKLab color space
+func rgbToOklch(col color.RGBA) (l, chroma, h float64) {
+	r, g, b := toLinear(col.R), toLinear(col.G), toLinear(col.B)
+	lms := [3]float64{
+		math.Cbrt(0.4122214708*r + 0.5363325363*g + 0.0514459929*b),
+		math.Cbrt(0.2119034982*r + 0.6806995451*g + 0.1073969566*b),
+		math.Cbrt(0.0883024619*r + 0.2817188376*g + 0.6299787005*b),
+	}
+	l = 0.2104542553*lms[0] + 0.7936177850*lms[1] - 0.0040720468*lms[2]
+	aa := 1.9779984951*lms[0] - 2.4285922050*lms[1] + 0.4505937099*lms[2]
+	bb := 0.0259040371*lms[0] + 0.7827717662*lms[1] - 0.8086757660*lms[2]
+	h = math.Atan2(bb, aa) / (2 * math.Pi)
+	return l, math.Hypot(aa, bb), h - math.Floor(h)
+}
+
+// oklchToRGB converts an OKLab lightness, chroma and hue in turns into
+// RGB, clipping colors which are out of the sRGB gamut
+func oklchToRGB(l, chroma, h float64) color.RGBA {
+	sin, cos := math.Sincos(2 * math.Pi * h)
+	aa, bb := chroma*cos, chroma*sin
+	cube := func(x float64) float64 { return x * x * x }
+	lc := cube(l + 0.3963377774*aa + 0.2158037573*bb)
+	mc := cube(l - 0.1055613458*aa - 0.0638541728*bb)
+	sc := cube(l - 0.0894841775*aa - 1.2914855480*bb)
+	return color.RGBA{
+		fromLinear(4.0767416621*lc - 3.3077115913*mc + 0.2309699292*sc),
+		fromLinear(-1.2684380046*lc + 2.6097574011*mc - 0.3413193965*sc),
+		fromLinear(-0.0041960863*lc - 0.7034186147*mc + 1.7076147010*sc),
+		255,
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"slices"
+)
+
+// Encoder for the images sent to iTerm2, as fast as it can be as a
+// whole frame of them is sent at a time
+var itermEncoder = png.Encoder{CompressionLevel: png.BestSpeed}
+
+// itermImage is an image last sent to part of a line of the screen
+type itermImage struct {
+	col, cols     int    // the cells it covers
+	data          []byte // its raw RGB data
+	width, height int    // its size in pixels
+}
+
+// The images last sent to each line of the screen, so the overlays can
+// be blended over them as iTerm2 doesn't draw images over images
+var itermLines [][]itermImage
+
+// writeITermLine sends raw RGB data as an iTerm2 inline image covering
+// cols cells from the cursor, which is at column col of line row,
+// sending the quantized img instead if it isn't nil
+func writeITermLine(row, col int, data []byte, img *image.Paletted, width, height, cols int) {
+	for len(itermLines) <= row {
+		itermLines = append(itermLines, nil)
+	}
+	shown := itermImage{col: col, cols: cols, data: slices.Clone(data), width: width, height: height}
+	found := false
+	for i, old := range itermLines[row] {
+		if old.col == col {
+			itermLines[row][i], found = shown, true
+		}
+	}
+	if !found {
+		itermLines[row] = append(itermLines[row], shown)
+	}
+	if img != nil {
+		writeITerm(img, &pngEncoder, cols)
+		return
+	}
+	writeITerm(rgbImage(data, width, height), &itermEncoder, cols)
+}
+
+// writeITerm sends img as an iTerm2 inline image stretched over cols
+// cells of the line from the cursor, leaving the cursor where it is
+func writeITerm(img image.Image, enc *png.Encoder, cols int) {
+	var buf bytes.Buffer
+	// Encoding a valid image into memory can't fail
+	_ = enc.Encode(&buf, img)
+	fmt.Fprintf(screen, "\033]1337;File=inline=1;size=%d;width=%d;height=1;preserveAspectRatio=0;doNotMoveCursor=1:%s\a",
+		buf.Len(), cols, base64.StdEncoding.EncodeToString(buf.Bytes()))
+}
+
+// writeITermImage draws an image.RGBA over the cells from the top left
+// of the screen by blending it over the images last sent to the lines
+// it covers and sending them again, so the overlays can stack
+func writeITermImage(img *image.RGBA) {
+	_, _, _, _, cellWidth, cellHeight := getImageDimensions()
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	for row := 0; row*cellHeight < height && row < len(itermLines); row++ {
+		for i, shown := range itermLines[row] {
+			x0, y0 := shown.col*cellWidth, row*cellHeight
+			if x0 >= width || transparent(img, image.Rect(x0, y0, x0+shown.cols*cellWidth, y0+cellHeight)) {
+				continue
+			}
+			data, w, h := scaleToCells(shown.data, shown.width, shown.height, shown.cols)
+			for y := 0; y < h && y0+y < height; y++ {
+				for x := 0; x < w && x0+x < width; x++ {
+					p, q := (y0+y)*img.Stride+4*(x0+x), 3*(y*w+x)
+					src := color.RGBA{img.Pix[p], img.Pix[p+1], img.Pix[p+2], img.Pix[p+3]}
+					c := blend(src, color.RGBA{data[q], data[q+1], data[q+2], 255})
+					data[q], data[q+1], data[q+2] = c.R, c.G, c.B
+				}
+			}
+			itermLines[row][i] = itermImage{col: shown.col, cols: shown.cols, data: data, width: w, height: h}
+			fmt.Fprintf(screen, "\033[%d;%dH", row+1, shown.col+1)
+			writeITerm(rgbImage(data, w, h), &itermEncoder, shown.cols)
+		}
+	}
+}
+
+// transparent returns true if the pixels of img in r are all fully
+// transparent
+func transparent(img *image.RGBA, r image.Rectangle) bool {
+	r = r.Intersect(img.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if img.Pix[img.PixOffset(x, y)+3] != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
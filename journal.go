@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The last view written to the journal so each is only written once
+var lastJournaled view
+
+// journalPath returns the path of the journal file
+func journalPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "journal.txt"), nil
+}
+
+// journalLine formats a line of the journal for the view v
+//
+// Each line is the time, the center, radius and depth of the view,
+// the Julia set parameter if any and then the note if any, separated
+// by tabs so the journal can be searched with grep and friends.
+func journalLine(t time.Time, v view, note string) string {
+	fields := []string{
+		t.Format(time.DateTime),
+		fmt.Sprintf("%g", v.absCenter()),
+		fmt.Sprintf("radius=%g", v.radius),
+		fmt.Sprintf("depth=%d", v.depth),
+	}
+	if !isMandelbrot(v.params.fractal) {
+		fields = append(fields, "fractal="+v.params.fractal.Name())
+	}
+	if f := formulaText(v.params); f != "" {
+		fields = append(fields, "formula="+f)
+	}
+	if v.params.hybrid != "" {
+		fields = append(fields, "hybrid="+v.params.hybrid)
+	}
+	if fp := fractalParamOf(v.params.fractal); fp != nil {
+		fields = append(fields, fmt.Sprintf("%s=%g", fp.name, v.params.param))
+	}
+	if v.params.julia {
+		fields = append(fields, fmt.Sprintf("julia=%g", v.params.c))
+	}
+	if note != "" {
+		fields = append(fields, note)
+	}
+	return strings.Join(fields, "\t") + "\n"
+}
+
+// appendJournal appends a line for the view v to the journal
+func appendJournal(v view, note string) error {
+	path, err := journalPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(journalLine(time.Now(), v, note))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// journalVisit writes the current view to the journal if it is
+// enabled and the view is new and has settled
+func journalVisit() {
+	if !cfg.Journal || animating() || dragging || densityMode() || currentView() == lastJournaled {
+		return
+	}
+	lastJournaled = currentView()
+	if err := appendJournal(lastJournaled, ""); err != nil {
+		message = fmt.Sprintf(tr("Journal failed: %v"), err)
+	}
+}
+
+// annotate asks for a note about the current view and writes it to
+// the journal
+func annotate() {
+	startPrompt(tr("Note:"), func(text string) error {
+		if text == "" {
+			return nil
+		}
+		if err := appendJournal(currentView(), text); err != nil {
+			return err
+		}
+		message = tr("Note saved in the journal")
+		return nil
+	})
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"math/bits"
+	"math/cmplx"
+)
+
+// Flags
+var (
+	kernelFlag = flag.String("kernel", "auto", `Iteration kernel - "auto", "float32", "float64", "fixed" for 64 bit fixed point, which is faster on some small ARM boards, "double" for double-double, "perturb" for perturbation against a big.Float reference orbit, "big" for big.Float, "simd" for float64 4 points at a time with AVX2 or "gpu" for float64 on the GPU with OpenCL if built with -tags opencl`)
+)
+
+func init() {
+	registerFractal(0, mandelbrotFractal{})
+}
+
+// mandelbrotFractal is the Mandelbrot set, iterated with the kernel
+// chosen
+type mandelbrotFractal struct{}
+
+func (mandelbrotFractal) Name() string                       { return "mandelbrot" }
+func (mandelbrotFractal) Title() string                      { return "Mandelbrot set" }
+func (mandelbrotFractal) DefaultView() (complex128, float64) { return 0, 2 }
+
+// Iterate iterates z with the float32, fixed point or float64 kernel
+// chosen, or float64 for the others
+func (mandelbrotFractal) Iterate(z, c complex128, i, maxDepth int) (int, complex128) {
+	switch currentKernel() {
+	case kernelFloat32:
+		return mandelbrot32(complex64(z), complex64(c), i, maxDepth)
+	case kernelFixed:
+		return mandelbrotFixed(z, c, i, maxDepth)
+	}
+	return mandelbrot(z, c, i, maxDepth)
+}
+
+// kernel is an implementation of the iteration
+type kernel int
+
+// The kernels
+const (
+	kernelFloat64 kernel = iota
+	kernelFloat32
+	kernelFixed
+	kernelBig
+	kernelPerturb
+	kernelDouble
+	kernelSIMD
+	kernelGPU
+)
+
+// Fixed point numbers are Q4.60, 4 bits of integer part including
+// the sign and 60 bits of fraction
+const (
+	fixedBits = 60
+	fixedOne  = int64(1) << fixedBits
+)
+
+// The fixed point kernel is only used while the pixels are spaced
+// much further apart than the 2^-60 resolution of the numbers
+const fixedMinSpacing = 2e-13
+
+// The float32 kernel is only used while the pixels are spaced about
+// 100 times further apart than the 2^-22 resolution of float32 near 2
+const float32MinSpacing = 2e-5
+
+// The double-double kernel is only used while the pixels are spaced a
+// few hundred times further apart than its 2^-104 resolution relative
+// to the size of the center
+const doubleMinSpacing = 2e-29
+
+// Periodicity checking settings
+//
+// The orbit is saved at iterations which double in spacing, the way
+// Brent's cycle finding does, and if it comes back to the saved point
+// it has fallen into a cycle so the point is inside the set. That
+// catches the orbits which are only slowly pulled into their cycle,
+// near the edges of the bulbs, long before the derivative test does.
+// The deep zoom kernels don't check, as their orbits are only held to
+// float64 so can't be told apart finely enough.
+const (
+	// Iterations before the orbit is first saved
+	periodFirstCheck = 16
+
+	// Orbits which come back to within this fraction of the pixel
+	// spacing of the saved point are taken to be in a cycle
+	periodTolerance = 1e-3
+)
+
+// The kernel chosen for the frame being plotted and how close an orbit
+// must come back to be taken to be in a cycle
+var (
+	frameKernel    kernel
+	periodDistance float64
+)
+
+// checkKernelFlag checks the value of --kernel, setting up the GPU if
+// it is to be used
+func checkKernelFlag() error {
+	switch *kernelFlag {
+	case "auto", "float32", "float64", "fixed", "double", "perturb", "big", "simd":
+		return nil
+	case "gpu":
+		if err := initGPU(); err != nil {
+			return fmt.Errorf("--kernel gpu: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("--kernel must be \"auto\", \"float32\", \"float64\", \"fixed\", \"double\", \"perturb\", \"big\", \"simd\" or \"gpu\" not %q", *kernelFlag)
+}
+
+// chooseKernel chooses the kernel for plotting a frame with its pixels
+// spaced dx apart in the set
+//
+// In auto mode float32 is used for shallow zooms as it is faster,
+// switching to float64 when it can't tell the pixels apart, unless
+// the SIMD kernel can be used, which is faster than both, and
+// likewise the fixed point kernel switches to float64 when zoomed in
+// too far. Zoomed in further than float64 can reach, double-double is
+// used and then beyond that the points are iterated by perturbation
+// against a reference orbit worked out in big.Float with the
+// precision the zoom needs. Julia sets are always float64 or less.
+//
+// It is chosen once per frame rather than for every point as the
+// choice is the same for them all.
+func chooseKernel(dx float64) {
+	frameKernel = kernelFor(dx)
+	periodDistance = periodTolerance * dx
+}
+
+// cycleEpsilon returns the squared distance an orbit must come back
+// within to be taken to be in a cycle, or -1 to turn the periodicity
+// check off as the interior coloring needs the orbits to have settled
+// onto their cycles
+func cycleEpsilon() float64 {
+	if needsInterior() {
+		return -1
+	}
+	return periodDistance * periodDistance
+}
+
+// kernelFor returns the kernel for plotting pixels dx apart
+func kernelFor(dx float64) kernel {
+	if !isMandelbrot(params.fractal) {
+		// The other fractals only have a float64 implementation
+		return kernelFloat64
+	}
+	switch *kernelFlag {
+	case "auto":
+		if deepView(dx) {
+			if dx > doubleMinSpacing*max(1, cmplx.Abs(absCenter())) {
+				return kernelDouble
+			}
+			return kernelPerturb
+		}
+		if simdUsable() {
+			return kernelSIMD
+		}
+		if dx > float32MinSpacing {
+			return kernelFloat32
+		}
+	case "float32":
+		return kernelFloat32
+	case "fixed":
+		if dx > fixedMinSpacing {
+			return kernelFixed
+		}
+	case "double":
+		if !params.julia {
+			return kernelDouble
+		}
+	case "perturb":
+		if !params.julia {
+			return kernelPerturb
+		}
+	case "big":
+		if !params.julia {
+			return kernelBig
+		}
+	case "simd":
+		if simdUsable() {
+			return kernelSIMD
+		}
+	case "gpu":
+		if gpuUsable() {
+			return kernelGPU
+		}
+	}
+	return kernelFloat64
+}
+
+// currentKernel returns the kernel chosen for the frame
+func currentKernel() kernel {
+	return frameKernel
+}
+
+// deepKernel returns true if the kernel in use is one of the deep
+// zoom ones, which work relative to origin
+func deepKernel() bool {
+	k := currentKernel()
+	return k == kernelDouble || k == kernelPerturb || k == kernelBig
+}
+
+// kernelName describes the kernel in use for the info overlay
+func kernelName() string {
+	switch currentKernel() {
+	case kernelFloat32:
+		return tr("• Float32 kernel")
+	case kernelFixed:
+		return tr("• Fixed point kernel")
+	case kernelDouble:
+		return tr("• Double-double kernel")
+	case kernelPerturb:
+		return fmt.Sprintf(tr("• Perturbation kernel with %d bits"), deepPrecision())
+	case kernelBig:
+		return fmt.Sprintf(tr("• Big float kernel with %d bits"), deepPrecision())
+	case kernelSIMD:
+		return fmt.Sprintf(tr("• SIMD kernel with %s"), simdName)
+	case kernelGPU:
+		return fmt.Sprintf(tr("• GPU kernel on %s"), gpuName())
+	}
+	return tr("• Float64 kernel")
+}
+
+// escape iterates z from iteration i until it escapes or reaches
+// maxDepth iterations with the fractal being plotted
+func escape(z, c complex128, i, maxDepth int) (int, complex128) {
+	return params.fractal.Iterate(z, c, i, maxDepth)
+}
+
+// mandelbrot32 is mandelbrot in float32
+func mandelbrot32(z, c complex64, i, maxDepth int) (int, complex128) {
+	x, y := real(z), imag(z)
+	cx, cy := real(c), imag(c)
+	dx, dy := float32(1), float32(0)
+	eps := float32(cycleEpsilon())
+	sx, sy, check := x, y, i+periodFirstCheck
+	for ; i < maxDepth; i++ {
+		x2, y2 := x*x, y*y
+		if x2+y2 >= 4 {
+			break
+		}
+		if i > 0 {
+			dx, dy = 2*(x*dx-y*dy), 2*(x*dy+y*dx)
+			if dx*dx+dy*dy < interiorEpsilon {
+				return maxDepth, complex(float64(x), float64(y))
+			}
+		}
+		x, y = x2-y2+cx, 2*x*y+cy
+		if i == check {
+			sx, sy, check = x, y, 2*check
+		} else if (x-sx)*(x-sx)+(y-sy)*(y-sy) <= eps {
+			return maxDepth, complex(float64(x), float64(y))
+		}
+	}
+	return i, complex(float64(x), float64(y))
+}
+
+// toFixed converts f, which must be less than 8 in size, to fixed point
+func toFixed(f float64) int64 {
+	return int64(f * float64(fixedOne))
+}
+
+// fromFixed converts a fixed point number to a float64
+func fromFixed(x int64) float64 {
+	return float64(x) / float64(fixedOne)
+}
+
+// fixedSquare returns x*x in fixed point
+func fixedSquare(x int64) int64 {
+	if x < 0 {
+		x = -x
+	}
+	hi, lo := bits.Mul64(uint64(x), uint64(x))
+	return int64(hi<<(64-fixedBits) | lo>>fixedBits)
+}
+
+// fixedMul returns x*y in fixed point
+func fixedMul(x, y int64) int64 {
+	neg := (x < 0) != (y < 0)
+	if x < 0 {
+		x = -x
+	}
+	if y < 0 {
+		y = -y
+	}
+	hi, lo := bits.Mul64(uint64(x), uint64(y))
+	r := int64(hi<<(64-fixedBits) | lo>>fixedBits)
+	if neg {
+		return -r
+	}
+	return r
+}
+
+// mandelbrotFixed is mandelbrot using fixed point integer arithmetic
+//
+// Each step checks |z| < 2 before squaring so none of the
+// intermediate values can overflow Q4.60. It doesn't do the
+// derivative test for the interior, which would need floating
+// point, so interior points are only stopped early by the periodicity
+// check, and the rest are iterated all the way to maxDepth, which
+// gives the same colors.
+func mandelbrotFixed(z, c complex128, i, maxDepth int) (int, complex128) {
+	if max(math.Abs(real(z)), math.Abs(imag(z)), math.Abs(real(c)), math.Abs(imag(c))) >= 2 {
+		// Escapes straight away, or too big to fit
+		return mandelbrot(z, c, i, maxDepth)
+	}
+	const two = 2 * fixedOne
+	x, y := toFixed(real(z)), toFixed(imag(z))
+	cx, cy := toFixed(real(c)), toFixed(imag(c))
+	tol := int64(-1)
+	if cycleEpsilon() >= 0 {
+		tol = toFixed(periodDistance)
+	}
+	sx, sy, check := x, y, i+periodFirstCheck
+	for ; i < maxDepth; i++ {
+		if x >= two || x <= -two || y >= two || y <= -two {
+			break
+		}
+		x2, y2 := fixedSquare(x), fixedSquare(y)
+		if uint64(x2)+uint64(y2) >= uint64(4*fixedOne) {
+			break
+		}
+		// |xy| <= (x² + y²)/2 < 2 so 2xy fits easily
+		x, y = x2-y2+cx, 2*fixedMul(x, y)+cy
+		if i == check {
+			sx, sy, check = x, y, 2*check
+		} else if max(x-sx, sx-x) <= tol && max(y-sy, sy-y) <= tol {
+			return maxDepth, complex(fromFixed(x), fromFixed(y))
+		}
+	}
+	return i, complex(fromFixed(x), fromFixed(y))
+}
@@ -0,0 +1,47 @@
+package main
+
+func init() {
+	registerFractal(60, lambdaFractal{})
+}
+
+// Size of z the Lambda set has escaped at
+const lambdaEscape = 1000
+
+// lambdaFractal is the Lambda set of the logistic map
+type lambdaFractal struct{}
+
+func (lambdaFractal) Name() string                       { return "lambda" }
+func (lambdaFractal) Title() string                      { return "Lambda set" }
+func (lambdaFractal) DefaultView() (complex128, float64) { return 1, 2.5 }
+func (lambdaFractal) JuliaView() (complex128, float64)   { return 0.5, 1.5 }
+
+// Start returns the critical point of the logistic map
+func (lambdaFractal) Start() complex128 { return 0.5 }
+
+// Iterate iterates the logistic map z = c z (1 - z), where c is the
+// growth rate usually called lambda, from iteration i until it
+// escapes or reaches maxDepth iterations
+//
+// It escapes much further out than the Mandelbrot set as for small c
+// z needs to be large before c z^2 outgrows z. Like multibrot it stops
+// early inside the set by the size of the derivative of the orbit
+// with respect to its first point after the critical point 0.5, which
+// is multiplied by |c (1 - 2z)| each step.
+func (lambdaFractal) Iterate(z, c complex128, i, maxDepth int) (int, complex128) {
+	c2 := real(c)*real(c) + imag(c)*imag(c)
+	d := 1.0 // |dz|²
+	for ; i < maxDepth; i++ {
+		if real(z)*real(z)+imag(z)*imag(z) >= lambdaEscape*lambdaEscape {
+			break
+		}
+		if i > 0 {
+			w := 1 - 2*z
+			d *= c2 * (real(w)*real(w) + imag(w)*imag(w))
+			if d < interiorEpsilon {
+				return maxDepth, z
+			}
+		}
+		z = c * z * (1 - z)
+	}
+	return i, z
+}
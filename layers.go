@@ -0,0 +1,156 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// Flags
+var (
+	layersFlag = flag.String("layers", "", "Colorings to draw on top of each other, eg smooth,distance:0.5,interior - each is a coloring or interior with an optional opacity from 0 to 1")
+)
+
+// layer is one of the colorings drawn on top of each other
+type layer struct {
+	coloring coloringMode // coloring of the points outside the set
+	interior bool         // set if this colors the points inside the set by the interior mode instead
+	opacity  float64      // how much it covers the layers below from 0 to 1
+}
+
+// Name of the layer coloring the inside of the set
+const interiorLayer = "interior"
+
+// The layers drawn instead of the coloring mode if set, from the
+// bottom up
+var layers []layer
+
+// checkLayersFlag sets the layers from --layers
+func checkLayersFlag() error {
+	if *layersFlag == "" {
+		return nil
+	}
+	if err := setLayers(*layersFlag); err != nil {
+		return fmt.Errorf("--layers: %w", err)
+	}
+	return nil
+}
+
+// parseLayers parses a comma separated list of layers, each a
+// coloring name or interior with an optional :opacity
+func parseLayers(text string) ([]layer, error) {
+	var ls []layer
+	for _, item := range strings.Split(text, ",") {
+		name, opacityText, hasOpacity := strings.Cut(strings.TrimSpace(item), ":")
+		l := layer{opacity: 1}
+		if hasOpacity {
+			opacity, err := strconv.ParseFloat(opacityText, 64)
+			if err != nil || !(opacity >= 0 && opacity <= 1) {
+				return nil, fmt.Errorf("bad opacity %q for layer %q - use 0 to 1", opacityText, name)
+			}
+			l.opacity = opacity
+		}
+		if name == interiorLayer {
+			l.interior = true
+		} else {
+			found := false
+			for i, n := range coloringNames {
+				if n == name {
+					l.coloring, found = coloringMode(i), true
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("unknown layer %q - use %s or %s", name, strings.Join(coloringNames, ", "), interiorLayer)
+			}
+		}
+		ls = append(ls, l)
+	}
+	// Only one sum can be added up over the orbits
+	stripe, exp := false, false
+	for _, l := range ls {
+		stripe = stripe || !l.interior && l.coloring == stripeColoring
+		exp = exp || !l.interior && l.coloring == expColoring
+	}
+	if stripe && exp {
+		return nil, fmt.Errorf("stripe and exponential can't be layered together")
+	}
+	return ls, nil
+}
+
+// setLayers sets the layers from text as parsed by parseLayers, or
+// turns them off if it is off
+func setLayers(text string) error {
+	var ls []layer
+	if text != "off" {
+		var err error
+		ls, err = parseLayers(text)
+		if err != nil {
+			return err
+		}
+	}
+	layers = ls
+	coloringID++
+	recolor()
+	return nil
+}
+
+// layersText returns the layers in the form parseLayers reads
+func layersText() string {
+	var items []string
+	for _, l := range layers {
+		name := interiorLayer
+		if !l.interior {
+			name = coloringNames[l.coloring]
+		}
+		if l.opacity != 1 {
+			name += ":" + strconv.FormatFloat(l.opacity, 'g', -1, 64)
+		}
+		items = append(items, name)
+	}
+	return strings.Join(items, ",")
+}
+
+// usesColoring returns true if mode is the coloring in use or one of
+// the layers
+func usesColoring(mode coloringMode) bool {
+	if layers == nil {
+		return coloring == mode
+	}
+	for _, l := range layers {
+		if !l.interior && l.coloring == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// layeredColor returns the color of it with the layers drawn on top
+// of each other over black, iterated to plotDepth with pixels of size
+// pixel
+//
+// Each layer works out its own color for the point first, then it is
+// mixed into the ones below by its opacity. The colorings of the
+// outside of the set leave the points inside it alone, and the
+// interior layer the points outside.
+func layeredColor(it iteration, plotDepth int, pixel float64) color.RGBA {
+	inside := it.i >= plotDepth
+	col := color.RGBA{0, 0, 0, 255}
+	for _, l := range layers {
+		if l.interior != inside {
+			continue
+		}
+		var top color.RGBA
+		if inside {
+			top = interiorColor(it, pixel)
+		} else {
+			top = escapedColor(l.coloring, it, pixel)
+		}
+		col = mixRGB(col, top, l.opacity)
+	}
+	if !inside {
+		col = lightColor(col, it.z, it.dz)
+	}
+	return col
+}
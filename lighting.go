@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// Flags
+var (
+	lightingFlag   = flag.Bool("lighting", false, "Shade the fractal as a surface lit from the side")
+	lightAngleFlag = flag.Float64("light-angle", 45, "Direction the light comes from in degrees anticlockwise from the right")
+)
+
+// Lighting settings
+const (
+	// Height of the light above the surface - the higher it is the
+	// less the shadows darken
+	lightHeight = 1.5
+
+	// Degrees the light is turned by each key press
+	lightStep = 15
+)
+
+// Lighting state
+var (
+	lighting   = false // set if the fractal is shaded as a lit surface
+	lightAngle = 45.0  // direction the light comes from in degrees
+)
+
+// checkLightingFlags sets the lighting from --lighting and
+// --light-angle
+func checkLightingFlags() {
+	lighting = *lightingFlag
+	setLightAngle(*lightAngleFlag)
+}
+
+// toggleLighting turns the lighting on or off
+func toggleLighting() {
+	lighting = !lighting
+	coloringID++
+	recolor()
+	if lighting {
+		message = tr("Lighting on")
+	} else {
+		message = tr("Lighting off")
+	}
+}
+
+// setLightAngle sets the direction the light comes from in degrees
+func setLightAngle(angle float64) {
+	angle = math.Mod(angle, 360)
+	if angle < 0 {
+		angle += 360
+	}
+	if angle != lightAngle {
+		lightAngle = angle
+		coloringID++
+		recolor()
+	}
+}
+
+// turnLight turns the light round by steps of lightStep degrees
+func turnLight(steps float64) {
+	setLightAngle(lightAngle + steps*lightStep)
+	message = fmt.Sprintf(tr("Light from %g°"), lightAngle)
+}
+
+// lightColor shades col as if the fractal were a surface lit from
+// lightAngle, with Lambertian shading
+//
+// The surface is the smooth iteration count, whose normal points
+// along z/dz, so it needs the derivative of the orbit and col is left
+// as it is if that wasn't tracked.
+func lightColor(col color.RGBA, z, dz complex128) color.RGBA {
+	if !lighting || !needsDerivative() {
+		return col
+	}
+	u := z / dz
+	a := lightAngle * math.Pi / 180
+	t := (real(u)*math.Cos(a) + imag(u)*math.Sin(a)) / math.Hypot(real(u), imag(u))
+	shade(&col, (t+lightHeight)/(1+lightHeight))
+	return col
+}
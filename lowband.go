@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// Flags
+var (
+	lowBandwidthFlag = flag.Bool("low-bandwidth", false, "Send the image in 256 colors compressed as PNG, for slow connections")
+)
+
+// sharedPalette is the palette every line is quantized to in low
+// bandwidth mode, 3 bits of red and green and 2 of blue
+//
+// Using the same palette for every frame means a line which has
+// only changed a little usually quantizes to the same pixels so
+// doesn't need sending again.
+var sharedPalette = func() color.Palette {
+	p := make(color.Palette, 256)
+	for i := range p {
+		r, g, b := i>>5, i>>2&7, i&3
+		p[i] = color.RGBA{uint8(r * 255 / 7), uint8(g * 255 / 7), uint8(b * 255 / 3), 255}
+	}
+	return p
+}()
+
+// quantize converts raw RGB data to an image using sharedPalette
+func quantize(data []byte, width, height int) *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, width, height), sharedPalette)
+	for i := range img.Pix {
+		r, g, b := data[3*i], data[3*i+1], data[3*i+2]
+		img.Pix[i] = r&0xe0 | g>>5<<2 | b>>6
+	}
+	return img
+}
+
+// pngEncoder compresses the lines as hard as it can as the link is
+// the bottleneck rather than the CPU
+var pngEncoder = png.Encoder{CompressionLevel: png.BestCompression}
+
+// writePaletted sends a quantized image as a PNG placed over cols
+// cells of a single line
+//
+// The deflate compression in the PNG takes care of the long runs of
+// the same color there are in most of the set.
+func writePaletted(img *image.Paletted, cols int) {
+	var buf bytes.Buffer
+	// Encoding a valid image into memory can't fail
+	_ = pngEncoder.Encode(&buf, img)
+	placement := ""
+	if cols > 0 {
+		placement = fmt.Sprintf(",c=%d,r=1", cols)
+	}
+	chunkSize := 4096
+	data := base64.StdEncoding.EncodeToString(buf.Bytes())
+	for len(data) > 0 {
+		m := "1"
+		end := chunkSize
+		if len(data) <= chunkSize {
+			end = len(data)
+			m = "0"
+		}
+		chunk := data[:end]
+		data = data[end:]
+
+		fmt.Fprintf(screen, "\033_Gf=100,a=T%s,q=2,m=%s;%s\033\\", placement, m, chunk)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// Flags
+var (
+	sequenceFlag = flag.String("sequence", "BBBBBBAAAAAA", "Sequence of A and B growth rates of the lyapunov fractal - the default is Zircon Zity")
+)
+
+func init() {
+	registerFractal(90, lyapunovFractal{})
+}
+
+// lyapunovFractal is the Lyapunov fractal of the --sequence
+type lyapunovFractal struct{}
+
+func (lyapunovFractal) Name() string                       { return "lyapunov" }
+func (lyapunovFractal) Title() string                      { return "Lyapunov fractal" }
+func (lyapunovFractal) DefaultView() (complex128, float64) { return complex(2.95, 3.7), 0.32 }
+func (lyapunovFractal) NoJulia() bool                      { return true }
+
+// The lyapunov sequence as true for B, false for A
+var sequence []bool
+
+// checkSequenceFlag checks and decodes --sequence
+func checkSequenceFlag() error {
+	s := strings.ToUpper(*sequenceFlag)
+	if s == "" || strings.Trim(s, "AB") != "" {
+		return fmt.Errorf("--sequence must be made of A and B not %q", *sequenceFlag)
+	}
+	sequence = sequence[:0]
+	for _, r := range s {
+		sequence = append(sequence, r == 'B')
+	}
+	return nil
+}
+
+// Iterate works out the Lyapunov exponent of the logistic map
+//
+//	x_{n+1} = r_n x_n (1 - x_n)
+//
+// where the growth rate r_n is taken from the real part of c for an A
+// in the sequence and from the imaginary part for a B.
+//
+// The first quarter of maxDepth iterations are done to let x settle
+// and the exponent is the average of log |r_n (1 - 2 x_n)| over the
+// rest. This isn't an escape time so the exponent is returned as the
+// real part of z with an iteration count of 0, which then isn't
+// refined any deeper, and colored by Color.
+func (lyapunovFractal) Iterate(z, c complex128, i, maxDepth int) (int, complex128) {
+	a, b := real(c), imag(c)
+	if a < 0 || a > 4 || b < 0 || b > 4 {
+		// x leaves [0, 1] and heads off to infinity
+		return 0, complex(math.Inf(1), 0)
+	}
+	warmup := maxDepth / 4
+	x := 0.5
+	sum, product := 0.0, 1.0
+	for n := 0; n < maxDepth; n++ {
+		r := a
+		if sequence[n%len(sequence)] {
+			r = b
+		}
+		if n >= warmup {
+			// Multiply up the terms and only take the log now and
+			// again as logs are slow
+			product *= math.Abs(r * (1 - 2*x))
+			if product < 1e-100 || product > 1e100 {
+				sum += math.Log(product)
+				product = 1
+			}
+		}
+		x = r * x * (1 - x)
+	}
+	sum += math.Log(product)
+	return 0, complex(sum/float64(maxDepth-warmup), 0)
+}
+
+// Color colors a Lyapunov exponent worked out by Iterate
+//
+// Negative exponents, where the map is stable, go up the gradient the
+// more negative they are and positive exponents, where it is chaotic,
+// are dark blue getting brighter the more chaotic. Where the map
+// heads off to infinity is black.
+func (lyapunovFractal) Color(it iteration) color.RGBA {
+	lambda := real(it.z)
+	switch {
+	case math.IsInf(lambda, 1) || math.IsNaN(lambda):
+		return color.RGBA{0, 0, 0, 255}
+	case lambda >= 0:
+		return color.RGBA{0, 0, uint8(255 * math.Min(lambda, 1)), 255}
+	}
+	return gradientColor(1 - 1/(1-lambda))
+}
@@ -0,0 +1,73 @@
+package main
+
+func init() {
+	registerFractal(100, magnetIFractal{})
+	registerFractal(110, magnetIIFractal{})
+}
+
+// Bailouts of the Magnet fractals
+const (
+	// Points escape when z gets this big
+	magnetEscape = 100
+
+	// Points have converged when z gets this close to 1
+	magnetConverged = 1e-6
+)
+
+// magnetIFractal is the Magnet type I fractal
+type magnetIFractal struct{}
+
+func (magnetIFractal) Name() string                       { return "magnet1" }
+func (magnetIFractal) Title() string                      { return "Magnet type I" }
+func (magnetIFractal) DefaultView() (complex128, float64) { return complex(1.5, 0), 2.5 }
+
+func (magnetIFractal) Iterate(z, c complex128, i, maxDepth int) (int, complex128) {
+	return magnet(magnetI, z, c, i, maxDepth)
+}
+
+// magnetIIFractal is the Magnet type II fractal
+type magnetIIFractal struct{}
+
+func (magnetIIFractal) Name() string                       { return "magnet2" }
+func (magnetIIFractal) Title() string                      { return "Magnet type II" }
+func (magnetIIFractal) DefaultView() (complex128, float64) { return complex(1.2, 0), 2.2 }
+
+func (magnetIIFractal) Iterate(z, c complex128, i, maxDepth int) (int, complex128) {
+	return magnet(magnetII, z, c, i, maxDepth)
+}
+
+// magnetStep is the iteration of one of the Magnet fractals
+type magnetStep func(z, c complex128) complex128
+
+// magnetI is the iteration of the Magnet type I fractal
+func magnetI(z, c complex128) complex128 {
+	w := (z*z + c - 1) / (2*z + c - 2)
+	return w * w
+}
+
+// magnetII is the iteration of the Magnet type II fractal
+func magnetII(z, c complex128) complex128 {
+	c1, c2 := c-1, c-2
+	w := (z*z*z + 3*c1*z + c1*c2) / (3*z*z + 3*c2*z + c1*c2 + 1)
+	return w * w
+}
+
+// magnet iterates one of the Magnet fractals from iteration i until
+// z escapes or converges to the fixed point at 1, or reaches maxDepth
+// iterations
+//
+// Unlike the other fractals there are two ways out of the loop. The
+// points which converge are inside the set so maxDepth is returned for
+// them straight away.
+func magnet(step magnetStep, z, c complex128, i, maxDepth int) (int, complex128) {
+	for ; i < maxDepth; i++ {
+		if real(z)*real(z)+imag(z)*imag(z) >= magnetEscape*magnetEscape {
+			break
+		}
+		if d := z - 1; real(d)*real(d)+imag(d)*imag(d) < magnetConverged*magnetConverged {
+			return maxDepth, z
+		}
+		z = step(z, c)
+	}
+	return i, z
+}
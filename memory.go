@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// Flags
+var (
+	maxMemoryFlag = flag.String("max-memory", "512M", "Memory to use for plots and caches, eg 256M or 2G")
+)
+
+// Globals
+var (
+	maxMemory int64 // parsed from maxMemoryFlag
+)
+
+// parseSize parses a size like 100, 64k, 512M or 2G into bytes
+func parseSize(s string) (int64, error) {
+	multiplier := int64(1)
+	s = strings.TrimSpace(s)
+	if s != "" {
+		switch strings.ToUpper(s[len(s)-1:]) {
+		case "K":
+			multiplier = 1 << 10
+		case "M":
+			multiplier = 1 << 20
+		case "G":
+			multiplier = 1 << 30
+		case "T":
+			multiplier = 1 << 40
+		}
+		if multiplier != 1 {
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("bad size %q", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// formatSize formats a number of bytes in human readable form
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fG", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fM", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fk", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d", n)
+}
+
+// size returns the memory used by the buffers of the plot
+func (p *plot) size() int64 {
+	return int64(len(p.data)) + int64(len(p.iters))*int64(unsafe.Sizeof(iteration{})) +
+		int64(len(p.supersamples))*int64(unsafe.Sizeof([]sample{})) + int64(p.kept)*int64(unsafe.Sizeof(sample{}))
+}
+
+// plotSize returns the memory a width x height plot will use
+func plotSize(width, height int) int64 {
+	return int64(width*height) * (3 + int64(unsafe.Sizeof(iteration{})))
+}
+
+// prefetchedSize returns the memory used by the prefetched plots
+func prefetchedSize() (n int64) {
+	for _, p := range prefetched {
+		n += p.size()
+	}
+	return n
+}
+
+// memoryUsage returns the memory used by the plots and caches
+func memoryUsage() int64 {
+	return lastPlot.size() + pyramidSize() + prefetchedSize() + accumulateSize() + tiles.bytes
+}
+
+// enforceMemoryBudget evicts the least recently used tiles then the
+// oldest prefetched plots until the memory used is within maxMemory
+func enforceMemoryBudget() {
+	for memoryUsage() > maxMemory && tiles.lru.Len() > 0 {
+		tiles.evict()
+	}
+	for memoryUsage() > maxMemory && len(prefetched) > 0 {
+		prefetched = prefetched[1:]
+	}
+}
+
+// memoryInfo returns a line for the info overlay
+func memoryInfo() string {
+	return fmt.Sprintf(tr("• Memory %s of %s (%d tiles, %d prefetched)"), formatSize(memoryUsage()), formatSize(maxMemory), tiles.lru.Len(), len(prefetched))
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"math/cmplx"
+)
+
+// Minibrot finding settings
+const (
+	// Steps of Newton's method tried at each precision before giving
+	// up on finding the nucleus
+	minibrotNewtonSteps = 64
+
+	// Periods tried before giving up on finding a minibrot rather
+	// than a bulb in the view
+	minibrotTries = 16
+
+	// Radius of the view zoomed onto a minibrot in sizes of the
+	// minibrot, enough to show all of it like the whole set
+	minibrotRadius = 2
+
+	// Depth for each iteration of the period of the minibrot zoomed
+	// onto, as each of its iterations takes period of the set's
+	minibrotDepthPerPeriod = 64
+)
+
+// minibrot is a copy of the Mandelbrot set found inside it
+type minibrot struct {
+	nucleus *deepPoint // the center of its cardioid, whose orbit goes back to 0
+	period  int        // the length of that orbit
+	size    float64    // its size relative to the whole set
+}
+
+// findMinibrot finds the minibrot of the lowest period in the view
+//
+// The periods tried are the iterations at which the orbit of a disc
+// the size of the view round the center, worked out to first order
+// from the derivative, surrounds 0, as it only does that when a point
+// whose orbit goes back to 0 is inside. Newton's method then finds
+// that point, the nucleus, starting from the center, in big.Float so
+// it works however deep the view. The nuclei of bulbs are found that
+// way too, so the first nucleus in the view which is the center of a
+// cardioid is the minibrot.
+func findMinibrot() (minibrot, error) {
+	if params.julia {
+		return minibrot{}, errors.New(tr("Julia sets have no minibrots to find"))
+	}
+	if !isMandelbrot(params.fractal) {
+		return minibrot{}, fmt.Errorf(tr("The %s has no minibrots to find"), tr(params.fractal.Title()))
+	}
+	prec := deepPrecision()
+	if origin != nil {
+		prec = max(prec, origin.re.Prec())
+	}
+	c := newDeepPoint(origin, center, prec)
+	for _, period := range ballPeriods(c, radius, depth, minibrotTries) {
+		n, ok := newtonNucleus(c, period, prec)
+		if !ok || !inView(n, prec) || !isCardioid(n, period) {
+			continue
+		}
+		return refineMinibrot(n, period, prec)
+	}
+	return minibrot{}, errors.New(tr("No minibrot found in the view - zoom in or raise the depth"))
+}
+
+// refineMinibrot works out the size of the minibrot with the nucleus
+// n found to prec bits, raising the precision of n until it is enough
+// to zoom onto the minibrot, which can be much smaller than the view
+func refineMinibrot(n *deepPoint, period int, prec uint) (minibrot, error) {
+	for {
+		size := minibrotSize(n, period)
+		if !(size > 0) || math.IsInf(size, 0) {
+			return minibrot{}, fmt.Errorf(tr("The minibrot of period %d is too small to zoom onto"), period)
+		}
+		need := precisionFor(n.approx, minibrotRadius*size)
+		if need <= prec {
+			return minibrot{nucleus: n, period: period, size: size}, nil
+		}
+		prec = need
+		var ok bool
+		n, ok = newtonNucleus(n, period, prec)
+		if !ok {
+			return minibrot{}, fmt.Errorf(tr("Newton's method didn't find the minibrot of period %d"), period)
+		}
+	}
+}
+
+// inView returns true if the point p held to prec bits is in the view
+func inView(p *deepPoint, prec uint) bool {
+	d := newDeepPoint(origin, center, prec)
+	d.re.Sub(p.re, d.re)
+	d.im.Sub(p.im, d.im)
+	re, _ := d.re.Float64()
+	im, _ := d.im.Float64()
+	return cmplx.Abs(complex(re, im)) <= radius
+}
+
+// ballPeriods returns up to n of the iterations of up to maxDepth at
+// which the disc of radius r round c surrounds 0, stopping when c
+// escapes
+func ballPeriods(c *deepPoint, r float64, maxDepth, n int) []int {
+	var periods []int
+	prec := c.re.Prec()
+	x, y := new(big.Float).SetPrec(prec), new(big.Float).SetPrec(prec)
+	x2, y2 := new(big.Float).SetPrec(prec), new(big.Float).SetPrec(prec)
+	var dz complex128
+	for i := 1; i <= maxDepth; i++ {
+		re, _ := x.Float64()
+		im, _ := y.Float64()
+		dz = 2*complex(re, im)*dz + 1
+		// y = 2xy + cy, x = x² - y² + cx
+		x2.Mul(x, x)
+		y2.Mul(y, y)
+		y.Mul(y, x)
+		y.Add(y, y)
+		y.Add(y, c.im)
+		x.Sub(x2, y2)
+		x.Add(x, c.re)
+		re, _ = x.Float64()
+		im, _ = y.Float64()
+		z := cmplx.Abs(complex(re, im))
+		if z > 2 {
+			break
+		}
+		if z < cmplx.Abs(dz)*r {
+			periods = append(periods, i)
+			if len(periods) >= n {
+				break
+			}
+		}
+	}
+	return periods
+}
+
+// newtonNucleus finds the nucleus of the given period nearest c with
+// Newton's method to prec bits, returning false if it doesn't
+// converge
+//
+// The nucleus is where z after period iterations is 0, so each step
+// moves c by z over its derivative with respect to c.
+func newtonNucleus(c *deepPoint, period int, prec uint) (*deepPoint, bool) {
+	newFloat := func() *big.Float { return new(big.Float).SetPrec(prec) }
+	cx, cy := newFloat().Set(c.re), newFloat().Set(c.im)
+	x, y, dx, dy := newFloat(), newFloat(), newFloat(), newFloat()
+	x2, y2, t, u := newFloat(), newFloat(), newFloat(), newFloat()
+	one := big.NewFloat(1)
+	tolerance := math.Ldexp(1, -int(prec)+8)
+	for step := 0; step < minibrotNewtonSteps; step++ {
+		x.SetInt64(0)
+		y.SetInt64(0)
+		dx.SetInt64(0)
+		dy.SetInt64(0)
+		for i := 0; i < period; i++ {
+			// dz = 2z dz + 1
+			t.Mul(x, dx)
+			u.Mul(y, dy)
+			t.Sub(t, u)
+			u.Mul(x, dy)
+			dy.Mul(y, dx)
+			dy.Add(dy, u)
+			dy.Add(dy, dy)
+			dx.Add(t, t)
+			dx.Add(dx, one)
+			// z = z² + c
+			x2.Mul(x, x)
+			y2.Mul(y, y)
+			y.Mul(y, x)
+			y.Add(y, y)
+			y.Add(y, cy)
+			x.Sub(x2, y2)
+			x.Add(x, cx)
+		}
+		// c -= z / dz
+		t.Mul(dx, dx)
+		u.Mul(dy, dy)
+		t.Add(t, u)
+		if t.Sign() == 0 {
+			return nil, false
+		}
+		x2.Mul(x, dx)
+		u.Mul(y, dy)
+		x2.Add(x2, u)
+		x2.Quo(x2, t)
+		y2.Mul(y, dx)
+		u.Mul(x, dy)
+		y2.Sub(y2, u)
+		y2.Quo(y2, t)
+		cx.Sub(cx, x2)
+		cy.Sub(cy, y2)
+		re, _ := cx.Float64()
+		im, _ := cy.Float64()
+		if cmplx.Abs(complex(re, im)) > 2 {
+			return nil, false
+		}
+		stepRe, _ := x2.Float64()
+		stepIm, _ := y2.Float64()
+		if cmplx.Abs(complex(stepRe, stepIm)) <= tolerance*max(1, cmplx.Abs(complex(re, im))) {
+			n := &deepPoint{re: cx, im: cy}
+			n.round()
+			return n, true
+		}
+	}
+	return nil, false
+}
+
+// isCardioid returns true if the nucleus c of the given period is the
+// center of a cardioid, a minibrot, rather than of a circular bulb
+//
+// This estimates the shape from the second derivatives of the orbit
+// after the first step, which are 0 for a perfect cardioid and 1 for
+// a perfect circle.
+func isCardioid(c *deepPoint, period int) bool {
+	prec := c.re.Prec()
+	x, y := new(big.Float).SetPrec(prec).Set(c.re), new(big.Float).SetPrec(prec).Set(c.im)
+	x2, y2 := new(big.Float).SetPrec(prec), new(big.Float).SetPrec(prec)
+	dc, dz := complex(1, 0), complex(1, 0)
+	var dcdc, dcdz complex128
+	for i := 1; i < period; i++ {
+		re, _ := x.Float64()
+		im, _ := y.Float64()
+		z := complex(re, im)
+		dcdc = 2 * (z*dcdc + dc*dc)
+		dcdz = 2 * (z*dcdz + dc*dz)
+		dc = 2*z*dc + 1
+		dz = 2 * z * dz
+		x2.Mul(x, x)
+		y2.Mul(y, y)
+		y.Mul(y, x)
+		y.Add(y, y)
+		y.Add(y, c.im)
+		x.Sub(x2, y2)
+		x.Add(x, c.re)
+	}
+	shape := -(dcdc/(2*dc) + dcdz/dz) / (dc * dz)
+	return cmplx.Abs(shape) < cmplx.Abs(shape-1)
+}
+
+// minibrotSize estimates the size of the minibrot with the nucleus c
+// of the given period relative to the whole set
+//
+// The orbit of the points near the nucleus is a small copy of the
+// orbit of the whole set, scaled by the derivatives along it.
+func minibrotSize(c *deepPoint, period int) float64 {
+	prec := c.re.Prec()
+	x, y := new(big.Float).SetPrec(prec), new(big.Float).SetPrec(prec)
+	x2, y2 := new(big.Float).SetPrec(prec), new(big.Float).SetPrec(prec)
+	l, b := complex(1, 0), complex(1, 0)
+	for i := 1; i < period; i++ {
+		x2.Mul(x, x)
+		y2.Mul(y, y)
+		y.Mul(y, x)
+		y.Add(y, y)
+		y.Add(y, c.im)
+		x.Sub(x2, y2)
+		x.Add(x, c.re)
+		re, _ := x.Float64()
+		im, _ := y.Float64()
+		l *= 2 * complex(re, im)
+		b += 1 / l
+	}
+	return cmplx.Abs(1 / (b * l * l))
+}
+
+// zoomToMinibrot finds the minibrot of the lowest period in the view
+// and zooms onto it
+//
+// The depth is raised to show its detail unless auto depth is on.
+func zoomToMinibrot() error {
+	m, err := findMinibrot()
+	if err != nil {
+		return err
+	}
+	setDeepCenter(m.nucleus)
+	radius = minibrotRadius * m.size
+	if !autoDepth {
+		depth = max(depth, minibrotDepthPerPeriod*m.period)
+	}
+	message = fmt.Sprintf(tr("Minibrot of period %d, size %.3g"), m.period, m.size)
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"strings"
+	"time"
+)
+
+// Flags
+var (
+	morphPathFlag   = flag.String("morph-path", "cardioid", "Path the Julia set parameter follows when morphing - cardioid, bulb or circle")
+	morphPeriodFlag = flag.Duration("morph-period", 20*time.Second, "Time the Julia set morph takes to go once round its path")
+)
+
+// Limits on the time to go round the morph path
+const (
+	minMorphPeriod = time.Second
+	maxMorphPeriod = 10 * time.Minute
+)
+
+// morphPath is a closed path of Julia set parameters to morph along
+type morphPath struct {
+	name  string
+	point func(theta float64) complex128 // the parameter at angle theta round the path
+}
+
+// The paths, which all go round something which has interesting Julia
+// sets along its edge
+var morphPaths = []morphPath{
+	{"cardioid", func(theta float64) complex128 {
+		// The boundary of the main cardioid
+		w := cmplx.Exp(complex(0, theta))
+		return w/2 - w*w/4
+	}},
+	{"bulb", func(theta float64) complex128 {
+		// The boundary of the period 2 bulb
+		return -1 + cmplx.Exp(complex(0, theta))/4
+	}},
+	{"circle", func(theta float64) complex128 {
+		// A circle through the classic dendrites and spirals
+		return 0.7885 * cmplx.Exp(complex(0, theta))
+	}},
+}
+
+// Globals
+var (
+	morphing    = false // set if the Julia set is morphing
+	morphIndex  = 0     // the morphPaths being followed
+	morphPhase  float64 // how far round the path it has got from 0 to 1
+	morphPeriod time.Duration
+)
+
+// checkMorphFlags checks --morph-path and --morph-period
+func checkMorphFlags() error {
+	morphIndex = -1
+	var names []string
+	for i, p := range morphPaths {
+		if p.name == *morphPathFlag {
+			morphIndex = i
+		}
+		names = append(names, p.name)
+	}
+	if morphIndex < 0 {
+		return fmt.Errorf("--morph-path must be one of %s not %q", strings.Join(names, ", "), *morphPathFlag)
+	}
+	if *morphPeriodFlag < minMorphPeriod || *morphPeriodFlag > maxMorphPeriod {
+		return fmt.Errorf("--morph-period must be from %v to %v", minMorphPeriod, maxMorphPeriod)
+	}
+	morphPeriod = *morphPeriodFlag
+	return nil
+}
+
+// toggleMorph starts or stops morphing the Julia set, switching to
+// the Julia set at the start of the path if need be
+func toggleMorph() {
+	if morphing {
+		morphing = false
+		return
+	}
+	if !hasJulia(params.fractal) {
+		message = fmt.Sprintf(tr("The %s has no Julia sets"), tr(params.fractal.Title()))
+		return
+	}
+	c := morphPaths[morphIndex].point(2 * math.Pi * morphPhase)
+	if !params.julia {
+		toggleJulia(c)
+	}
+	params.c = c
+	if !animating() {
+		lastTick = time.Now()
+	}
+	morphing = true
+}
+
+// nextMorphPath cycles through the paths to morph along
+func nextMorphPath() {
+	morphIndex = (morphIndex + 1) % len(morphPaths)
+	message = fmt.Sprintf(tr("Morph path %s"), morphPaths[morphIndex].name)
+}
+
+// changeMorphSpeed speeds up the morph by factor
+func changeMorphSpeed(factor float64) {
+	morphPeriod = max(minMorphPeriod, min(maxMorphPeriod, time.Duration(float64(morphPeriod)/factor)))
+	message = fmt.Sprintf(tr("Morph period %v"), morphPeriod.Round(100*time.Millisecond))
+}
+
+// morphStep moves the Julia set parameter on by dt along the path
+func morphStep(dt time.Duration) {
+	if !params.julia {
+		// Back to the Mandelbrot set so leave it there
+		morphing = false
+		return
+	}
+	morphPhase = math.Mod(morphPhase+float64(dt)/float64(morphPeriod), 1)
+	params.c = morphPaths[morphIndex].point(2 * math.Pi * morphPhase)
+}
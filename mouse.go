@@ -0,0 +1,230 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Constants
+const (
+	// Time constant for the decay of the panning speed after a flick
+	inertiaDecay = 300 * time.Millisecond
+
+	// Only drag motion this recent is used to work out the flick speed
+	flickWindow = 100 * time.Millisecond
+
+	// Panning stops when it gets slower than this in pixels per second
+	minPanVelocity = 20
+
+	// Two clicks in the same place within this time are a double click
+	doubleClickTime = 400 * time.Millisecond
+)
+
+// dragSample is a point on the drag path
+type dragSample struct {
+	t    time.Time
+	x, y float64 // total pixels dragged
+}
+
+// Globals
+var (
+	dragging                     bool         // set if the left button is down
+	dragMoved                    bool         // set if the mouse moved while the button was down
+	dragX, dragY                 int          // last mouse position in cells
+	dragTotal                    dragSample   // total drag so far
+	dragSamples                  []dragSample // recent drag motion
+	panVelocityX, panVelocityY   float64      // inertial panning speed in pixels per second
+	panRemainderX, panRemainderY float64      // fractions of a pixel still to pan
+	clickPending                 bool         // set if a click is waiting to see if it is the first of a double click
+	clickTime                    time.Time    // when the pending click arrived
+	clickX, clickY               int          // where the pending click was
+	mouseX, mouseY               int          // where the mouse was last seen
+	mouseSeen                    bool         // set once the mouse has been seen
+)
+
+// pointAt returns the point of the set under the mouse
+func pointAt(mouseX, mouseY int) complex128 {
+	width, height, rows, cols, _, _ := getImageDimensions()
+	dx, dy := getSetSize(width, height)
+	re := real(center) + dx*float64(mouseX-cols/2)/float64(cols)*float64(width)
+	im := imag(center) + dy*float64(mouseY-rows/2)/float64(rows)*float64(height)
+	return complex(re, im)
+}
+
+// trackMouse notes where the mouse is from the event ev
+func trackMouse(ev termbox.Event) {
+	mouseX, mouseY, mouseSeen = ev.MouseX, ev.MouseY, true
+}
+
+// pointUnderMouse returns the point of the set under the mouse, or
+// the center of the view if the mouse hasn't been seen
+func pointUnderMouse() complex128 {
+	if !mouseSeen {
+		return center
+	}
+	return pointAt(mouseX, mouseY)
+}
+
+// zoomAt centers the view on the cell under the mouse and zooms by factor
+func zoomAt(mouseX, mouseY int, factor float64) {
+	center = pointAt(mouseX, mouseY)
+	radius *= factor
+}
+
+// click acts on a left click which wasn't a drag, returning true if a
+// redraw is needed
+//
+// A click zooms in on the point clicked, but only once doubleClickTime
+// has passed without a second click, see clickWaiting, so a double
+// click just centers the view on the point. The times are those the
+// clicks arrived rather than were handled, so a slow frame doesn't
+// split a double click.
+func click(mouseX, mouseY int) bool {
+	if clickPending && eventTime.Sub(clickTime) < doubleClickTime && mouseX == clickX && mouseY == clickY {
+		clickPending = false
+		center = pointAt(mouseX, mouseY)
+		return true
+	}
+	redraw := false
+	if clickPending {
+		// Too late to be a double click so zoom in for it now
+		zoomAt(clickX, clickY, 1.0/zoom)
+		redraw = true
+	}
+	clickPending, clickTime, clickX, clickY = true, eventTime, mouseX, mouseY
+	return redraw
+}
+
+// clickWaiting returns how long until the pending click zooms in, and
+// false if there isn't one
+func clickWaiting() (time.Duration, bool) {
+	if !clickPending {
+		return 0, false
+	}
+	return time.Until(clickTime.Add(doubleClickTime)), true
+}
+
+// clickEvent returns the event which zooms in for the pending click,
+// which is no longer pending
+func clickEvent() inputEvent {
+	clickPending = false
+	return inputEvent{Event: termbox.Event{Type: eventClick, MouseX: clickX, MouseY: clickY}, t: time.Now()}
+}
+
+// handleMouse acts on a mouse event, returning true if a redraw is needed
+//
+// A left click zooms in, but if the mouse is moved with the button
+// held down then the view is dragged instead. Releasing a drag while
+// still moving flicks the view which carries on panning and slows
+// down gradually.
+//
+// A Ctrl click bookmarks the point clicked and a middle click flips
+// between the Mandelbrot set and the Julia set of the point clicked.
+func handleMouse(ev termbox.Event) bool {
+	switch ev.Key {
+	case termbox.MouseLeft:
+		if ev.Mod&modCtrl != 0 {
+			if ev.Mod&termbox.ModMotion == 0 {
+				bookmarkAt(pointAt(ev.MouseX, ev.MouseY))
+			}
+			return true
+		}
+		if dragging && ev.Mod&termbox.ModMotion != 0 {
+			_, _, _, _, cellWidth, cellHeight := getImageDimensions()
+			mx, my := ev.MouseX-dragX, ev.MouseY-dragY
+			if mx == 0 && my == 0 {
+				return false
+			}
+			dragX, dragY = ev.MouseX, ev.MouseY
+			dragMoved = true
+			px, py := mx*cellWidth, my*cellHeight
+			panPixels(-px, -py)
+			dragTotal.t = time.Now()
+			dragTotal.x += float64(px)
+			dragTotal.y += float64(py)
+			dragSamples = append(dragSamples, dragTotal)
+			return true
+		}
+		dragging, dragMoved = true, false
+		dragX, dragY = ev.MouseX, ev.MouseY
+		dragTotal = dragSample{t: time.Now()}
+		dragSamples = append(dragSamples[:0], dragTotal)
+		// Catch the view if it is still moving
+		panVelocityX, panVelocityY = 0, 0
+		return false
+	case termbox.MouseRelease:
+		if !dragging {
+			return false
+		}
+		dragging = false
+		if !dragMoved {
+			return click(dragX, dragY)
+		}
+		startFlick()
+		return false
+	case termbox.MouseRight:
+		zoomAt(ev.MouseX, ev.MouseY, zoom)
+		return true
+	case termbox.MouseMiddle:
+		if ev.Mod&termbox.ModMotion == 0 {
+			toggleJulia(pointAt(ev.MouseX, ev.MouseY))
+		}
+		return true
+	case termbox.MouseWheelDown:
+		radius *= zoom
+		return true
+	case termbox.MouseWheelUp:
+		radius /= zoom
+		return true
+	}
+	return false
+}
+
+// startFlick sets the panning velocity from the end of the drag
+func startFlick() {
+	now := time.Now()
+	last := dragSamples[len(dragSamples)-1]
+	if now.Sub(last.t) > flickWindow {
+		// The mouse stopped before it was released
+		return
+	}
+	first := last
+	for i := len(dragSamples) - 1; i >= 0 && now.Sub(dragSamples[i].t) <= flickWindow; i-- {
+		first = dragSamples[i]
+	}
+	dt := last.t.Sub(first.t).Seconds()
+	if dt <= 0 {
+		return
+	}
+	panVelocityX = (last.x - first.x) / dt
+	panVelocityY = (last.y - first.y) / dt
+	panRemainderX, panRemainderY = 0, 0
+	lastTick = now
+}
+
+// panning returns true if the view is still moving after a flick
+func panning() bool {
+	return panVelocityX != 0 || panVelocityY != 0
+}
+
+// animatePan moves the view on by the flick velocity over dt
+func animatePan(dt time.Duration) {
+	if !panning() {
+		return
+	}
+	panRemainderX += panVelocityX * dt.Seconds()
+	panRemainderY += panVelocityY * dt.Seconds()
+	px, py := math.Trunc(panRemainderX), math.Trunc(panRemainderY)
+	panRemainderX -= px
+	panRemainderY -= py
+	panPixels(-int(px), -int(py))
+
+	decay := math.Exp(-float64(dt) / float64(inertiaDecay))
+	panVelocityX *= decay
+	panVelocityY *= decay
+	if math.Hypot(panVelocityX, panVelocityY) < minPanVelocity {
+		panVelocityX, panVelocityY = 0, 0
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import "math"
+
+func init() {
+	registerFractal(40, multibrotFractal{})
+}
+
+// multibrotFractal is the Multibrot set of z^power + c with the power
+// the parameter
+type multibrotFractal struct{}
+
+func (multibrotFractal) Name() string                       { return "multibrot" }
+func (multibrotFractal) Title() string                      { return "Multibrot set" }
+func (multibrotFractal) DefaultView() (complex128, float64) { return 0, 2 }
+func (multibrotFractal) Power() float64                     { return params.param }
+
+func (multibrotFractal) Param() *fractalParam {
+	return &fractalParam{name: "exponent", initial: 3, min: 2, max: 16, step: 0.25}
+}
+
+func (multibrotFractal) Iterate(z, c complex128, i, maxDepth int) (int, complex128) {
+	return multibrot(z, c, params.param, i, maxDepth)
+}
+
+// multibrot iterates z^power + c from iteration i until it escapes
+// or reaches maxDepth iterations
+//
+// Whole number powers are done by multiplying as raising to a power
+// in polar form is much slower.
+//
+// Like mandelbrot it stops at maxDepth once the derivative of the
+// orbit with respect to its first point after 0 shrinks below
+// interiorEpsilon. Only its size matters, which is multiplied by
+// power |z|^(power-1) each step, so only that is kept.
+func multibrot(z, c complex128, power float64, i, maxDepth int) (int, complex128) {
+	n := int(power)
+	whole := float64(n) == power
+	d := 1.0 // |dz|²
+	for ; i < maxDepth; i++ {
+		r2 := real(z)*real(z) + imag(z)*imag(z)
+		if r2 >= escapeRadius*escapeRadius {
+			break
+		}
+		if i > 0 {
+			// No derivative to start from at the first step
+			if whole {
+				d *= power * power
+				for k := 1; k < n; k++ {
+					d *= r2
+				}
+			} else {
+				d *= power * power * math.Pow(r2, power-1)
+			}
+			if d < interiorEpsilon {
+				return maxDepth, z
+			}
+		}
+		if whole {
+			p := z
+			for k := 1; k < n; k++ {
+				p *= z
+			}
+			z = p + c
+		} else {
+			r := math.Pow(r2, power/2)
+			sin, cos := math.Sincos(power * math.Atan2(imag(z), real(z)))
+			z = complex(r*cos, r*sin) + c
+		}
+	}
+	return i, z
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// Flags
+var (
+	notifyFlag = flag.Bool("notify", false, "Ring the bell and send a desktop notification when --render finishes")
+)
+
+// notify tells the user that a long job has finished if --notify is set
+//
+// It rings the terminal bell and sends the notification to the
+// terminal with OSC 9 (iTerm2, kitty, ghostty and others) and OSC 777
+// (VTE based terminals and others), and with notify-send if it is
+// installed in case the terminal supports neither.
+func notify(title, body string) {
+	if !*notifyFlag {
+		return
+	}
+	fmt.Printf("\a\033]9;%s: %s\033\\\033]777;notify;%s;%s\033\\", title, body, title, body)
+	if path, err := exec.LookPath("notify-send"); err == nil {
+		cmd := exec.Command(path, title, body)
+		cmd.Stderr = os.Stderr
+		_ = cmd.Run()
+	}
+}
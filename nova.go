@@ -0,0 +1,46 @@
+package main
+
+func init() {
+	registerFractal(70, novaFractal{})
+}
+
+// novaFractal is the Nova fractal with the relaxation the parameter
+type novaFractal struct{}
+
+func (novaFractal) Name() string                       { return "nova" }
+func (novaFractal) Title() string                      { return "Nova" }
+func (novaFractal) DefaultView() (complex128, float64) { return complex(-0.4, 0), 1.5 }
+func (novaFractal) Start() complex128                  { return 1 }
+func (novaFractal) Converges() bool                    { return true }
+
+func (novaFractal) Param() *fractalParam {
+	return &fractalParam{name: "relaxation", initial: 1, min: 0.05, max: 2, step: 0.05}
+}
+
+func (novaFractal) Iterate(z, c complex128, i, maxDepth int) (int, complex128) {
+	return nova(z, c, params.param, i, maxDepth)
+}
+
+// nova iterates the Nova fractal, Newton's method for z^3 - 1 with
+// relaxation relax plus c, from iteration i until it converges or
+// reaches maxDepth iterations
+//
+// For points which converge the last step is returned in place of z
+// as that is what the coloring needs. Points which don't converge
+// return their z so they can be carried on with. Points which head
+// off to infinity never converge so are given up on straight away.
+func nova(z, c complex128, relax float64, i, maxDepth int) (int, complex128) {
+	r := complex(relax, 0)
+	for ; i < maxDepth; i++ {
+		z2 := z * z
+		step := c - r*(z2*z-1)/(3*z2)
+		z += step
+		if real(step)*real(step)+imag(step)*imag(step) < convergedStep*convergedStep {
+			return i, step
+		}
+		if real(z)*real(z)+imag(z)*imag(z) > divergedSize {
+			return maxDepth, z
+		}
+	}
+	return i, z
+}
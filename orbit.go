@@ -0,0 +1,123 @@
+package main
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// Escape radius used while tracking the orbit - the distance estimate
+// and the stripe average are only good once |z| is large
+const orbitEscape = 1000
+
+// needsDerivative returns true if the coloring or lighting needs the
+// derivative of the orbit and the fractal being drawn has one worked
+// out, which the deep zoom kernels don't
+func needsDerivative() bool {
+	return (usesColoring(distanceColoring) || lighting || brailleEdges()) && isMandelbrot(params.fractal) && origin == nil
+}
+
+// orbitSum is what is added up over the orbit of each point for the
+// coloring
+type orbitSum int
+
+// The sums
+const (
+	noSum     orbitSum = iota
+	stripeSum          // stripe terms for stripe coloring
+	expSum             // exp(-|z|) for exponential coloring
+)
+
+// neededSum returns what the coloring needs added up over the orbits
+// of the fractal being drawn, noSum if nothing or if it can't be
+// worked out for the fractal or by the deep zoom kernels
+func neededSum() orbitSum {
+	_, colored := params.fractal.(colorFractal)
+	switch {
+	case origin != nil:
+		return noSum
+	case usesColoring(stripeColoring) && isMandelbrot(params.fractal):
+		return stripeSum
+	case usesColoring(expColoring) && !needsHistory(params.fractal) && !colored:
+		return expSum
+	}
+	return noSum
+}
+
+// escapeOrbit carries on iterating it with c until it escapes or
+// reaches maxDepth iterations, tracking the derivative of z and the
+// sum over the orbit too if the coloring needs them
+//
+// The derivative is with respect to the first z for Julia sets and
+// with respect to c otherwise. Points which end up inside the set
+// have their sum replaced by the interior value if the interior
+// coloring needs it. If the inside is all colored the same, points of
+// the Mandelbrot set in the main cardioid or the period 2 bulb aren't
+// iterated at all.
+func escapeOrbit(it iteration, c complex128, maxDepth int, julia bool) iteration {
+	if knownInside(c, julia) {
+		it.i = maxDepth
+		return it
+	}
+	sum := neededSum()
+	switch {
+	case !needsDerivative() && sum == noSum:
+		it.i, it.z = escape(it.z, c, it.i, maxDepth)
+	case isMandelbrot(params.fractal):
+		it = mandelbrotOrbit(it, c, maxDepth, julia, sum)
+	default:
+		it = stepOrbit(it, c, maxDepth)
+	}
+	if it.i >= maxDepth && needsInterior() {
+		it.sum = interiorValue(it.z, c)
+	}
+	return it
+}
+
+// knownInside returns true if the point with c is known to be inside
+// the set without iterating it, which is only worth knowing if the
+// inside is all colored the same
+func knownInside(c complex128, julia bool) bool {
+	return !julia && isMandelbrot(params.fractal) && flatInterior() && (inCardioid(c) || inPeriod2Bulb(c))
+}
+
+// mandelbrotOrbit is mandelbrot which also tracks dz, the derivative
+// of z, and adds up the terms of the orbit for sum
+//
+// It always works in float64 whatever the kernel setting.
+func mandelbrotOrbit(it iteration, c complex128, maxDepth int, julia bool, sum orbitSum) iteration {
+	i, z, dz, s := it.i, it.z, it.dz, it.sum
+	dc := complex(1, 0)
+	if julia {
+		dc = 0
+	}
+	// Derivative with respect to the first point after 0 for the
+	// interior test, and the periodicity check, as in mandelbrot
+	d1 := complex(1, 0)
+	eps := cycleEpsilon()
+	saved, check := z, i+periodFirstCheck
+	for ; i < maxDepth; i++ {
+		if real(z)*real(z)+imag(z)*imag(z) >= orbitEscape*orbitEscape {
+			break
+		}
+		if i > 0 {
+			d1 = 2 * z * d1
+			if real(d1)*real(d1)+imag(d1)*imag(d1) < interiorEpsilon {
+				return iteration{i: maxDepth, z: z, dz: dz, sum: s}
+			}
+		}
+		dz = 2*z*dz + dc
+		z = z*z + c
+		switch sum {
+		case stripeSum:
+			s += stripeTerm(z)
+		case expSum:
+			s += math.Exp(-cmplx.Abs(z))
+		}
+		if i == check {
+			saved, check = z, 2*check
+		} else if d := z - saved; real(d)*real(d)+imag(d)*imag(d) <= eps {
+			return iteration{i: maxDepth, z: z, dz: dz, sum: s}
+		}
+	}
+	return iteration{i: i, z: z, dz: dz, sum: s}
+}
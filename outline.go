@@ -0,0 +1,164 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/cmplx"
+	"slices"
+)
+
+// Number of points on each traced outline
+const outlinePoints = 720
+
+// Set to draw the outlines of the main cardioid and bulbs
+var showOutlines = false
+
+// component is a hyperbolic component of the Mandelbrot set to outline
+type component struct {
+	period int
+	center complex128 // the c for which 0 is periodic
+	col    color.RGBA
+}
+
+// The components outlined - the main cardioid, the period 2 bulb and
+// the period 3 bulbs and minibrot
+var components = []component{
+	{1, 0, color.RGBA{255, 255, 255, 255}},
+	{2, -1, color.RGBA{255, 255, 0, 255}},
+	{3, complex(-0.12256116687665, 0.74486176661974), color.RGBA{0, 255, 255, 255}},
+	{3, complex(-0.12256116687665, -0.74486176661974), color.RGBA{0, 255, 255, 255}},
+	{3, -1.75487766624670, color.RGBA{0, 255, 255, 255}},
+}
+
+// The outlines as points of the set, traced when first needed, with
+// NaN for any points which couldn't be found
+var outlines [][]complex128
+
+// cycleEquations evaluates the equations for c being the parameter
+// where z is on a cycle of the period given with multiplier lambda
+//
+//	f^p(z) - z = 0
+//	(f^p)'(z) - lambda = 0
+//
+// returning them and their Jacobian with respect to z and c.
+func cycleEquations(z, c, lambda complex128, period int) (f1, f2, j11, j12, j21, j22 complex128) {
+	zk, dzdz, dzdc := z, complex(1, 0), complex(0, 0)
+	p, dpdz, dpdc := complex(1, 0), complex(0, 0), complex(0, 0)
+	for k := 0; k < period; k++ {
+		// The multiplier is the product of 2 z_k round the cycle
+		p, dpdz, dpdc = 2*zk*p, 2*(dzdz*p+zk*dpdz), 2*(dzdc*p+zk*dpdc)
+		zk, dzdz, dzdc = zk*zk+c, 2*zk*dzdz, 2*zk*dzdc+1
+	}
+	return zk - z, p - lambda, dzdz - 1, dzdc, dpdz, dpdc
+}
+
+// solveCycle refines z and c with Newton's method so z is on a cycle
+// of the period given with multiplier lambda, returning false if it
+// doesn't converge
+func solveCycle(z, c *complex128, lambda complex128, period int) bool {
+	for i := 0; i < 50; i++ {
+		f1, f2, a, b, d, e := cycleEquations(*z, *c, lambda, period)
+		if cmplx.Abs(f1)+cmplx.Abs(f2) < 1e-13 {
+			return true
+		}
+		det := a*e - b*d
+		if det == 0 {
+			return false
+		}
+		*z -= (e*f1 - b*f2) / det
+		*c -= (a*f2 - d*f1) / det
+	}
+	return false
+}
+
+// traceOutline finds the boundary of the component, where the
+// multiplier of its cycle has size 1
+//
+// Starting from the center, where the multiplier is 0, it is moved
+// out to -1 and then each way round the unit circle, each solution
+// being the starting guess for the next. Both halves stop just short
+// of a multiplier of 1 at the root, where the cycle meets the one of
+// the parent component which the solution could jump to.
+func traceOutline(comp component) []complex128 {
+	z0, c0 := complex(0, 0), comp.center
+	for s := 1; s <= 20; s++ {
+		solveCycle(&z0, &c0, complex(-float64(s)/20, 0), comp.period)
+	}
+	half := func(dir float64) []complex128 {
+		z, c := z0, c0
+		var points []complex128
+		for i := 0; i < outlinePoints/2; i++ {
+			lambda := -cmplx.Exp(complex(0, dir*2*math.Pi*float64(i)/outlinePoints))
+			if !solveCycle(&z, &c, lambda, comp.period) {
+				points = append(points, cmplx.NaN())
+				continue
+			}
+			points = append(points, c)
+		}
+		return points
+	}
+	points := half(-1)
+	slices.Reverse(points)
+	return append(points, half(1)...)
+}
+
+// outlineOverlay returns a transparent width x height image of the
+// view with the outlines drawn on it
+func outlineOverlay(width, height int) *image.RGBA {
+	if outlines == nil {
+		for _, comp := range components {
+			outlines = append(outlines, traceOutline(comp))
+		}
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	x0, y0, dx, dy := viewGrid(absCenter(), radius, width, height)
+	thickness := max(1, int(fontSize/10))
+	for i, points := range outlines {
+		col := components[i].col
+		for j := 1; j < len(points); j++ {
+			a, b := points[j-1], points[j]
+			if cmplx.IsNaN(a) || cmplx.IsNaN(b) {
+				continue
+			}
+			drawLine(img, (real(a)-x0)/dx, (imag(a)-y0)/dy, (real(b)-x0)/dx, (imag(b)-y0)/dy, thickness, col)
+		}
+	}
+	return img
+}
+
+// drawLine draws a line from x0, y0 to x1, y1 in img
+//
+// The line is clipped to the image first so the very long lines
+// there are when zoomed in don't take long to draw.
+func drawLine(img *image.RGBA, x0, y0, x1, y1 float64, thickness int, col color.RGBA) {
+	w, h := float64(img.Rect.Dx()), float64(img.Rect.Dy())
+	// Liang-Barsky clipping of the part of the line from t0 to t1
+	ddx, ddy := x1-x0, y1-y0
+	t0, t1 := 0.0, 1.0
+	for _, edge := range [4][2]float64{{-ddx, x0}, {ddx, w - x0}, {-ddy, y0}, {ddy, h - y0}} {
+		p, q := edge[0], edge[1]
+		if p == 0 {
+			if q < 0 {
+				return
+			}
+			continue
+		}
+		t := q / p
+		if p < 0 {
+			t0 = max(t0, t)
+		} else {
+			t1 = min(t1, t)
+		}
+	}
+	if t0 > t1 {
+		return
+	}
+	x0, y0, x1, y1 = x0+t0*ddx, y0+t0*ddy, x0+t1*ddx, y0+t1*ddy
+	steps := max(1, int(math.Ceil(max(math.Abs(x1-x0), math.Abs(y1-y0)))))
+	for k := 0; k <= steps; k++ {
+		f := float64(k) / float64(steps)
+		x, y := int(x0+(x1-x0)*f), int(y0+(y1-y0)*f)
+		fillRect(img, image.Rect(x, y, x+thickness, y+thickness), col)
+	}
+}
@@ -0,0 +1,621 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image/color"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Flags
+var (
+	paletteFlag  = flag.String("palette", "", "Palette to start with - classic, fire, ocean, ultra, viridis, rainbow, deutan, protan, tritan or a .map, .ugr, .json or image file")
+	gradientFlag = flag.String("gradient", "", "Gradient to start with as position:color stops, eg \"0:000000,0.3:0044ff,0.7:ffdd00,1:ffffff\"")
+)
+
+// Number of entries in an exported .map palette, which is what
+// Fractint and friends expect
+const mapEntries = 256
+
+// Number of color stops in an exported .ugr gradient, Ultra Fractal
+// places them at indexes 0 to 399
+const ugrIndexes = 400
+
+// builtinPalette is one of the palettes which come with termbrot
+type builtinPalette struct {
+	name   string
+	colors []color.RGBA // the gradient stops, spaced evenly
+}
+
+// The built in palettes, the first is the default
+var palettes = []builtinPalette{
+	{
+		name: "classic",
+		colors: []color.RGBA{
+			{0, 0, 0, 255},       // Black
+			{0, 0, 255, 255},     // Blue
+			{255, 0, 0, 255},     // Red
+			{255, 255, 0, 255},   // Yellow
+			{255, 255, 255, 255}, // White
+		},
+	},
+	{
+		name: "fire",
+		colors: []color.RGBA{
+			{0, 0, 0, 255},
+			{128, 0, 0, 255},
+			{255, 64, 0, 255},
+			{255, 160, 0, 255},
+			{255, 255, 64, 255},
+			{255, 255, 255, 255},
+		},
+	},
+	{
+		name: "ocean",
+		colors: []color.RGBA{
+			{0, 0, 16, 255},
+			{0, 32, 96, 255},
+			{0, 96, 160, 255},
+			{0, 180, 200, 255},
+			{160, 240, 255, 255},
+			{255, 255, 255, 255},
+		},
+	},
+	{
+		// The default gradient of Ultra Fractal, much copied
+		name: "ultra",
+		colors: []color.RGBA{
+			{0, 7, 100, 255},
+			{32, 107, 203, 255},
+			{237, 255, 255, 255},
+			{255, 170, 0, 255},
+			{0, 2, 0, 255},
+		},
+	},
+	{
+		// Perceptually uniform like matplotlib's viridis
+		name: "viridis",
+		colors: []color.RGBA{
+			{68, 1, 84, 255},
+			{72, 40, 120, 255},
+			{62, 74, 137, 255},
+			{49, 104, 142, 255},
+			{38, 130, 142, 255},
+			{31, 158, 137, 255},
+			{53, 183, 121, 255},
+			{109, 205, 89, 255},
+			{180, 222, 44, 255},
+			{253, 231, 37, 255},
+		},
+	},
+	{
+		name: "rainbow",
+		colors: []color.RGBA{
+			{255, 0, 0, 255},
+			{255, 165, 0, 255},
+			{255, 255, 0, 255},
+			{0, 255, 0, 255},
+			{0, 255, 255, 255},
+			{0, 0, 255, 255},
+			{255, 0, 255, 255},
+		},
+	},
+	{
+		// Blues into yellow for deuteranopia, which confuses red
+		// and green, from the Okabe-Ito colors
+		name: "deutan",
+		colors: []color.RGBA{
+			{0, 0, 0, 255},
+			{0, 32, 81, 255},
+			{0, 114, 178, 255},
+			{86, 180, 233, 255},
+			{240, 228, 66, 255},
+			{255, 255, 255, 255},
+		},
+	},
+	{
+		// Like cividis, blue into yellow with no red for protanopia,
+		// which sees reds darker as well as confusing them with
+		// greens
+		name: "protan",
+		colors: []color.RGBA{
+			{0, 32, 77, 255},
+			{49, 68, 107, 255},
+			{102, 105, 112, 255},
+			{149, 143, 120, 255},
+			{203, 186, 105, 255},
+			{255, 234, 70, 255},
+		},
+	},
+	{
+		// Teals into reds for tritanopia, which confuses blue and
+		// yellow
+		name: "tritan",
+		colors: []color.RGBA{
+			{0, 0, 0, 255},
+			{0, 64, 74, 255},
+			{0, 134, 143, 255},
+			{224, 72, 90, 255},
+			{255, 179, 179, 255},
+			{255, 255, 255, 255},
+		},
+	},
+}
+
+// paletteIndex returns the index into palettes of the gradient in
+// use, or -1 if it isn't one of them
+func paletteIndex() int {
+	return slices.IndexFunc(palettes, func(p builtinPalette) bool {
+		return slices.Equal(evenStops(p.colors), gradient)
+	})
+}
+
+// paletteName returns the name of the palette in use, or "custom" if
+// it isn't one of the built in ones
+func paletteName() string {
+	if i := paletteIndex(); i >= 0 {
+		return palettes[i].name
+	}
+	return "custom"
+}
+
+// setPalette changes the gradient to the built in palette called name
+func setPalette(name string) error {
+	if i := paletteByName(name); i >= 0 {
+		setGradient(evenStops(palettes[i].colors))
+		return nil
+	}
+	var names []string
+	for _, p := range palettes {
+		names = append(names, p.name)
+	}
+	return fmt.Errorf("unknown palette %q - use one of %s", name, strings.Join(names, ", "))
+}
+
+// nextPalette cycles to the next built in palette
+func nextPalette() {
+	setGradient(evenStops(palettes[(paletteIndex()+1)%len(palettes)].colors))
+	message = fmt.Sprintf(tr("Palette %s"), paletteName())
+}
+
+// randomPalette makes a random gradient and uses it
+//
+// The hues wander round the color wheel at random but the lightness
+// goes steadily up from dark to light in OKLCH, as in the built in
+// palettes, so the colors always flow from the outside in to the edge
+// of the set without jumping about.
+func randomPalette() {
+	n := 4 + rand.Intn(4)
+	h := rand.Float64()
+	colors := make([]color.RGBA, n)
+	for i := range colors {
+		l := 0.15 + 0.8*float64(i)/float64(n-1)
+		colors[i] = oklchToRGB(l, 0.06+0.12*rand.Float64(), h-math.Floor(h))
+		h += 0.05 + 0.3*rand.Float64()
+	}
+	setGradient(evenStops(colors))
+	message = tr("Random palette - P to save it")
+}
+
+// coloringID identifies the gradient in use and is part of the
+// identity of plots and tiles, so changing the gradient doesn't reuse
+// pixels colored with the old one
+//
+// The palette offset isn't part of it, as pixels colored with another
+// offset are just colored again, see plot.paletteOffset.
+var coloringID int
+
+// gradientStop is a color of the gradient and how far along it it is
+// from 0 to 1
+type gradientStop struct {
+	pos float64
+	col color.RGBA
+}
+
+// evenStops spaces colors evenly along a gradient
+func evenStops(colors []color.RGBA) []gradientStop {
+	stops := make([]gradientStop, len(colors))
+	for i, col := range colors {
+		stops[i] = gradientStop{pos: float64(i) / float64(max(1, len(colors)-1)), col: col}
+	}
+	return stops
+}
+
+// evenlySpaced returns true if the stops are spaced evenly to within
+// tolerance so can be written without their positions
+func evenlySpaced(stops []gradientStop, tolerance float64) bool {
+	for i, s := range stops {
+		if math.Abs(s.pos-float64(i)/float64(max(1, len(stops)-1))) > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// setGradient changes the gradient to stops
+func setGradient(stops []gradientStop) {
+	if len(stops) == 0 || slices.Equal(stops, gradient) {
+		return
+	}
+	gradient = slices.Clone(stops)
+	coloringID++
+	recolor()
+}
+
+// paletteFile is the JSON form of a palette
+type paletteFile struct {
+	Name   string   `json:"name"`
+	Colors []string `json:"colors"` // the gradient stops as #rrggbb if spaced evenly, otherwise position:#rrggbb
+}
+
+// hexColor formats col as #rrggbb
+func hexColor(col color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", col.R, col.G, col.B)
+}
+
+// parseHexColor parses a color written as #rrggbb, the # being
+// optional
+func parseHexColor(s string) (color.RGBA, error) {
+	var col color.RGBA
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 {
+		return col, fmt.Errorf("bad color %q - use #rrggbb", s)
+	}
+	if _, err := fmt.Sscanf(hex, "%02x%02x%02x", &col.R, &col.G, &col.B); err != nil {
+		return col, fmt.Errorf("bad color %q - use #rrggbb", s)
+	}
+	col.A = 255
+	return col, nil
+}
+
+// gradientHex returns the gradient stops as #rrggbb, with their
+// positions in front as position:#rrggbb unless spaced evenly
+func gradientHex() []string {
+	even := evenlySpaced(gradient, 1e-9)
+	var colors []string
+	for _, s := range gradient {
+		if even {
+			colors = append(colors, hexColor(s.col))
+		} else {
+			colors = append(colors, fmt.Sprintf("%g:%s", s.pos, hexColor(s.col)))
+		}
+	}
+	return colors
+}
+
+// parseGradient parses gradient stops written as #rrggbb, which are
+// spaced evenly, or position:#rrggbb with the positions going up from
+// 0 to 1
+func parseGradient(colors []string) ([]gradientStop, error) {
+	var stops []gradientStop
+	positioned := 0
+	for _, s := range colors {
+		posText, colText, hasPos := strings.Cut(strings.TrimSpace(s), ":")
+		if !hasPos {
+			colText = posText
+		}
+		col, err := parseHexColor(colText)
+		if err != nil {
+			return nil, err
+		}
+		stop := gradientStop{col: col}
+		if hasPos {
+			positioned++
+			stop.pos, err = strconv.ParseFloat(posText, 64)
+			if err != nil || !(stop.pos >= 0 && stop.pos <= 1) {
+				return nil, fmt.Errorf("bad position %q - use 0 to 1", posText)
+			}
+			if len(stops) > 0 && stop.pos < stops[len(stops)-1].pos {
+				return nil, fmt.Errorf("position %g is before the one in front of it", stop.pos)
+			}
+		}
+		stops = append(stops, stop)
+	}
+	switch positioned {
+	case 0:
+		for i := range stops {
+			stops[i].pos = float64(i) / float64(max(1, len(stops)-1))
+		}
+	case len(stops):
+	default:
+		return nil, fmt.Errorf("give the positions of all the stops or none of them")
+	}
+	return stops, nil
+}
+
+// mapPalette formats the gradient as a Fractint .map file with one
+// "R G B" line per entry
+func mapPalette() string {
+	var b strings.Builder
+	for i := 0; i < mapEntries; i++ {
+		col := gradientColor(float64(i) / (mapEntries - 1))
+		fmt.Fprintf(&b, "%d %d %d\n", col.R, col.G, col.B)
+	}
+	return b.String()
+}
+
+// ugrPalette formats the gradient as an Ultra Fractal .ugr file
+// called name
+//
+// The colors are written as R + 256*G + 65536*B at indexes from 0 to
+// 399.
+func ugrPalette(name string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s {\ngradient:\n  title=%q smooth=yes\n", name, name)
+	for _, s := range gradient {
+		index := int(math.Round(s.pos * (ugrIndexes - 1)))
+		fmt.Fprintf(&b, "  index=%d color=%d\n", index, int(s.col.R)+int(s.col.G)<<8+int(s.col.B)<<16)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// jsonPalette formats the gradient as JSON
+func jsonPalette(name string) (string, error) {
+	p := paletteFile{Name: name, Colors: gradientHex()}
+	data, err := json.MarshalIndent(p, "", "\t")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// savePalette writes the gradient to path in the format given by its
+// extension, .map, .ugr or .json
+func savePalette(path string) error {
+	ext := strings.ToLower(filepath.Ext(path))
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	var text string
+	switch ext {
+	case ".map":
+		text = mapPalette()
+	case ".ugr":
+		text = ugrPalette(name)
+	case ".json":
+		var err error
+		text, err = jsonPalette(name)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf(tr("unknown palette format %q - use .map, .ugr or .json"), ext)
+	}
+	return os.WriteFile(path, []byte(text), 0644)
+}
+
+// exportPalette asks for a file name and saves the palette to it
+func exportPalette() {
+	startPrompt(tr("Save palette as:"), func(text string) error {
+		if text == "" {
+			return nil
+		}
+		if err := savePalette(text); err != nil {
+			return err
+		}
+		message = fmt.Sprintf(tr("Palette saved to %s"), text)
+		return nil
+	})
+}
+
+// startPalette sets the palette from --gradient or --palette, or
+// from the config file if neither is given
+func startPalette() error {
+	var err error
+	switch {
+	case *gradientFlag != "":
+		if err = setGradientSpec(*gradientFlag); err != nil {
+			err = fmt.Errorf("--gradient: %w", err)
+		}
+	case *paletteFlag != "":
+		if err = usePalette(*paletteFlag); err != nil {
+			err = fmt.Errorf("--palette: %w", err)
+		}
+	case *paletteImageFlag != "":
+		var stops []gradientStop
+		if stops, err = loadPalette(*paletteImageFlag); err != nil {
+			err = fmt.Errorf("--palette-from-image: %w", err)
+		}
+		setGradient(stops)
+	case cfg.Gradient != "":
+		if err = setGradientSpec(cfg.Gradient); err != nil {
+			err = fmt.Errorf("gradient in config: %w", err)
+		}
+	case cfg.Palette != "":
+		if err = usePalette(cfg.Palette); err != nil {
+			err = fmt.Errorf("palette in config: %w", err)
+		}
+	}
+	return err
+}
+
+// usePalette changes to the built in palette called name, or else
+// loads the palette from the file name
+func usePalette(name string) error {
+	if paletteByName(name) >= 0 {
+		return setPalette(name)
+	}
+	stops, err := loadPalette(name)
+	if err != nil {
+		return err
+	}
+	setGradient(stops)
+	return nil
+}
+
+// setGradientSpec changes the gradient to the comma separated stops
+// in spec, eg "0:000000,0.3:0044ff,0.7:ffdd00,1:ffffff"
+func setGradientSpec(spec string) error {
+	stops, err := parseGradient(strings.Split(spec, ","))
+	if err != nil {
+		return err
+	}
+	setGradient(stops)
+	return nil
+}
+
+// paletteByName returns the index into palettes of the one called
+// name, or -1 if there isn't one
+func paletteByName(name string) int {
+	return slices.IndexFunc(palettes, func(p builtinPalette) bool {
+		return p.name == name
+	})
+}
+
+// loadPalette reads the gradient stops from path in the format given
+// by its extension, .map, .ugr or .json, or makes them from the
+// colors of a .png or .jpg image
+func loadPalette(path string) ([]gradientStop, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var stops []gradientStop
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".map":
+		var colors []color.RGBA
+		colors, err = parseMapPalette(data)
+		stops = evenStops(colors)
+	case ".ugr":
+		stops, err = parseUgrPalette(data)
+	case ".json":
+		var p paletteFile
+		if err = json.Unmarshal(data, &p); err == nil {
+			stops, err = parseGradient(p.Colors)
+		}
+	case ".png", ".jpg", ".jpeg":
+		stops, err = parseImagePalette(data)
+	default:
+		return nil, fmt.Errorf(tr("unknown palette format %q - use .map, .ugr, .json or an image"), ext)
+	}
+	if err == nil && len(stops) == 0 {
+		err = fmt.Errorf("no colors found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return stops, nil
+}
+
+// parseMapPalette parses a Fractint .map file, which has one entry
+// per line as "R G B" with anything after that being a comment
+func parseMapPalette(data []byte) ([]color.RGBA, error) {
+	var colors []color.RGBA
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], ";") || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("line %d: need R G B", line)
+		}
+		var rgb [3]uint8
+		for i := range rgb {
+			v, err := strconv.ParseUint(fields[i], 10, 8)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: bad value %q", line, fields[i])
+			}
+			rgb[i] = uint8(v)
+		}
+		colors = append(colors, color.RGBA{rgb[0], rgb[1], rgb[2], 255})
+	}
+	return colors, scanner.Err()
+}
+
+// ugrStop is a color stop of an Ultra Fractal gradient
+type ugrStop struct {
+	index int
+	col   color.RGBA
+}
+
+// parseUgrPalette parses the first gradient in an Ultra Fractal .ugr
+// file
+//
+// The stops are "index=N color=C" where C is R + 256*G + 65536*B and
+// the indexes go round from 0 to 399, so if there are no stops at the
+// ends they are made by going round from the last stop to the first.
+// Evenly spaced stops, as termbrot saves, are put back evenly spaced.
+func parseUgrPalette(data []byte) ([]gradientStop, error) {
+	var stops []ugrStop
+	index, haveIndex := 0, false
+	for _, field := range strings.Fields(string(data)) {
+		if field == "}" && len(stops) > 0 {
+			break
+		}
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || key != "index" && key != "color" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("bad %s %q", key, value)
+		}
+		if key == "index" {
+			index, haveIndex = n, true
+		} else if haveIndex {
+			stops = append(stops, ugrStop{index: (index%ugrIndexes + ugrIndexes) % ugrIndexes, col: color.RGBA{uint8(n), uint8(n >> 8), uint8(n >> 16), 255}})
+			haveIndex = false
+		}
+	}
+	if len(stops) == 0 {
+		return nil, nil
+	}
+	sort.SliceStable(stops, func(i, j int) bool { return stops[i].index < stops[j].index })
+	first, last := stops[0], stops[len(stops)-1]
+	wrapped := func(index int) color.RGBA {
+		return mixRGB(last.col, first.col, float64(index-last.index)/float64(first.index+ugrIndexes-last.index))
+	}
+	var result []gradientStop
+	if first.index > 0 {
+		result = append(result, gradientStop{pos: 0, col: wrapped(ugrIndexes)})
+	}
+	for _, s := range stops {
+		result = append(result, gradientStop{pos: float64(s.index) / (ugrIndexes - 1), col: s.col})
+	}
+	if last.index < ugrIndexes-1 {
+		result = append(result, gradientStop{pos: 1, col: wrapped(ugrIndexes - 1)})
+	}
+	if evenlySpaced(result, 0.5/(ugrIndexes-1)) {
+		// Saved from an evenly spaced gradient so put it back exactly
+		for i := range result {
+			result[i].pos = float64(i) / float64(max(1, len(result)-1))
+		}
+	}
+	return result, nil
+}
+
+// mixRGB returns the color frac of the way from a to b
+func mixRGB(a, b color.RGBA, frac float64) color.RGBA {
+	mix := func(x, y uint8) uint8 {
+		return uint8(float64(x)*(1-frac) + float64(y)*frac + 0.5)
+	}
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255}
+}
+
+// importPalette asks for a file name and loads the palette from it,
+// completing file names with tab
+func importPalette() {
+	p := startPrompt(tr("Load palette from:"), func(text string) error {
+		if text == "" {
+			return nil
+		}
+		stops, err := loadPalette(text)
+		if err != nil {
+			return err
+		}
+		setGradient(stops)
+		message = fmt.Sprintf(tr("Palette loaded from %s"), text)
+		return nil
+	})
+	p.complete = completeFileName
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"math/big"
+	"math/cmplx"
+	"slices"
+	"sync"
+)
+
+// Series approximation settings
+const (
+	// The series is made to work for points up to this many radii
+	// of the view from the reference, which covers the view and the
+	// strips round it which are prefetched
+	seriesRadius = 3
+
+	// Iterations are skipped while the cubic term of the series is
+	// this much smaller than the square term, so the terms left off
+	// are too small to matter
+	seriesTolerance = 1e-4
+)
+
+// Glitch correction settings
+const (
+	// A point is glitched if |z|² falls below this fraction of |Z|²,
+	// as then the difference from the reference orbit has grown as
+	// big as the orbit and lost the precision which tells the points
+	// apart
+	glitchTolerance = 1e-6
+
+	// Most secondary reference orbits worked out for a frame
+	maxReferences = 32
+
+	// Most references each glitched point is tried against
+	glitchRetries = 4
+)
+
+// reference is the orbit of a point of the Mandelbrot set worked out
+// to full precision, which the points near it are iterated against
+type reference struct {
+	origin *deepPoint   // the point at is relative to
+	at     complex128   // the point, relative to origin
+	depth  int          // iterations the orbit was worked out to
+	orbit  []complex128 // z at each iteration rounded to complex128, ending with the first which escaped if it did
+
+	// The series approximation of the difference from the orbit
+	// after skip iterations, as a cubic in the difference from the
+	// point, which is good for differences up to reach, and the
+	// derivative of the orbit there, which is close enough to the
+	// derivative of the orbits it is used for
+	skip    int
+	a, b, c complex128
+	dz      complex128
+	reach   float64
+
+	// Secondary references for points which glitched against the
+	// orbit of the frame, guarded by referenceMu
+	secondary []*reference
+}
+
+// The reference orbit of the frame, guarded by referenceMu
+var (
+	referenceMu   sync.Mutex
+	mainReference *reference
+)
+
+// newReference works out the orbit of the point at, relative to
+// origin, to maxDepth iterations or until it escapes
+func newReference(at complex128, maxDepth int) *reference {
+	prec := deepPrecision()
+	if origin != nil {
+		prec = origin.re.Prec()
+	}
+	c := newDeepPoint(origin, at, prec)
+	x, y := new(big.Float).SetPrec(prec), new(big.Float).SetPrec(prec)
+	x2, y2 := new(big.Float).SetPrec(prec), new(big.Float).SetPrec(prec)
+	r := &reference{origin: origin, at: at, depth: maxDepth}
+	for i := 0; i <= maxDepth; i++ {
+		re, _ := x.Float64()
+		im, _ := y.Float64()
+		z := complex(re, im)
+		r.orbit = append(r.orbit, z)
+		if real(z)*real(z)+imag(z)*imag(z) >= 4 {
+			break
+		}
+		x2.Mul(x, x)
+		y2.Mul(y, y)
+		y.Mul(y, x)
+		y.Add(y, y)
+		y.Add(y, c.im)
+		x.Sub(x2, y2)
+		x.Add(x, c.re)
+	}
+	r.series(seriesRadius * radius)
+	return r
+}
+
+// series works out how many iterations the points up to reach from
+// the reference point can skip with the series approximation and the
+// coefficients of the series there
+//
+// The difference d of an orbit from the reference orbit Z after n
+// iterations is close to a·dc + b·dc² + c·dc³ for small dc, where
+// a' = 2Za + 1, b' = 2Zb + a² and c' = 2Zc + 2ab, so the iterations
+// up to where that stops being accurate don't need doing for every
+// point. It stops short of the end of the orbit.
+func (r *reference) series(reach float64) {
+	r.reach = reach
+	var a, b, c complex128
+	dz := complex(1, 0)
+	for n := 0; n < len(r.orbit)-2; n++ {
+		z := r.orbit[n]
+		a, b, c = 2*z*a+1, 2*z*b+a*a, 2*z*c+2*a*b
+		if n > 0 && !(cmplx.Abs(c)*reach < seriesTolerance*cmplx.Abs(b)) {
+			break
+		}
+		if n > 0 {
+			dz *= 2 * z
+		}
+		r.skip, r.a, r.b, r.c, r.dz = n+1, a, b, c, dz
+	}
+}
+
+// escaped returns true if the reference orbit escaped
+func (r *reference) escaped() bool {
+	return len(r.orbit) <= r.depth
+}
+
+// frameReference returns the reference orbit at the center of the
+// view, working it out again if the view has moved or it isn't deep
+// enough for maxDepth
+func frameReference(maxDepth int) *reference {
+	referenceMu.Lock()
+	defer referenceMu.Unlock()
+	r := mainReference
+	if r == nil || r.origin != origin || r.at != center || !r.escaped() && r.depth < maxDepth {
+		r = newReference(center, maxDepth)
+		mainReference = r
+	}
+	return r
+}
+
+// perturbIterate iterates the point p, relative to origin, of the
+// Mandelbrot set by perturbation against the reference orbit of the
+// frame
+//
+// If the point glitches it is iterated again against secondary
+// reference orbits until one works.
+func perturbIterate(p complex128, maxDepth int) iteration {
+	frame := frameReference(maxDepth)
+	it, glitched := frame.iterate(p-frame.at, maxDepth)
+	tried := []*reference{frame}
+	for glitched && len(tried) <= glitchRetries {
+		r := frame.secondaryFor(p, maxDepth, tried)
+		if r == nil {
+			break
+		}
+		it, glitched = r.iterate(p-r.at, maxDepth)
+		tried = append(tried, r)
+	}
+	return it
+}
+
+// secondaryFor returns a secondary reference of the frame reference r
+// to iterate the glitched point p against, which isn't one of those
+// tried already, or nil if there are too many already
+//
+// The nearest secondary reference is tried first, as the points of a
+// glitch usually all work against a reference in it, and if there
+// isn't one or it has been tried a new one is made at p itself, which
+// can't glitch against it.
+func (r *reference) secondaryFor(p complex128, maxDepth int, tried []*reference) *reference {
+	referenceMu.Lock()
+	defer referenceMu.Unlock()
+	var nearest *reference
+	for _, s := range r.secondary {
+		if !slices.Contains(tried, s) && (nearest == nil || cmplx.Abs(s.at-p) < cmplx.Abs(nearest.at-p)) {
+			nearest = s
+		}
+	}
+	if nearest != nil && len(tried) == 1 {
+		return nearest
+	}
+	if len(r.secondary) >= maxReferences {
+		return nearest
+	}
+	s := newReference(p, maxDepth)
+	r.secondary = append(r.secondary, s)
+	return s
+}
+
+// iterate iterates the point dc from the reference point by
+// perturbation
+//
+// Only the difference d of the orbit from the reference orbit Z is
+// iterated, as d' = 2Zd + d² + dc, which stays small enough for
+// float64 at any depth, and z is Z + d. When the reference orbit runs
+// out, z is carried on against its start, as Z is 0 there so d is z,
+// which also lets points outlast a reference which escaped.
+//
+// The first iterations are skipped with the series approximation if
+// dc is in its reach, unless the point escaped during them. The
+// derivative of z is tracked like mandelbrot does to stop early
+// inside the set.
+//
+// It returns true if the point glitched, which is detected the way
+// Pauldelbrot found, by z getting much smaller than Z.
+func (r *reference) iterate(dc complex128, maxDepth int) (iteration, bool) {
+	orbit := r.orbit
+	var d complex128
+	dz := complex(1, 0)
+	m := 0
+	if r.skip > 0 && r.skip < maxDepth && cmplx.Abs(dc) <= r.reach {
+		d = ((r.c*dc+r.b)*dc + r.a) * dc
+		if z := orbit[r.skip] + d; real(z)*real(z)+imag(z)*imag(z) < 4 {
+			m, dz = r.skip, r.dz
+		} else {
+			d = 0
+		}
+	}
+	i := m
+	for ; i < maxDepth; i++ {
+		zr := orbit[m]
+		z := zr + d
+		zz := real(z)*real(z) + imag(z)*imag(z)
+		if zz >= 4 {
+			return iteration{i: i, z: z}, false
+		}
+		if zz < glitchTolerance*(real(zr)*real(zr)+imag(zr)*imag(zr)) {
+			return iteration{i: i, z: z}, true
+		}
+		if i > 0 {
+			dz = 2 * z * dz
+			if real(dz)*real(dz)+imag(dz)*imag(dz) < interiorEpsilon {
+				return iteration{i: maxDepth, z: z}, false
+			}
+		}
+		if m == len(orbit)-1 {
+			d, m = z, 0
+		}
+		d = 2*orbit[m]*d + d*d + dc
+		m++
+	}
+	return iteration{i: i, z: orbit[m] + d}, false
+}
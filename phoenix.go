@@ -0,0 +1,40 @@
+package main
+
+func init() {
+	registerFractal(80, phoenixFractal{})
+}
+
+// phoenixFractal is the Phoenix fractal with p the parameter
+type phoenixFractal struct{}
+
+func (phoenixFractal) Name() string                       { return "phoenix" }
+func (phoenixFractal) Title() string                      { return "Phoenix" }
+func (phoenixFractal) DefaultView() (complex128, float64) { return complex(-0.3, 0), 1.5 }
+func (phoenixFractal) History() bool                      { return true }
+
+func (phoenixFractal) Param() *fractalParam {
+	return &fractalParam{name: "p", initial: -0.5, min: -1, max: 1, step: 0.05}
+}
+
+func (phoenixFractal) Iterate(z, c complex128, i, maxDepth int) (int, complex128) {
+	return phoenix(z, c, params.param, i, maxDepth)
+}
+
+// phoenix iterates the Phoenix fractal from iteration i until it
+// escapes or reaches maxDepth iterations
+//
+//	z_{n+1} = z_n^2 + c + p z_{n-1}
+//
+// As the previous z isn't kept between calls it can only start from
+// the beginning with i = 0.
+func phoenix(z, c complex128, p float64, i, maxDepth int) (int, complex128) {
+	prev := complex(0, 0)
+	pz := complex(p, 0)
+	for ; i < maxDepth; i++ {
+		if real(z)*real(z)+imag(z)*imag(z) >= escapeRadius*escapeRadius {
+			break
+		}
+		z, prev = z*z+c+pz*prev, z
+	}
+	return i, z
+}
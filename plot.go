@@ -0,0 +1,316 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"sync"
+)
+
+// plot is a rectangle of the set computed on a grid of pixels
+//
+// Plots on the same grid can be used to fill in each other, so if the
+// view has only moved by whole pixels the overlapping part of the
+// last plot is shifted into place and only the newly exposed strips
+// need computing.
+type plot struct {
+	data       []byte      // RGB pixels
+	iters      []iteration // iteration results for each pixel
+	width      int
+	height     int
+	x0, y0     float64      // set co-ordinates of the top left pixel
+	dx, dy     float64      // size of a pixel in set co-ordinates
+	baseDepth  int          // depth requested by the user
+	depth      int          // depth the plot has been iterated to
+	decompose  bool         // set if plotted with binary decomposition
+	coloring   int          // coloringID of the coloring plotted with
+	derivative bool         // set if the iterations track the derivative
+	sum        orbitSum     // what the iterations add up over their orbits
+	interior   interiorMode // interior coloring the iterations were worked out for
+	params     fractalParams
+	origin     *deepPoint // the point the set co-ordinates are relative to
+	refined    bool       // set when no more refinement is possible
+	aliased    bool       // set if the plot still needs antialiasing
+	samples    int        // jittered passes accumulated into the pixels
+
+	// The palette offset the pixels were colored with, which isn't
+	// part of the coloringID so changing it only colors the plots
+	// again rather than throwing them away
+	paletteOffset float64
+
+	// The samples averaged into each pixel by antialiasing so it can
+	// be colored again without losing it, nil for the pixels which
+	// are just their iteration, and how many there are. If they
+	// wouldn't fit in the memory they aren't kept and lostSamples is
+	// set.
+	supersamples [][]sample
+	kept         int
+	lostSamples  bool
+}
+
+// sample is an iteration result averaged into the color of a pixel
+// with the weight given
+type sample struct {
+	it     iteration
+	weight float32
+}
+
+// lastPlot is the last full resolution plot of the view
+var lastPlot plot
+
+// Rows in each band of a plot worked out off screen, which are
+// split into tiles to be worked out by subdivision
+const bandHeight = 32
+
+// viewGrid returns the top left and pixel size of a width x height
+// image of the view with the center and radius given
+func viewGrid(c complex128, r float64, width, height int) (x0, y0, dx, dy float64) {
+	dx, dy = getSetSizeAt(r, width, height)
+	x0 = real(c) + dx*float64(-width/2)
+	y0 = imag(c) + dy*float64(-height/2)
+	return x0, y0, dx, dy
+}
+
+// offset returns the offset in pixels of the grid with top left at
+// x0, y0 into p and whether p can be used to fill it in.
+//
+// Pixel (x, y) of the grid is pixel (x+ox, y+oy) of p.
+func (p *plot) offset(x0, y0, dx, dy float64, plotDepth int) (ox, oy int, ok bool) {
+	if p.data == nil || p.dx != dx || p.dy != dy || p.baseDepth != depth || p.depth != plotDepth || p.params != params || p.origin != origin || !p.recolorable() {
+		return 0, 0, false
+	}
+	fx := (x0 - p.x0) / dx
+	fy := (y0 - p.y0) / dy
+	if math.Abs(fx) > 1e9 || math.Abs(fy) > 1e9 {
+		return 0, 0, false
+	}
+	ox, oy = int(math.Round(fx)), int(math.Round(fy))
+	if math.Abs(fx-float64(ox)) > 1e-3 || math.Abs(fy-float64(oy)) > 1e-3 {
+		return 0, 0, false
+	}
+	return ox, oy, true
+}
+
+// plotSource is a plot which lines up with the grid being plotted
+type plotSource struct {
+	p      *plot
+	ox, oy int
+}
+
+// fillRow copies the pixels of row y of the grid which the source
+// covers and which aren't already covered, coloring them again if
+// the source was colored differently
+func (s plotSource) fillRow(y, width int, line []byte, iters []iteration, covered []bool) {
+	sy := y + s.oy
+	if sy < 0 || sy >= s.p.height {
+		return
+	}
+	xs, xe := max(0, -s.ox), min(width, s.p.width-s.ox)
+	colored := s.p.colored()
+	for x := xs; x < xe; x++ {
+		if covered[x] {
+			continue
+		}
+		sp := sy*s.p.width + x + s.ox
+		if colored {
+			copy(line[3*x:3*x+3], s.p.data[3*sp:3*sp+3])
+		} else {
+			col := s.p.pixelColor(sp)
+			line[3*x+0], line[3*x+1], line[3*x+2] = col.R, col.G, col.B
+		}
+		iters[x] = s.p.iters[sp]
+		covered[x] = true
+	}
+}
+
+// calculateUncovered computes the runs of pixels in the row which
+// aren't covered, adding a goroutine to wg for each
+func calculateUncovered(fx, fy, dx float64, width, maxDepth int, iters []iteration, covered []bool, wg *sync.WaitGroup) {
+	for x := 0; x < width; {
+		if covered[x] {
+			x++
+			continue
+		}
+		end := x + 1
+		for end < width && !covered[end] {
+			end++
+		}
+		calculateRow(fx+dx*float64(x), fy, dx, end-x, maxDepth, iters[x:end], wg)
+		x = end
+	}
+}
+
+// calculateRow computes the row of width pixels starting at fx, fy
+// in the background, split up as the work setting says
+func calculateRow(fx, fy, dx float64, width, maxDepth int, iters []iteration, wg *sync.WaitGroup) {
+	splitRow(width, func(start, end int) {
+		x, w := fx+dx*float64(start), end-start
+		f := newFarmJob(farmTile{X0: x, Y0: fy, Dx: dx, Width: w, MaxDepth: maxDepth}, func(its []farmIteration) {
+			for k := range w {
+				iters[start+k] = fromFarm(its[k])
+			}
+		})
+		goFarm(wg, func(int) {
+			calculateMandlebrotRectangle(x, fy, dx, w, maxDepth, iters[start:end])
+		}, f)
+	})
+}
+
+// forEachRow calls fn on each of height rows in parallel, returning
+// false if interrupted
+func forEachRow(height int, interrupted func() bool, fn func(y int)) bool {
+	var wg sync.WaitGroup
+	for y := 0; y < height; y++ {
+		if interrupted() {
+			wg.Wait()
+			return false
+		}
+		goWork(&wg, func() {
+			fn(y)
+		})
+	}
+	wg.Wait()
+	return !interrupted()
+}
+
+// renderPlot computes a plot of the grid given, returning nil if
+// interrupted
+func renderPlot(x0, y0, dx, dy float64, width, height, plotDepth int, interrupted func() bool) *plot {
+	p := &plot{
+		data:       make([]byte, 3*width*height),
+		iters:      make([]iteration, width*height),
+		width:      width,
+		height:     height,
+		x0:         x0,
+		y0:         y0,
+		dx:         dx,
+		dy:         dy,
+		baseDepth:  depth,
+		depth:      plotDepth,
+		decompose:  decompose,
+		coloring:   coloringID,
+		derivative: needsDerivative(),
+		sum:        neededSum(),
+		interior:   interior,
+		params:     params,
+		origin:     origin,
+		aliased:    true,
+
+		paletteOffset: paletteOffset,
+	}
+	if gpuFrames() {
+		for y := 0; y < height; y += gpuBandHeight {
+			if interrupted() {
+				return nil
+			}
+			h := min(gpuBandHeight, height-y)
+			iterateGridGPU(x0, y0, dx, dy, width, y, h, plotDepth, p.iters[y*width:(y+h)*width], nil)
+		}
+	} else {
+		var wg sync.WaitGroup
+		for y := 0; y < height; y += bandHeight {
+			if interrupted() {
+				wg.Wait()
+				return nil
+			}
+			h := min(bandHeight, height-y)
+			calculateBand(x0, y0, dx, dy, width, y, h, plotDepth, p.iters[y*width:(y+h)*width], nil, &wg)
+		}
+		wg.Wait()
+	}
+	if usesColoring(histogramColoring) {
+		equalize(p.iters, plotDepth)
+	}
+	colorPixels(p.data, p.iters, nil, width, height, plotDepth, dx)
+	return p
+}
+
+// colorPixels colors the pixels of a width x height image in data
+// from their iteration results, iterated to plotDepth with pixels of
+// size pixel, skipping any
+// set in covered if it isn't nil
+//
+// Coloring is a separate pass over the iteration results, done in
+// parallel by row, so the plot can be colored again without
+// iterating it again when the coloring changes.
+func colorPixels(data []byte, iters []iteration, covered []bool, width, height, plotDepth int, pixel float64) {
+	forEachRow(height, func() bool { return false }, func(y int) {
+		for x := 0; x < width; x++ {
+			p := y*width + x
+			if covered != nil && covered[p] {
+				continue
+			}
+			col := plotColor(iters[p], plotDepth, pixel)
+			data[3*p+0], data[3*p+1], data[3*p+2] = col.R, col.G, col.B
+		}
+	})
+}
+
+// pixelColor returns the color of pixel p of the plot worked out again
+// from its iteration results, averaging its samples if it has been
+// supersampled
+func (pl *plot) pixelColor(p int) color.RGBA {
+	if pl.supersamples == nil || pl.supersamples[p] == nil {
+		return plotColor(pl.iters[p], pl.depth, pl.dx)
+	}
+	var r, g, b, w float32
+	for _, s := range pl.supersamples[p] {
+		col := plotColor(s.it, pl.depth, pl.dx)
+		r += s.weight * float32(col.R)
+		g += s.weight * float32(col.G)
+		b += s.weight * float32(col.B)
+		w += s.weight
+	}
+	return color.RGBA{uint8(r/w + 0.5), uint8(g/w + 0.5), uint8(b/w + 0.5), 255}
+}
+
+// colored returns true if the pixels of p are colored the way the
+// current settings color them
+func (p *plot) colored() bool {
+	return p.decompose == decompose && p.coloring == coloringID && p.paletteOffset == paletteOffset
+}
+
+// recolorable returns true if the iterations of p track everything
+// the current coloring needs, so p can be colored again from them
+func (p *plot) recolorable() bool {
+	return !(needsDerivative() && !p.derivative || neededSum() != noSum && p.sum != neededSum() ||
+		needsInterior() && p.interior != interior)
+}
+
+// recolor colors the last plot again from its iteration results when
+// the palette, its offset or the decompose setting has changed
+//
+// If only the offset has changed, as it does all the time while the
+// palette is cycling, the samples kept from the antialiasing are
+// colored again too so it isn't lost. Otherwise the plot is marked as
+// needing antialiasing again, as which pixels need it depends on the
+// colors.
+func recolor() {
+	if lastPlot.data == nil || lastPlot.baseDepth != depth || lastPlot.params != params || lastPlot.colored() {
+		return
+	}
+	if !lastPlot.recolorable() {
+		// Needs iterating again to track the orbit
+		lastPlot.data = nil
+		return
+	}
+	if usesColoring(histogramColoring) {
+		equalize(lastPlot.iters, lastPlot.depth)
+	}
+	if lastPlot.decompose != decompose || lastPlot.coloring != coloringID || lastPlot.lostSamples {
+		lastPlot.supersamples, lastPlot.kept, lastPlot.lostSamples = nil, 0, false
+		lastPlot.aliased = true
+		lastPlot.samples = 0
+	}
+	width := lastPlot.width
+	data := make([]byte, len(lastPlot.data))
+	forEachRow(lastPlot.height, func() bool { return false }, func(y int) {
+		for p := y * width; p < (y+1)*width; p++ {
+			col := lastPlot.pixelColor(p)
+			data[3*p+0], data[3*p+1], data[3*p+2] = col.R, col.G, col.B
+		}
+	})
+	lastPlot.data = data
+	lastPlot.decompose = decompose
+	lastPlot.coloring = coloringID
+	lastPlot.paletteOffset = paletteOffset
+}
@@ -0,0 +1,91 @@
+package main
+
+import "math"
+
+// Only keep this many prefetched plots
+const maxPrefetched = 6
+
+// Prefetched plots of the regions around the view, newest last
+var prefetched []*plot
+
+// prefetchTarget is a region of the set which might be needed next
+type prefetchTarget struct {
+	x0, y0, dx, dy float64
+	width, height  int
+	depth          int
+}
+
+// prefetchTargets returns the regions needed for the next pan in each
+// direction and the next zoom in and out.
+//
+// The pan strips are done at the depth of the last plot so they can
+// be shifted in alongside it.
+func prefetchTargets() []prefetchTarget {
+	width, height, _, _, _, _ := getImageDimensions()
+	x0, y0, dx, dy := viewGrid(center, radius, width, height)
+	px := int(math.Round(radius * pan / dx))
+	py := int(math.Round(radius * pan / dy))
+	d := lastPlot.depth
+	targets := []prefetchTarget{
+		{x0 + dx*float64(width), y0, dx, dy, px, height, d}, // right
+		{x0 - dx*float64(px), y0, dx, dy, px, height, d},    // left
+		{x0, y0 + dy*float64(height), dx, dy, width, py, d}, // down
+		{x0, y0 - dy*float64(py), dx, dy, width, py, d},     // up
+	}
+	for _, r := range []float64{radius / zoom, radius * zoom} {
+		zx0, zy0, zdx, zdy := viewGrid(center, r, width, height)
+		targets = append(targets, prefetchTarget{zx0, zy0, zdx, zdy, width, height, depth})
+	}
+	return targets
+}
+
+// isPrefetched returns true if the target is already in the cache
+func (t *prefetchTarget) isPrefetched() bool {
+	for _, p := range prefetched {
+		if p.x0 == t.x0 && p.y0 == t.y0 && p.dx == t.dx && p.dy == t.dy &&
+			p.width == t.width && p.height == t.height && p.depth == t.depth &&
+			p.baseDepth == depth && p.params == params && p.origin == origin && p.recolorable() {
+			return true
+		}
+	}
+	return false
+}
+
+// nextPrefetch returns the next region to prefetch or nil if all done
+func nextPrefetch() *prefetchTarget {
+	for _, t := range prefetchTargets() {
+		if !t.isPrefetched() {
+			return &t
+		}
+	}
+	return nil
+}
+
+// prefetchPending returns true if there is an idle view with regions
+// around it still to prefetch
+//
+// Prefetching stops if the plots wouldn't fit in the memory budget.
+func prefetchPending() bool {
+	if densityMode() || animating() || !lastPlotCurrent() {
+		return false
+	}
+	t := nextPrefetch()
+	return t != nil && lastPlot.size()+prefetchedSize()+plotSize(t.width, t.height) <= maxMemory
+}
+
+// prefetchStep renders the next region around the view into the cache
+func prefetchStep(interrupted func() bool) {
+	t := nextPrefetch()
+	if t == nil {
+		return
+	}
+	p := renderPlot(t.x0, t.y0, t.dx, t.dy, t.width, t.height, t.depth, interrupted)
+	if p == nil {
+		return
+	}
+	prefetched = append(prefetched, p)
+	if len(prefetched) > maxPrefetched {
+		prefetched = prefetched[len(prefetched)-maxPrefetched:]
+	}
+	enforceMemoryBudget()
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+// prompt is a line of text being typed in by the user
+type prompt struct {
+	label    string
+	text     []rune
+	done     func(text string) error  // called with the text when enter is pressed
+	complete func(text string) string // completes the text when tab is pressed, if set
+}
+
+// Globals
+var (
+	activePrompt *prompt // the prompt being typed into, if any
+	message      string  // message to show in the overlay until the next key
+)
+
+// startPrompt asks the user for a line of text, calling done with it
+// when they press enter. If done returns an error it is shown as the
+// message.
+func startPrompt(label string, done func(text string) error) *prompt {
+	activePrompt = &prompt{label: label, done: done}
+	return activePrompt
+}
+
+// promptKey handles a key press while a prompt is active
+func promptKey(ev termbox.Event) {
+	p := activePrompt
+	switch ev.Key {
+	case termbox.KeyEsc, termbox.KeyCtrlC:
+		activePrompt = nil
+	case termbox.KeyEnter:
+		activePrompt = nil
+		if err := p.done(strings.TrimSpace(string(p.text))); err != nil {
+			message = err.Error()
+		}
+	case termbox.KeyBackspace, termbox.KeyBackspace2:
+		if len(p.text) > 0 {
+			p.text = p.text[:len(p.text)-1]
+		}
+	case termbox.KeyCtrlU:
+		p.text = p.text[:0]
+	case termbox.KeyTab:
+		if p.complete != nil {
+			p.text = []rune(p.complete(string(p.text)))
+		}
+	case termbox.KeySpace:
+		p.text = append(p.text, ' ')
+	default:
+		if ev.Ch != 0 {
+			p.text = append(p.text, ev.Ch)
+		}
+	}
+}
+
+// promptText returns the line to show for the prompt or message, or
+// "" if there is neither
+func promptText() string {
+	if activePrompt != nil {
+		return fmt.Sprintf("%s %s_", activePrompt.label, string(activePrompt.text))
+	}
+	return message
+}
+
+// zoomToRadius asks for a radius and zooms the view to it
+func zoomToRadius() {
+	startPrompt(tr("Zoom to radius:"), func(text string) error {
+		r, err := strconv.ParseFloat(text, 64)
+		if err != nil || !(r > 0) || math.IsInf(r, 1) {
+			return fmt.Errorf(tr("bad radius %q"), text)
+		}
+		radius = r
+		return nil
+	})
+}
+
+// completeFileName completes text as far as all the files starting
+// with it agree, adding a / if it is a directory
+func completeFileName(text string) string {
+	matches, _ := filepath.Glob(text + "*")
+	if len(matches) == 0 {
+		return text
+	}
+	prefix := matches[0]
+	for _, m := range matches[1:] {
+		for !strings.HasPrefix(m, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if len(matches) == 1 {
+		if info, err := os.Stat(prefix); err == nil && info.IsDir() {
+			prefix += string(filepath.Separator)
+		}
+	}
+	return prefix
+}
@@ -0,0 +1,107 @@
+package main
+
+import "math"
+
+// pyramidLevel is the last plot downsampled by a power of two
+type pyramidLevel struct {
+	data   []byte // RGB pixels
+	width  int
+	height int
+}
+
+// pyramid is a mipmap of the last full resolution plot
+//
+// Reduced resolution frames, as used while animating, can be served
+// from it wherever it has pixels at least as fine as the ones needed,
+// so zooming out and the start of a fast zoom in don't need to
+// recompute pixels which have already been plotted.
+type pyramid struct {
+	x0, y0 float64
+	dx, dy float64
+	origin *deepPoint     // the point the set co-ordinates are relative to
+	levels []pyramidLevel // level k has pixels 2^k times the size of level 0
+}
+
+// The pyramid of the last plot
+var lastPyramid pyramid
+
+// downsample halves the size of the level averaging 2x2 blocks of pixels
+func (l *pyramidLevel) downsample() pyramidLevel {
+	w, h := l.width/2, l.height/2
+	out := pyramidLevel{data: make([]byte, 3*w*h), width: w, height: h}
+	rowSize := 3 * l.width
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			p := 2*y*rowSize + 6*x
+			for k := 0; k < 3; k++ {
+				sum := int(l.data[p+k]) + int(l.data[p+3+k]) + int(l.data[p+rowSize+k]) + int(l.data[p+rowSize+3+k])
+				out.data[3*(y*w+x)+k] = uint8(sum / 4)
+			}
+		}
+	}
+	return out
+}
+
+// updatePyramid rebuilds the pyramid if the last plot has changed
+func updatePyramid() {
+	if len(lastPlot.data) == 0 || len(lastPyramid.levels) > 0 && &lastPyramid.levels[0].data[0] == &lastPlot.data[0] {
+		return
+	}
+	source := lastPlot
+	lastPyramid = pyramid{
+		x0:     source.x0,
+		y0:     source.y0,
+		dx:     source.dx,
+		dy:     source.dy,
+		origin: source.origin,
+		levels: []pyramidLevel{{data: source.data, width: source.width, height: source.height}},
+	}
+	for {
+		top := &lastPyramid.levels[len(lastPyramid.levels)-1]
+		if top.width < 2 || top.height < 2 {
+			break
+		}
+		lastPyramid.levels = append(lastPyramid.levels, top.downsample())
+	}
+}
+
+// pyramidSize returns the memory used by the pyramid levels not
+// shared with the last plot
+func pyramidSize() (n int64) {
+	for _, l := range lastPyramid.levels[min(1, len(lastPyramid.levels)):] {
+		n += int64(len(l.data))
+	}
+	return n
+}
+
+// sample returns the pixel of the pyramid at x, y for a pixel of size
+// dx, dy in set co-ordinates, and false if the pyramid doesn't have
+// it at that resolution.
+func (pm *pyramid) sample(x, y, dx, dy float64) ([]byte, bool) {
+	if len(pm.levels) == 0 || dx < pm.dx || dy < pm.dy || pm.origin != origin {
+		return nil, false
+	}
+	k := min(int(math.Log2(min(dx/pm.dx, dy/pm.dy))), len(pm.levels)-1)
+	l := &pm.levels[k]
+	scale := float64(int(1) << k)
+	// Pixels are sampled at their top left corners so pixel i of
+	// level 0 covers i-0.5 to i+0.5
+	px := int(math.Floor(((x-pm.x0)/pm.dx + 0.5) / scale))
+	py := int(math.Floor(((y-pm.y0)/pm.dy + 0.5) / scale))
+	if px < 0 || px >= l.width || py < 0 || py >= l.height {
+		return nil, false
+	}
+	p := 3 * (py*l.width + px)
+	return l.data[p : p+3], true
+}
+
+// fillRowFromPyramid fills in the pixels of a reduced resolution row
+// which the pyramid has, marking them in covered
+func fillRowFromPyramid(x0, fy, dx, dy float64, width int, line []byte, covered []bool) {
+	for x := 0; x < width; x++ {
+		if pix, ok := lastPyramid.sample(x0+dx*float64(x), fy, dx, dy); ok {
+			copy(line[3*x:3*x+3], pix)
+			covered[x] = true
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"slices"
+	"time"
+)
+
+// Constants
+const (
+	// Wait for this long without input before refining the plot
+	idleDelay = 300 * time.Millisecond
+
+	// Refine the depth to at most this multiple of the user's depth
+	maxRefine = 16
+
+	// Pixels which differ from a neighbour by more than this sum of
+	// RGB differences get antialiased
+	aaThreshold = 48
+)
+
+// plotColor returns the color for an iteration result of a plot
+// iterated to plotDepth with pixels of size pixel.
+//
+// Colors are always scaled to the depth the user asked for so
+// refining the depth only changes the pixels which escape.
+func plotColor(it iteration, plotDepth int, pixel float64) color.RGBA {
+	if f, ok := params.fractal.(colorFractal); ok {
+		return adjustColor(f.Color(it))
+	}
+	if brailleDots() {
+		return brailleColor(it, plotDepth, pixel)
+	}
+	if layers != nil {
+		return adjustColor(layeredColor(it, plotDepth, pixel))
+	}
+	if it.i >= plotDepth {
+		return adjustColor(interiorColor(it, pixel))
+	}
+	return adjustColor(lightColor(escapedColor(coloring, it, pixel), it.z, it.dz))
+}
+
+// escapedColor returns the color of an iteration result which escaped
+// with the coloring mode given
+func escapedColor(mode coloringMode, it iteration, pixel float64) color.RGBA {
+	switch mode {
+	case angleColoring, angleIterColoring:
+		return angleColor(min(it.i, depth-1), it.z, depth, mode == angleIterColoring)
+	case histogramColoring:
+		return histogramColor(min(it.i, depth-1), it.z, depth)
+	case cyclicColoring:
+		return cyclicColor(min(it.i, depth-1), it.z)
+	case distanceColoring:
+		return distanceColor(min(it.i, depth-1), it.z, it.dz, depth, pixel)
+	case stripeColoring:
+		return stripeColor(it.i, it.z, it.sum, depth)
+	case expColoring:
+		return expColor(min(it.i, depth-1), it.z, it.sum, depth)
+	}
+	return smoothColor(min(it.i, depth-1), it.z, depth)
+}
+
+// lastPlotCurrent returns true if lastPlot is of the current view
+func lastPlotCurrent() bool {
+	width, height, _, _, _, _ := getImageDimensions()
+	x0, y0, dx, dy := viewGrid(center, radius, width, height)
+	ox, oy, ok := lastPlot.offset(x0, y0, dx, dy, lastPlot.depth)
+	return ok && ox == 0 && oy == 0 && lastPlot.width == width && lastPlot.height == height
+}
+
+// refinePending returns true if the plot on screen could be improved
+func refinePending() bool {
+	return !densityMode() && !animating() && (!lastPlot.refined || lastPlot.aliased || lastPlot.samples < maxSamples) && lastPlotCurrent()
+}
+
+// refineStep does one pass of refinement on the last plot,
+// returning true if it changed and needs displaying again.
+//
+// The depth is doubled for the pixels which reached it until no more
+// pixels escape, then the pixels on edges are antialiased, then
+// jittered passes are accumulated into the whole plot.
+//
+// If interrupted returns true then the pass is abandoned.
+func refineStep(interrupted func() bool) bool {
+	if !lastPlot.refined {
+		return refineDepth(interrupted)
+	}
+	if lastPlot.aliased {
+		return antialias(interrupted)
+	}
+	return accumulate(interrupted)
+}
+
+// refineDepth carries on iterating the pixels which reached the depth
+// of the last plot to twice that depth
+func refineDepth(interrupted func() bool) bool {
+	oldDepth := lastPlot.depth
+	newDepth := 2 * oldDepth
+	if newDepth > lastPlot.baseDepth*maxRefine {
+		lastPlot.refined = true
+		return false
+	}
+	width := lastPlot.width
+	x0, y0, dx, dy := lastPlot.x0, lastPlot.y0, lastPlot.dx, lastPlot.dy
+	iters := slices.Clone(lastPlot.iters)
+	data := slices.Clone(lastPlot.data)
+	escaped := make([]int, lastPlot.height)
+	ok := forEachRow(lastPlot.height, interrupted, func(y int) {
+		fy := y0 + dy*float64(y)
+		for x := 0; x < width; x++ {
+			p := y*width + x
+			it := &iters[p]
+			if it.i < oldDepth {
+				continue
+			}
+			*it = iterateFrom(*it, complex(x0+dx*float64(x), fy), newDepth)
+			if it.i < newDepth {
+				escaped[y]++
+				col := plotColor(*it, newDepth, dx)
+				data[3*p+0], data[3*p+1], data[3*p+2] = col.R, col.G, col.B
+			}
+		}
+	})
+	if !ok {
+		return false
+	}
+	total := 0
+	for _, n := range escaped {
+		total += n
+	}
+	if total == 0 {
+		// Nothing more to see at a higher depth
+		lastPlot.refined = true
+		return false
+	}
+	lastPlot.iters, lastPlot.data, lastPlot.depth = iters, data, newDepth
+	lastPlot.aliased = true
+	lastPlot.samples = 0
+	lastPlot.supersamples, lastPlot.kept, lastPlot.lostSamples = nil, 0, false
+	return true
+}
+
+// colorDiff returns the sum of the differences of the RGB components
+func colorDiff(a, b []byte) int {
+	d := 0
+	for k := 0; k < 3; k++ {
+		d += max(int(a[k])-int(b[k]), int(b[k])-int(a[k]))
+	}
+	return d
+}
+
+// antialias supersamples the pixels of the last plot which differ
+// significantly from their neighbours
+func antialias(interrupted func() bool) bool {
+	width, height := lastPlot.width, lastPlot.height
+	x0, y0, dx, dy := lastPlot.x0, lastPlot.y0, lastPlot.dx, lastPlot.dy
+	src := lastPlot.data
+	data := slices.Clone(src)
+	rowSize := 3 * width
+	offsets := [4][2]float64{{-0.25, -0.25}, {0.25, -0.25}, {-0.25, 0.25}, {0.25, 0.25}}
+	supersamples, kept := keptSamples()
+	ok := forEachRow(lastPlot.height, interrupted, func(y int) {
+		if y == 0 || y == height-1 {
+			return
+		}
+		var xs []int
+		for x := 1; x < width-1; x++ {
+			p := y*rowSize + 3*x
+			pix := src[p : p+3]
+			if colorDiff(pix, src[p-3:p]) <= aaThreshold && colorDiff(pix, src[p+3:p+6]) <= aaThreshold &&
+				colorDiff(pix, src[p-rowSize:p-rowSize+3]) <= aaThreshold && colorDiff(pix, src[p+rowSize:p+rowSize+3]) <= aaThreshold {
+				continue
+			}
+			xs = append(xs, x)
+		}
+		// Iterate the samples of the row together
+		n := len(offsets)
+		ps := make([]complex128, 0, n*len(xs))
+		for _, x := range sortLongestFirst(xs, y) {
+			for _, o := range offsets {
+				ps = append(ps, complex(x0+dx*(float64(x)+o[0]), y0+dy*(float64(y)+o[1])))
+			}
+		}
+		its := make([]iteration, len(ps))
+		iteratePoints(ps, lastPlot.depth, its)
+		for k, x := range xs {
+			var r, g, b int
+			for _, it := range its[k*n : (k+1)*n] {
+				col := plotColor(it, lastPlot.depth, dx)
+				r, g, b = r+int(col.R), g+int(col.G), b+int(col.B)
+			}
+			p := y*rowSize + 3*x
+			data[p+0], data[p+1], data[p+2] = uint8(r/n), uint8(g/n), uint8(b/n)
+			if supersamples != nil {
+				ss := make([]sample, n)
+				for j, it := range its[k*n : (k+1)*n] {
+					ss[j] = sample{it: it, weight: 1}
+				}
+				q := y*width + x
+				kept[y] += n - len(supersamples[q])
+				supersamples[q] = ss
+			}
+		}
+	})
+	if !ok {
+		return false
+	}
+	lastPlot.data = data
+	lastPlot.aliased = false
+	setKeptSamples(supersamples, kept)
+	return true
+}
+
+// idlePending returns true if there is work to do while waiting for input
+func idlePending() bool {
+	return refinePending() || tunePending() || prefetchPending()
+}
+
+// idleStep does the next piece of idle work, returning true if the
+// last plot changed and needs displaying again
+func idleStep(interrupted func() bool) bool {
+	if refinePending() {
+		return refineStep(interrupted)
+	}
+	if tunePending() {
+		tuneStep(interrupted)
+		return false
+	}
+	prefetchStep(interrupted)
+	return false
+}
+
+// showLastPlot sends the last plot to the terminal again with the overlay
+func showLastPlot() {
+	_, _, _, cols, _, cellHeight := getImageDimensions()
+	fmt.Fprintf(screen, "\033[H")
+	writeRGBFrame(lastPlot.data, lastPlot.width, lastPlot.height, cols, cellHeight)
+	drawOverlay()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Flags
+var (
+	idleFlag = flag.Duration("idle", 0, "With the screensaver command, time without input before exploring starts, eg 5m")
+)
+
+// Globals
+var (
+	screensaver = false // set when run as "termbrot screensaver"
+	benchmark   = false // set when run as "termbrot benchmark"
+	lastInput   time.Time
+)
+
+// checkCommand checks the command given after the flags, if any
+func checkCommand() error {
+	switch flag.Arg(0) {
+	case "":
+		return nil
+	case "screensaver":
+		screensaver = true
+		// Allow the flags after the command too
+		_ = flag.CommandLine.Parse(flag.Args()[1:])
+		if flag.NArg() > 0 {
+			return fmt.Errorf("unexpected %q after screensaver", flag.Arg(0))
+		}
+		if *idleFlag < 0 {
+			return fmt.Errorf("--idle must not be negative")
+		}
+		return nil
+	case "benchmark":
+		benchmark = true
+		if flag.NArg() > 1 {
+			return fmt.Errorf("unexpected %q after benchmark", flag.Arg(1))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown command %q - the commands are screensaver and benchmark", flag.Arg(0))
+}
+
+// startScreensaver sets up the screensaver if it was asked for
+func startScreensaver() {
+	if !screensaver {
+		return
+	}
+	showHelp = false
+	lastInput = time.Now()
+	screensaverCheck()
+}
+
+// screensaverWaiting returns how long until the screensaver starts
+// exploring and whether it is waiting to
+func screensaverWaiting() (time.Duration, bool) {
+	if !screensaver || exploring {
+		return 0, false
+	}
+	return time.Until(lastInput.Add(*idleFlag)), true
+}
+
+// screensaverCheck starts exploring if there has been no input for
+// the idle time
+func screensaverCheck() {
+	if wait, ok := screensaverWaiting(); ok && wait <= 0 {
+		toggleExplore()
+	}
+}
+
+// screensaverInput notes the input event ev, returning true if it
+// should end the screensaver
+func screensaverInput(ev termbox.Event) bool {
+	if ev.Type != termbox.EventKey && ev.Type != termbox.EventMouse {
+		return false
+	}
+	lastInput = time.Now()
+	return screensaver && exploring
+}
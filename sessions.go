@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+)
+
+// Number of recent sessions to remember
+const maxSessions = 5
+
+// Flags
+var (
+	menuFlag = flag.Bool("menu", false, "Show a menu of recent sessions and bookmarks to start from")
+)
+
+// sessionsPath returns the path of the file of recent sessions
+func sessionsPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "sessions.json"), nil
+}
+
+// loadSessions reads the views termbrot was last quit at, oldest first
+func loadSessions() ([]bookmark, error) {
+	path, err := sessionsPath()
+	if err != nil {
+		return nil, err
+	}
+	return readBookmarks(path)
+}
+
+// saveSession remembers the current view as the latest session,
+// forgetting the oldest ones if there are too many
+//
+// The flame has no view to go back to so isn't saved, and nor is
+// wherever the screensaver wandered off to.
+func saveSession() error {
+	if densityMode() || screensaver {
+		return nil
+	}
+	path, err := sessionsPath()
+	if err != nil {
+		return err
+	}
+	sessions, err := readBookmarks(path)
+	if err != nil {
+		return err
+	}
+	b := newBookmark(currentView())
+	if err := saveThumbnail(&b); err != nil {
+		return err
+	}
+	sessions = append(sessions, b)
+	for len(sessions) > maxSessions {
+		removeThumbnail(&sessions[0])
+		sessions = sessions[1:]
+	}
+	return writeBookmarks(path, sessions)
+}
+
+// startupMenu shows the menu of recent sessions and bookmarks if it
+// was asked for and there are any
+func startupMenu() {
+	if !*menuFlag && !cfg.StartupMenu {
+		return
+	}
+	openMenu(true)
+	message = ""
+}
@@ -0,0 +1,68 @@
+package main
+
+// simdUsable returns true if the SIMD kernel can be used, as the CPU
+// has the instructions for it and the coloring only needs the
+// iteration counts and final z of the points
+func simdUsable() bool {
+	return hasSIMD && !needsDerivative() && neededSum() == noSum
+}
+
+// iteratePoints iterates the points ps from the start like iterate,
+// setting their results in iters and returning the iterations done
+//
+// With the SIMD kernel they are all iterated together by mandelbrots,
+// which gives the same results as mandelbrot. So are they with the
+// GPU kernel, as the GPU is only given whole frames by iterateGridGPU.
+func iteratePoints(ps []complex128, maxDepth int, iters []iteration) int {
+	kern := currentKernel()
+	if kern == kernelSIMD && simdUsable() || kern == kernelGPU && gpuUsable() {
+		return iterateBatch(ps, maxDepth, iters, mandelbrots)
+	}
+	done := 0
+	for k, p := range ps {
+		iters[k] = iterate(p, maxDepth)
+		done += iters[k].i
+	}
+	return done
+}
+
+// iterateBatch iterates the points ps from the start like iterate with
+// batch, which is mandelbrots or mandelbrotsGPU, setting their results
+// in iters and returning the iterations done
+func iterateBatch(ps []complex128, maxDepth int, iters []iteration, batch func(z, c []complex128, maxDepth int, is []int)) int {
+	done := 0
+	var (
+		z, c []complex128
+		idx  []int
+	)
+	for k, p := range ps {
+		if origin != nil {
+			p = absPoint(p)
+		}
+		if knownInside(p, params.julia) {
+			iters[k] = iteration{i: maxDepth}
+			done += maxDepth
+			continue
+		}
+		if params.julia {
+			z, c = append(z, p), append(c, params.c)
+		} else {
+			z, c = append(z, fractalStart(params.fractal)), append(c, p)
+		}
+		idx = append(idx, k)
+	}
+	is := make([]int, len(z))
+	batch(z, c, maxDepth, is)
+	for j, k := range idx {
+		it := iteration{i: is[j], z: z[j]}
+		if params.julia {
+			it.dz = 1
+		}
+		if it.i >= maxDepth && needsInterior() {
+			it.sum = interiorValue(it.z, c[j])
+		}
+		iters[k] = it
+		done += it.i
+	}
+	return done
+}
@@ -0,0 +1,31 @@
+package main
+
+import "golang.org/x/sys/cpu"
+
+// Set if the CPU can run the SIMD kernel
+var hasSIMD = cpu.X86.HasAVX2
+
+// Name of the instructions the SIMD kernel uses
+const simdName = "AVX2"
+
+// mandelbrotAVX2 is mandelbrot for the n points z with c from
+// iteration 0, with AVX2 iterating 4 of them at once
+//
+// It leaves the final z in z and the iterations in counts, as
+// mandelbrot with the periodicity check of eps would. maxDepth must be
+// at least 1.
+//
+//go:noescape
+func mandelbrotAVX2(z, c *complex128, n, maxDepth int, eps float64, counts *int)
+
+// mandelbrots iterates the points z with c from iteration 0 like
+// mandelbrot does, leaving their final z in z and their iterations in is
+func mandelbrots(z, c []complex128, maxDepth int, is []int) {
+	if len(z) == 0 || maxDepth < 1 {
+		for k := range z {
+			is[k], z[k] = mandelbrot(z[k], c[k], 0, maxDepth)
+		}
+		return
+	}
+	mandelbrotAVX2(&z[0], &c[0], len(z), maxDepth, cycleEpsilon(), &is[0])
+}
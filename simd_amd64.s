@@ -0,0 +1,305 @@
+#include "textflag.h"
+
+DATA four<>+0(SB)/8, $4.0
+DATA four<>+8(SB)/8, $4.0
+DATA four<>+16(SB)/8, $4.0
+DATA four<>+24(SB)/8, $4.0
+GLOBL four<>(SB), RODATA|NOPTR, $32
+
+DATA one<>+0(SB)/8, $1.0
+DATA one<>+8(SB)/8, $1.0
+DATA one<>+16(SB)/8, $1.0
+DATA one<>+24(SB)/8, $1.0
+GLOBL one<>(SB), RODATA|NOPTR, $32
+
+DATA epsilon<>+0(SB)/8, $1e-12
+DATA epsilon<>+8(SB)/8, $1e-12
+DATA epsilon<>+16(SB)/8, $1e-12
+DATA epsilon<>+24(SB)/8, $1e-12
+GLOBL epsilon<>(SB), RODATA|NOPTR, $32
+
+DATA ones<>+0(SB)/8, $1
+DATA ones<>+8(SB)/8, $1
+DATA ones<>+16(SB)/8, $1
+DATA ones<>+24(SB)/8, $1
+GLOBL ones<>(SB), RODATA|NOPTR, $32
+
+// periodFirstCheck+1
+DATA firstSave<>+0(SB)/8, $17
+DATA firstSave<>+8(SB)/8, $17
+DATA firstSave<>+16(SB)/8, $17
+DATA firstSave<>+24(SB)/8, $17
+GLOBL firstSave<>(SB), RODATA|NOPTR, $32
+
+// Masks picking out each lane
+DATA lanes<>+0(SB)/8, $-1
+DATA lanes<>+8(SB)/8, $0
+DATA lanes<>+16(SB)/8, $0
+DATA lanes<>+24(SB)/8, $0
+DATA lanes<>+32(SB)/8, $0
+DATA lanes<>+40(SB)/8, $-1
+DATA lanes<>+48(SB)/8, $0
+DATA lanes<>+56(SB)/8, $0
+DATA lanes<>+64(SB)/8, $0
+DATA lanes<>+72(SB)/8, $0
+DATA lanes<>+80(SB)/8, $-1
+DATA lanes<>+88(SB)/8, $0
+DATA lanes<>+96(SB)/8, $0
+DATA lanes<>+104(SB)/8, $0
+DATA lanes<>+112(SB)/8, $0
+DATA lanes<>+120(SB)/8, $-1
+GLOBL lanes<>(SB), RODATA|NOPTR, $128
+
+// Where things are kept on the stack, 4 of each
+#define S_X 0
+#define S_Y 32
+#define S_N 64
+#define S_IDX 96
+#define S_EPS 128
+#define S_MAXD1 160
+
+// func mandelbrotAVX2(z, c *complex128, n, maxDepth int, eps float64, counts *int)
+//
+// Each lane iterates a point until it stops, when its result is
+// stored and the lane is loaded with the next point, so the lanes are
+// kept busy however different the lengths of the orbits are.
+//
+// Each time round the loop the lanes are tested for stopping at z_i -
+// the periodicity check of z_i, reaching maxDepth, the escape test
+// and the test of the derivative made from z_i, which stop mandelbrot
+// in that order - and only if one of them stops does it go to the
+// slow path, which works out which test stopped it. The lanes
+// iterating only change then, so the steps don't wait for the tests.
+//
+// Registers
+//	Y0, Y1	x, y
+//	Y2, Y3	cx, cy
+//	Y4, Y5	the derivative
+//	Y6	i of each lane
+//	Y7	lanes iterating, all ones if so
+//	Y8, Y9	z saved for the periodicity check
+//	Y10	the i z is saved at next
+//	Y11	x²-y²+cx, the next x
+//	Y12	lanes stopped by the periodicity check
+//	Y13	lanes stopping
+//	Y14, Y15	temporaries
+//	AX, BX	z, c
+//	CX	n
+//	DX	the next point to load
+//	SI	maxDepth
+//	DI	counts
+//	R8	the lane being worked on by the slow path
+//	R11	lanes stopping which escaped
+//	R13	lanes stopping left to work on
+TEXT ·mandelbrotAVX2(SB), NOSPLIT, $192-48
+	MOVQ z+0(FP), AX
+	MOVQ c+8(FP), BX
+	MOVQ n+16(FP), CX
+	MOVQ maxDepth+24(FP), SI
+	MOVQ counts+40(FP), DI
+	VBROADCASTSD eps+32(FP), Y0
+	VMOVUPD Y0, S_EPS(SP)
+	LEAQ -1(SI), R9
+	VMOVQ R9, X0
+	VPBROADCASTQ X0, Y0
+	VMOVDQU Y0, S_MAXD1(SP)
+
+	// Start with all the lanes empty, and load them
+	VPCMPEQQ Y0, Y0, Y0
+	VMOVDQU Y0, S_IDX(SP)
+	VXORPD Y0, Y0, Y0
+	VXORPD Y1, Y1, Y1
+	VXORPD Y2, Y2, Y2
+	VXORPD Y3, Y3, Y3
+	VXORPD Y4, Y4, Y4
+	VXORPD Y5, Y5, Y5
+	VPXOR Y6, Y6, Y6
+	VPXOR Y7, Y7, Y7
+	VXORPD Y8, Y8, Y8
+	VXORPD Y9, Y9, Y9
+	VPXOR Y10, Y10, Y10
+	XORQ DX, DX
+	MOVQ $15, R13
+	JMP lane
+
+loop:
+	// Escape test
+	VMULPD Y0, Y0, Y14
+	VMULPD Y1, Y1, Y15
+	VADDPD Y15, Y14, Y13
+	VCMPPD $0x1d, four<>(SB), Y13, Y13 // x²+y² >= 4
+	VSUBPD Y15, Y14, Y11
+	VADDPD Y2, Y11, Y11
+
+	// dx, dy = 2*(x*dx-y*dy), 2*(x*dy+y*dx) for the lanes iterating,
+	// so the derivative of empty lanes doesn't go on shrinking into
+	// denormals, which are slow
+	VMULPD Y4, Y0, Y12
+	VMULPD Y5, Y1, Y14
+	VSUBPD Y14, Y12, Y12
+	VADDPD Y12, Y12, Y12
+	VMULPD Y5, Y0, Y14
+	VMULPD Y4, Y1, Y15
+	VADDPD Y15, Y14, Y14
+	VADDPD Y14, Y14, Y14
+	VBLENDVPD Y7, Y12, Y4, Y4
+	VBLENDVPD Y7, Y14, Y5, Y5
+
+	// Derivative test
+	VMULPD Y4, Y4, Y12
+	VMULPD Y5, Y5, Y14
+	VADDPD Y14, Y12, Y12
+	VCMPPD $0x11, epsilon<>(SB), Y12, Y12 // < interiorEpsilon
+	VORPD Y12, Y13, Y13
+
+	// Reached maxDepth
+	VPCMPGTQ S_MAXD1(SP), Y6, Y12
+	VORPD Y12, Y13, Y13
+
+	// The periodicity check, except at the i z is saved at and at i 1,
+	// as z_1 didn't come from a step with a check
+	VSUBPD Y8, Y0, Y12
+	VSUBPD Y9, Y1, Y14
+	VMULPD Y12, Y12, Y12
+	VMULPD Y14, Y14, Y14
+	VADDPD Y14, Y12, Y12
+	VCMPPD $0x12, S_EPS(SP), Y12, Y12 // <= eps
+	VPCMPEQQ Y10, Y6, Y14
+	VPCMPEQQ ones<>(SB), Y6, Y15
+	VPOR Y15, Y14, Y15
+	VANDNPD Y12, Y15, Y12
+	VORPD Y12, Y13, Y13
+
+	// Save z, doubling the spacing of the saves
+	VBLENDVPD Y14, Y0, Y8, Y8
+	VBLENDVPD Y14, Y1, Y9, Y9
+	VPADDQ Y10, Y10, Y15
+	VPSUBQ ones<>(SB), Y15, Y15
+	VBLENDVPD Y14, Y15, Y10, Y10
+
+	VANDPD Y7, Y13, Y13
+	VMOVMSKPD Y13, R9
+	TESTQ R9, R9
+	JNZ stopping
+
+	// x, y = x²-y²+cx, 2*x*y+cy for the lanes iterating
+	VADDPD Y0, Y0, Y14
+	VMULPD Y1, Y14, Y14
+	VADDPD Y3, Y14, Y14
+	VBLENDVPD Y7, Y11, Y0, Y0
+	VBLENDVPD Y7, Y14, Y1, Y1
+	VPSUBQ Y7, Y6, Y6
+	JMP loop
+
+stopping:
+	// The lanes stopping escaped if the escape test stopped them
+	// without the periodicity check or reaching maxDepth first
+	MOVQ R9, R13
+	VMOVMSKPD Y12, R11
+	VPCMPGTQ S_MAXD1(SP), Y6, Y14
+	VMOVMSKPD Y14, R10
+	ORQ R10, R11
+	NOTQ R11
+	VMULPD Y0, Y0, Y14
+	VMULPD Y1, Y1, Y15
+	VADDPD Y15, Y14, Y14
+	VCMPPD $0x1d, four<>(SB), Y14, Y14 // x²+y² >= 4
+	VMOVMSKPD Y14, R10
+	ANDQ R10, R11
+	VMOVUPD Y0, S_X(SP)
+	VMOVUPD Y1, S_Y(SP)
+	VMOVDQU Y6, S_N(SP)
+
+	// Step the other lanes
+	VANDNPD Y7, Y13, Y15
+	VADDPD Y0, Y0, Y14
+	VMULPD Y1, Y14, Y14
+	VADDPD Y3, Y14, Y14
+	VBLENDVPD Y15, Y11, Y0, Y0
+	VBLENDVPD Y15, Y14, Y1, Y1
+	VPSUBQ Y15, Y6, Y6
+
+lane:
+	// Work on each lane stopping in turn, storing its result
+	BSFQ R13, R8
+	MOVQ S_IDX(SP)(R8*8), R10
+	TESTQ R10, R10
+	JS load
+	MOVQ SI, R9
+	BTQ R8, R11
+	JCC store
+	MOVQ S_N(SP)(R8*8), R9
+
+store:
+	MOVQ R9, (DI)(R10*8)
+	SHLQ $4, R10
+	MOVQ S_X(SP)(R8*8), R9
+	MOVQ R9, (AX)(R10*1)
+	MOVQ S_Y(SP)(R8*8), R9
+	MOVQ R9, 8(AX)(R10*1)
+
+load:
+	// and loading the next point into it, blending its values into
+	// the lane with Y15
+	MOVQ R8, R9
+	SHLQ $5, R9
+	LEAQ lanes<>(SB), R10
+	VMOVDQU (R10)(R9*1), Y15
+
+next:
+	CMPQ DX, CX
+	JGE empty
+	MOVQ DX, R12
+	SHLQ $4, R12
+	VBROADCASTSD (AX)(R12*1), Y12
+	VBROADCASTSD 8(AX)(R12*1), Y13
+	VBLENDVPD Y15, Y12, Y8, Y8
+	VBLENDVPD Y15, Y13, Y9, Y9
+	VMULPD Y12, Y12, Y12
+	VMULPD Y13, Y13, Y13
+	VADDSD X13, X12, X14
+	VUCOMISD four<>(SB), X14
+	JCC escaped
+
+	// The first step has no derivative to start from
+	VSUBPD Y13, Y12, Y12
+	VBROADCASTSD (BX)(R12*1), Y13
+	VBLENDVPD Y15, Y13, Y2, Y2
+	VADDPD Y13, Y12, Y12
+	VBLENDVPD Y15, Y12, Y0, Y0
+	VBROADCASTSD (AX)(R12*1), Y12
+	VADDPD Y12, Y12, Y12
+	VBROADCASTSD 8(AX)(R12*1), Y13
+	VMULPD Y13, Y12, Y12
+	VBROADCASTSD 8(BX)(R12*1), Y13
+	VBLENDVPD Y15, Y13, Y3, Y3
+	VADDPD Y13, Y12, Y12
+	VBLENDVPD Y15, Y12, Y1, Y1
+	VBLENDVPD Y15, one<>(SB), Y4, Y4
+	VANDNPD Y5, Y15, Y5
+	VBLENDVPD Y15, ones<>(SB), Y6, Y6
+	VBLENDVPD Y15, firstSave<>(SB), Y10, Y10
+	VORPD Y15, Y7, Y7
+	MOVQ DX, S_IDX(SP)(R8*8)
+	INCQ DX
+	JMP nextlane
+
+escaped:
+	// Escaped straight away
+	MOVQ $0, (DI)(DX*8)
+	INCQ DX
+	JMP next
+
+empty:
+	VANDNPD Y7, Y15, Y7
+	MOVQ $-1, S_IDX(SP)(R8*8)
+
+nextlane:
+	LEAQ -1(R13), R9
+	ANDQ R9, R13
+	JNZ lane
+	VMOVMSKPD Y7, R9
+	TESTQ R9, R9
+	JNZ loop
+	VZEROUPPER
+	RET
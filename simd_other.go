@@ -0,0 +1,17 @@
+//go:build !amd64
+
+package main
+
+// The SIMD kernel is only written for amd64
+var hasSIMD = false
+
+// Name of the instructions the SIMD kernel uses
+const simdName = ""
+
+// mandelbrots iterates the points z with c from iteration 0 like
+// mandelbrot does, leaving their final z in z and their iterations in is
+func mandelbrots(z, c []complex128, maxDepth int, is []int) {
+	for k := range z {
+		is[k], z[k] = mandelbrot(z[k], c[k], 0, maxDepth)
+	}
+}
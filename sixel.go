@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"slices"
+	"sync"
+)
+
+// Sixel settings
+const (
+	// Colors each sixel image is quantized to, as many as most
+	// terminals have color registers for
+	sixelColors = 256
+
+	// Bits of each of red, green and blue the colors are told apart
+	// by when quantizing
+	sixelBits = 5
+)
+
+// sixelQuantizer holds the colors of an image being quantized, indexed
+// by the color cut down to sixelBits of each of red, green and blue
+type sixelQuantizer struct {
+	count [1 << (3 * sixelBits)]int
+	sum   [1 << (3 * sixelBits)][3]int
+	index [1 << (3 * sixelBits)]uint8
+	keys  []int // the colors used
+}
+
+// The quantizers free to use, as they are big to make for every line
+var sixelQuantizers = sync.Pool{New: func() any { return new(sixelQuantizer) }}
+
+// colorBox is a box of colors the median cut splits in two until
+// there is one for each color of the palette
+type colorBox struct {
+	keys      []int // the colors in the box
+	pixels    int   // pixels of those colors
+	score     int   // pixels times the widest range of a component
+	component int   // the component with the widest range
+}
+
+// writeSixelLine sends raw RGB data as sixels covering cols cells from
+// the cursor
+//
+// Sixels are drawn a pixel to a pixel, so the data is scaled to the
+// cells as the kitty protocol has the terminal do.
+func writeSixelLine(data []byte, width, height, cols int) {
+	data, w, h := scaleToCells(data, width, height, cols)
+	writeSixel(data, nil, w, h)
+}
+
+// writeSixelImage sends an image.RGBA as sixels at the cursor
+//
+// Sixels are either drawn or not, so the pixels more transparent than
+// not are left out and the rest drawn as they would be over black.
+func writeSixelImage(img *image.RGBA) {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	data := make([]byte, 3*width*height)
+	opaque := make([]bool, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			p, q := y*img.Stride+4*x, y*width+x
+			copy(data[3*q:3*q+3], img.Pix[p:p+3])
+			opaque[q] = img.Pix[p+3] >= 0x80
+		}
+	}
+	writeSixel(data, opaque, width, height)
+}
+
+// writeSixel sends the width x height raw RGB data as sixels at the
+// cursor, leaving out the pixels not set in opaque unless it is nil
+//
+// Each band of 6 rows is sent a color at a time, with the pixels of
+// the band that color set in the sixels, run length encoded.
+func writeSixel(data []byte, opaque []bool, width, height int) {
+	pix, palette := quantizeMedianCut(data, opaque)
+	var b bytes.Buffer
+	// Pixels not drawn are left as they are, rather than background
+	fmt.Fprintf(&b, "\033P0;1;0q\"1;1;%d;%d", width, height)
+	for i, c := range palette {
+		fmt.Fprintf(&b, "#%d;2;%d;%d;%d", i, percent(c.R), percent(c.G), percent(c.B))
+	}
+	bits := make([][]byte, len(palette))
+	inBand := make([]bool, len(palette))
+	var used []uint8
+	for y0 := 0; y0 < height; y0 += 6 {
+		for r := 0; r < 6 && y0+r < height; r++ {
+			for x := 0; x < width; x++ {
+				p := (y0+r)*width + x
+				if opaque != nil && !opaque[p] {
+					continue
+				}
+				c := pix[p]
+				if !inBand[c] {
+					inBand[c] = true
+					used = append(used, c)
+					if bits[c] == nil {
+						bits[c] = make([]byte, width)
+					}
+				}
+				bits[c][x] |= 1 << r
+			}
+		}
+		for k, c := range used {
+			if k > 0 {
+				b.WriteByte('$')
+			}
+			fmt.Fprintf(&b, "#%d", c)
+			writeSixelRow(&b, bits[c])
+			clear(bits[c])
+			inBand[c] = false
+		}
+		used = used[:0]
+		b.WriteByte('-')
+	}
+	b.WriteString("\033\\")
+	_, _ = screen.Write(b.Bytes())
+}
+
+// percent converts a color component to the percentage sixels use
+func percent(v uint8) int {
+	return (int(v)*100 + 127) / 255
+}
+
+// writeSixelRow writes the sixels of a band of one color, dropping
+// the empty ones at the end and run length encoding the rest
+func writeSixelRow(b *bytes.Buffer, row []byte) {
+	end := len(row)
+	for end > 0 && row[end-1] == 0 {
+		end--
+	}
+	for x := 0; x < end; {
+		n := 1
+		for x+n < end && row[x+n] == row[x] {
+			n++
+		}
+		c := row[x] + '?'
+		if n > 3 {
+			fmt.Fprintf(b, "!%d%c", n, c)
+		} else {
+			for range n {
+				b.WriteByte(c)
+			}
+		}
+		x += n
+	}
+}
+
+// quantizeMedianCut quantizes raw RGB data to a palette of at most
+// sixelColors colors, returning the index of each pixel's color and
+// the palette, leaving out the pixels not set in opaque unless it is
+// nil
+//
+// This is Heckbert's median cut - the box of colors with the most
+// pixels times its widest range of red, green or blue is split at the
+// median of that, until there are enough boxes. Each color of the
+// palette is the average of the pixels in its box.
+func quantizeMedianCut(data []byte, opaque []bool) ([]uint8, []color.RGBA) {
+	q := sixelQuantizers.Get().(*sixelQuantizer)
+	defer sixelQuantizers.Put(q)
+	n := len(data) / 3
+	pix := make([]uint8, n)
+	for i := 0; i < n; i++ {
+		if opaque != nil && !opaque[i] {
+			continue
+		}
+		r, g, b := data[3*i], data[3*i+1], data[3*i+2]
+		k := colorKey(r, g, b)
+		if q.count[k] == 0 {
+			q.keys = append(q.keys, k)
+		}
+		q.count[k]++
+		q.sum[k][0] += int(r)
+		q.sum[k][1] += int(g)
+		q.sum[k][2] += int(b)
+	}
+	var boxes []colorBox
+	if len(q.keys) <= sixelColors {
+		for _, k := range q.keys {
+			boxes = append(boxes, colorBox{keys: []int{k}, pixels: q.count[k]})
+		}
+	} else {
+		boxes = q.cut()
+	}
+	palette := make([]color.RGBA, len(boxes))
+	for i, box := range boxes {
+		var sum [3]int
+		for _, k := range box.keys {
+			q.index[k] = uint8(i)
+			for c := range sum {
+				sum[c] += q.sum[k][c]
+			}
+		}
+		palette[i] = color.RGBA{uint8(sum[0] / box.pixels), uint8(sum[1] / box.pixels), uint8(sum[2] / box.pixels), 255}
+	}
+	for i := 0; i < n; i++ {
+		if opaque == nil || opaque[i] {
+			pix[i] = q.index[colorKey(data[3*i], data[3*i+1], data[3*i+2])]
+		}
+	}
+	for _, k := range q.keys {
+		q.count[k], q.sum[k] = 0, [3]int{}
+	}
+	q.keys = q.keys[:0]
+	return pix, palette
+}
+
+// colorKey returns the color cut down to sixelBits of each component
+func colorKey(r, g, b uint8) int {
+	const shift = 8 - sixelBits
+	return int(r>>shift)<<(2*sixelBits) | int(g>>shift)<<sixelBits | int(b>>shift)
+}
+
+// keyComponent returns component c, 0 for red to 2 for blue, of the
+// color with key k
+func keyComponent(k, c int) int {
+	return k >> ((2 - c) * sixelBits) & (1<<sixelBits - 1)
+}
+
+// cut splits the colors used into sixelColors boxes
+func (q *sixelQuantizer) cut() []colorBox {
+	boxes := []colorBox{q.box(slices.Clone(q.keys))}
+	for len(boxes) < sixelColors {
+		best := 0
+		for i, box := range boxes {
+			if box.score > boxes[best].score {
+				best = i
+			}
+		}
+		box := boxes[best]
+		if box.score == 0 {
+			break
+		}
+		slices.SortFunc(box.keys, func(a, b int) int {
+			return keyComponent(a, box.component) - keyComponent(b, box.component)
+		})
+		// Split at the median pixel, keeping a color in each half
+		m, pixels := 1, q.count[box.keys[0]]
+		for m < len(box.keys)-1 && 2*(pixels+q.count[box.keys[m]]) <= box.pixels {
+			pixels += q.count[box.keys[m]]
+			m++
+		}
+		boxes[best] = q.box(box.keys[:m])
+		boxes = append(boxes, q.box(box.keys[m:]))
+	}
+	return boxes
+}
+
+// box makes the box of the colors keys
+func (q *sixelQuantizer) box(keys []int) colorBox {
+	box := colorBox{keys: keys}
+	var lo, hi [3]int
+	for c := range lo {
+		lo[c], hi[c] = 1<<sixelBits, -1
+	}
+	for _, k := range keys {
+		box.pixels += q.count[k]
+		for c := range lo {
+			lo[c], hi[c] = min(lo[c], keyComponent(k, c)), max(hi[c], keyComponent(k, c))
+		}
+	}
+	for c := range lo {
+		if score := box.pixels * (hi[c] - lo[c]); score > box.score {
+			box.score, box.component = score, c
+		}
+	}
+	return box
+}
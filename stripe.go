@@ -0,0 +1,48 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"math/cmplx"
+)
+
+// Number of stripes round each point of the set with stripe coloring
+const stripeFrequency = 5
+
+// stripeTerm returns the stripe term of a point of an orbit, from 0
+// to 1 as the sine of stripeFrequency times the angle of z goes from
+// -1 to 1
+//
+// The sine is worked out by raising the direction of z to the power
+// as that is much quicker than Phase and Sin in the inner loop.
+func stripeTerm(z complex128) float64 {
+	r := cmplx.Abs(z)
+	if r == 0 {
+		return 0.5
+	}
+	w := z / complex(r, 0)
+	p := complex(1, 0)
+	for k := 0; k < stripeFrequency; k++ {
+		p *= w
+	}
+	return 0.5 + 0.5*imag(p)
+}
+
+// stripeColor maps the average of the stripe terms of the orbit of a
+// point which escaped after i iterations to the gradient, which gives
+// smooth stripes which follow the shape of the set
+//
+// The averages with and without the last term are mixed by how far
+// through the last iteration z escaped so the colors don't step from
+// one iteration count to the next.
+func stripeColor(i int, z complex128, sum float64, maxDepth int) color.RGBA {
+	if neededSum() != stripeSum || i < 2 {
+		// Stripes not tracked for this fractal
+		return smoothColor(min(i, maxDepth-1), z, maxDepth)
+	}
+	avg := sum / float64(i)
+	prev := (sum - stripeTerm(z)) / float64(i-1)
+	f := 1 + math.Log2(math.Log(orbitEscape)/math.Log(cmplx.Abs(z)))
+	f = math.Min(math.Max(f, 0), 1)
+	return decomposeColor(paletteColor(f*avg+(1-f)*prev), z)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"slices"
+	"sync"
+)
+
+// subdivision works out a rectangle of a grid being plotted the way
+// Mariani and Silver did, by working out the pixels round its border
+// and only subdividing it to work out the pixels inside if they
+// aren't all the same
+//
+// Only rectangles whose border is all inside the set are filled in,
+// which is exact as the Mandelbrot set and its Julia sets have no
+// holes, so anything inside a loop of points of the set is in the set
+// too. Rectangles whose border all escaped after the same number of
+// iterations aren't filled as the smooth colorings vary across them,
+// and they can have minibrots inside.
+type subdivision struct {
+	x0, y0   float64 // set co-ordinates of the top left pixel of the grid
+	dx, dy   float64 // size of a pixel in set co-ordinates
+	width    int     // width of the grid
+	left     int     // column of the grid the pixels start at
+	top      int     // row of the grid the pixels start at
+	maxDepth int
+	iters    []iteration     // iteration results of the pixels
+	known    []bool          // set for the pixels worked out or covered already
+	ps       []complex128    // points of the border being worked out
+	border   []int           // pixels of the border being worked out
+	results  []iteration     // iteration results of the border
+	worker   int             // worker working it out
+	wg       *sync.WaitGroup // for the pieces split off for other workers
+}
+
+// Rectangles with fewer pixels than this are worked out by the worker
+// splitting them up rather than being split off for others to steal
+const minSplitPixels = 256
+
+// canSubdivide returns true if the view can be worked out by
+// subdivision
+func canSubdivide() bool {
+	return isMandelbrot(params.fractal) && flatInterior()
+}
+
+// point adds pixel x, y to the border to be worked out if it isn't
+// known already
+func (s *subdivision) point(x, y int) {
+	p := y*s.width + x
+	if !s.known[p] {
+		s.ps = append(s.ps, complex(s.x0+s.dx*float64(s.left+x), s.y0+s.dy*float64(s.top+y)))
+		s.border = append(s.border, p)
+		s.known[p] = true
+	}
+}
+
+// iterate works out the pixels added to the border
+//
+// The border points are worked out all at once so they can be
+// iterated together by the SIMD kernel.
+func (s *subdivision) iterate() {
+	s.results = slices.Grow(s.results[:0], len(s.ps))[:len(s.ps)]
+	iteratePoints(s.ps, s.maxDepth, s.results)
+	for k, p := range s.border {
+		s.iters[p] = s.results[k]
+	}
+	s.ps, s.border = s.ps[:0], s.border[:0]
+}
+
+// inside returns true if pixel x, y is inside the set
+func (s *subdivision) inside(x, y int) bool {
+	return s.iters[y*s.width+x].i >= s.maxDepth
+}
+
+// rect works out the w x h rectangle of pixels with top left x0, y0
+func (s *subdivision) rect(x0, y0, w, h int) {
+	x1, y1 := x0+w-1, y0+h-1
+	for x := x0; x <= x1; x++ {
+		s.point(x, y0)
+		s.point(x, y1)
+	}
+	for y := y0 + 1; y < y1; y++ {
+		s.point(x0, y)
+		s.point(x1, y)
+	}
+	s.iterate()
+	if w <= 2 || h <= 2 {
+		return
+	}
+	inside := true
+	for x := x0; x <= x1 && inside; x++ {
+		inside = s.inside(x, y0) && s.inside(x, y1)
+	}
+	for y := y0 + 1; y < y1 && inside; y++ {
+		inside = s.inside(x0, y) && s.inside(x1, y)
+	}
+	if inside {
+		for y := y0 + 1; y < y1; y++ {
+			for x := x0 + 1; x < x1; x++ {
+				if p := y*s.width + x; !s.known[p] {
+					s.iters[p] = iteration{i: s.maxDepth, filled: true}
+					s.known[p] = true
+				}
+			}
+		}
+		return
+	}
+	// Split across the longer side, the halves sharing the middle
+	// line which is worked out first so the halves only touch their
+	// own pixels
+	if w >= h {
+		m := w / 2
+		for y := y0 + 1; y < y1; y++ {
+			s.point(x0+m, y)
+		}
+		s.iterate()
+		s.split(x0, y0, m+1, h)
+		s.rect(x0+m, y0, w-m, h)
+	} else {
+		m := h / 2
+		for x := x0 + 1; x < x1; x++ {
+			s.point(x, y0+m)
+		}
+		s.iterate()
+		s.split(x0, y0, w, m+1)
+		s.rect(x0, y0+m, w, h-m)
+	}
+}
+
+// split works out the w x h rectangle of pixels with top left x0, y0,
+// splitting it off for another worker to steal if it is big enough
+func (s *subdivision) split(x0, y0, w, h int) {
+	if w*h < minSplitPixels {
+		s.rect(x0, y0, w, h)
+		return
+	}
+	t := &subdivision{x0: s.x0, y0: s.y0, dx: s.dx, dy: s.dy, width: s.width, left: s.left, top: s.top, maxDepth: s.maxDepth, iters: s.iters, known: s.known, wg: s.wg}
+	splitWork(s.worker, s.wg, func(worker int) {
+		t.worker = worker
+		t.rect(x0, y0, w, h)
+	})
+}
+
+// farmSubdivision returns the farm job of working out the w x height
+// tile starting at column x of the grid s is of, or nil if there are
+// no farm workers
+func farmSubdivision(s *subdivision, x, w, height int) *farmJob {
+	if !farming() {
+		return nil
+	}
+	known := make([]bool, 0, w*height)
+	for y := 0; y < height; y++ {
+		known = append(known, s.known[y*s.width+x:y*s.width+x+w]...)
+	}
+	t := farmTile{Subdivide: true, X0: s.x0, Y0: s.y0, Dx: s.dx, Dy: s.dy, Left: x, Top: s.top, Width: w, Height: height, MaxDepth: s.maxDepth, Known: known}
+	return newFarmJob(t, func(its []farmIteration) {
+		for y := 0; y < height; y++ {
+			for k := 0; k < w; k++ {
+				if p := y*s.width + x + k; !known[y*w+k] {
+					s.iters[p] = fromFarm(its[y*w+k])
+				}
+			}
+		}
+	})
+}
+
+// calculateBand computes the pixels of the band of height rows from
+// row top of the grid with top left at x0, y0 which aren't covered in
+// the background, either by subdivision in tileWidth pieces or row by
+// row
+//
+// covered may be nil if none of them are.
+func calculateBand(x0, y0, dx, dy float64, width, top, height, maxDepth int, iters []iteration, covered []bool, wg *sync.WaitGroup) {
+	if covered == nil {
+		covered = make([]bool, width*height)
+	}
+	if !canSubdivide() {
+		for y := 0; y < height; y++ {
+			calculateUncovered(x0, y0+dy*float64(top+y), dx, width, maxDepth, iters[y*width:(y+1)*width], covered[y*width:(y+1)*width], wg)
+		}
+		return
+	}
+	known := slices.Clone(covered)
+	for x := 0; x < width; x += tileWidth {
+		w := min(tileWidth, width-x)
+		s := &subdivision{x0: x0, y0: y0, dx: dx, dy: dy, width: width, top: top, maxDepth: maxDepth, iters: iters, known: known, wg: wg}
+		goFarm(wg, func(worker int) {
+			s.worker = worker
+			s.rect(x, 0, w, height)
+		}, farmSubdivision(s, x, w, height))
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// symmetryAxis returns the sum of the rows of a grid with top left at
+// y0 and pixels dy high which are mirror images of each other in the
+// real axis, and whether the rows can be mirrored
+//
+// The Mandelbrot set is symmetric about the real axis - the orbit of
+// the conjugate of c is the conjugate of the orbit of c, exactly so
+// in floating point as only the signs change - so rows mirrored in
+// the axis only need working out once. The grid must line up with
+// the axis, and the views worked relative to origin at deep zooms
+// aren't mirrored, nor are stripes whose sum isn't symmetric.
+func symmetryAxis(y0, dy float64) (int, bool) {
+	if !isMandelbrot(params.fractal) || params.julia || origin != nil || neededSum() == stripeSum {
+		return 0, false
+	}
+	s := -2 * y0 / dy
+	axis := math.Round(s)
+	if math.Abs(s-axis) > 1e-3 || math.Abs(axis) > 1e9 {
+		return 0, false
+	}
+	return int(axis), true
+}
+
+// mirrorRow fills in the iterations of a row which aren't skipped
+// from the row src which is its mirror image in the real axis,
+// marking them in skip
+func mirrorRow(dst, src []iteration, skip []bool) {
+	for x := range dst {
+		if skip[x] {
+			continue
+		}
+		it := src[x]
+		it.z, it.dz = cmplx.Conj(it.z), cmplx.Conj(it.dz)
+		dst[x] = it
+		skip[x] = true
+	}
+}
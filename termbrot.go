@@ -3,14 +3,19 @@ package main
 
 import (
 	"encoding/base64"
+	"flag"
 	"fmt"
+	"hash/fnv"
 	"image"
 	"image/color"
+	"io"
 	"log"
 	"math"
 	"math/cmplx"
 	"os"
 	"regexp"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 
@@ -24,44 +29,105 @@ import (
 
 // Constants
 const (
-	// X/Y aspect ratio
-	aspect = 1.0
+	// Cell size to assume if the terminal doesn't report its size in pixels
+	defaultCellWidth  = 10
+	defaultCellHeight = 20
 
 	// Fraction of the radius we pan on each keypress
 	pan = 0.2
 
 	// Factor we zoom in on each keypress
 	zoom = 2
+
+	// Size of the overlay text in points
+	defaultFontSize = 20
+	minFontSize     = 8
+	maxFontSize     = 72
+
+	// Factor we zoom in on each fine zoom keypress
+	fineZoom = 1.1
+
+	// Fraction of the radius we pan on a fine or coarse keypress
+	finePan   = 0.02
+	coarsePan = 1.0
+
+	// Factor we zoom in on each coarse zoom keypress
+	coarseZoom = 16
+
+	// How often progressive renderers refresh the image
+	refreshInterval = 250 * time.Millisecond
+
+	// Points whose orbit derivative squared falls below this are
+	// inside the set
+	interiorEpsilon = 1e-12
+)
+
+// Flags
+var (
+	flyRate    = flag.Float64("fly-rate", 1, "Fly-in zoom speed in doublings per second, negative to fly out")
+	aspectFlag = flag.Float64("aspect", 0, "Height/width aspect ratio of the pixels, 0 to work it out from the terminal")
 )
 
 // Globals
 var (
-	showHelp     = true
-	showInfo     = true
-	center       complex128
-	radius       float64
-	depth        int
-	textFace     font.Face
-	plotDuration time.Duration
-	imgWidth     int
-	imgHeight    int
-	decompose    = false
+	showHelp      = true
+	showInfo      = true
+	center        complex128
+	radius        float64
+	depth         int
+	ttfFont       *truetype.Font
+	textFace      font.Face
+	fallbackFonts []*truetype.Font // fonts for characters ttfFont doesn't have
+	fallbackFaces []font.Face
+	fontSize      float64 // size of textFace in points
+	plotDuration  time.Duration
+	imgWidth      int
+	imgHeight     int
+	decompose     = false
+	pixelAspect   = 1.0                       // height/width of a pixel on the screen
+	events        chan inputEvent             // input from the terminal
+	eventTime     time.Time                   // when the event being handled arrived
+	screen        io.Writer       = os.Stdout // where the images are sent
 )
 
 // reset to the start position
 func reset() {
-	center = complex(0, 0)
-	radius = 2.0
+	center, radius = homeView()
+	origin = nil
 	depth = 256
 }
 
-// Gradient colors
-var gradient = []color.RGBA{
-	{0, 0, 0, 255},       // Black
-	{0, 0, 255, 255},     // Blue
-	{255, 0, 0, 255},     // Red
-	{255, 255, 0, 255},   // Yellow
-	{255, 255, 255, 255}, // White
+// Gradient colors, which are replaced rather than changed in place
+var gradient = evenStops(palettes[0].colors)
+
+// gradientColor returns the color at t in [0, 1] along the gradient
+func gradientColor(t float64) color.RGBA {
+	t = math.Min(math.Max(t, 0), 1) // Clamp to [0, 1]
+
+	// Find the stops either side of t
+	idx := sort.Search(len(gradient), func(i int) bool { return gradient[i].pos >= t })
+	if idx == 0 {
+		return gradient[0].col
+	}
+	if idx == len(gradient) {
+		return gradient[idx-1].col
+	}
+	s1, s2 := gradient[idx-1], gradient[idx]
+	frac := 0.0
+	if s2.pos > s1.pos {
+		frac = (t - s1.pos) / (s2.pos - s1.pos)
+	}
+	return mixColors(s1.col, s2.col, frac)
+}
+
+// smoothCount returns the iteration count i at which z escaped made
+// continuous from how far past the escape radius z got, which goes
+// with the exponent of the iteration
+func smoothCount(i int, z complex128) float64 {
+	if fractalConverges(params.fractal) {
+		return convergedCount(i, z)
+	}
+	return float64(i) + 1.0 - math.Log(math.Log(cmplx.Abs(z)))/math.Log(fractalPower())
 }
 
 // smoothColor maps the Mandelbrot iteration depth to an RGB color
@@ -73,61 +139,143 @@ func smoothColor(i int, z complex128, maxDepth int) color.RGBA {
 		return color.RGBA{0, 0, 0, 255}
 	}
 
-	// Smooth iteration count
-	smooth := float64(i) + 1.0 - math.Log(math.Log(cmplx.Abs(z)))/math.Log(2.0)
+	smooth := smoothCount(i, z)
 
 	// Map smooth iteration to gradient index
 	t := smooth / float64(maxDepth) // Normalized to [0, 1]
-	t = math.Min(math.Max(t, 0), 1) // Clamp to [0, 1]
-
-	// Find two colors in the gradient
-	idx := int(t * float64(len(gradient)-1))
-	frac := t*float64(len(gradient)-1) - float64(idx)
+	return decomposeColor(paletteColor(t), z)
+}
 
-	c1 := gradient[idx]
-	c2 := gradient[int(math.Min(float64(idx+1), float64(len(gradient)-1)))]
+// iteration is the result of iterating a single point
+type iteration struct {
+	i   int        // number of iterations done
+	z   complex128 // final value of z
+	dz  complex128 // derivative of z if the coloring needs it, see escapeOrbit
+	sum float64    // sum over the orbit for stripe or exponential coloring, see neededSum
 
-	// Interpolate between c1 and c2
-	r := uint8(float64(c1.R)*(1-frac) + float64(c2.R)*frac)
-	g := uint8(float64(c1.G)*(1-frac) + float64(c2.G)*frac)
-	b := uint8(float64(c1.B)*(1-frac) + float64(c2.B)*frac)
+	filled bool // set if filled in as inside the set without iterating, see subdivision
+}
 
-	if decompose && imag(z) < 0 {
-		r = uint8(0.8 * float64(r))
-		g = uint8(0.8 * float64(g))
-		b = uint8(0.8 * float64(b))
+// mandelbrot iterates z starting from iteration i until it escapes
+// or reaches maxDepth iterations
+//
+// It also tracks the derivative of the orbit with respect to its
+// first point after 0 (which is critical so would make it 0). If that
+// shrinks below interiorEpsilon the orbit is being pulled into an
+// attracting cycle so the point is inside the set and it returns
+// maxDepth straight away, as it does if the orbit comes back round to
+// where it was, see periodFirstCheck.
+//
+// This is the hottest loop in the program so works on the real and
+// imaginary parts, keeping their squares for the escape test and the
+// next step, and is unrolled to do two steps each time round. Check
+// changes with "termbrot benchmark".
+func mandelbrot(z, c complex128, i, maxDepth int) (int, complex128) {
+	x, y := real(z), imag(z)
+	cx, cy := real(c), imag(c)
+	x2, y2 := x*x, y*y
+	dx, dy := 1.0, 0.0
+	eps := cycleEpsilon()
+	sx, sy, check := x, y, i+periodFirstCheck
+	if i == 0 && i < maxDepth && x2+y2 < 4 {
+		// No derivative to start from yet
+		x, y = x2-y2+cx, 2*x*y+cy
+		x2, y2 = x*x, y*y
+		i++
 	}
+	for ; i+1 < maxDepth; i += 2 {
+		if x2+y2 >= 4 {
+			return i, complex(x, y)
+		}
+		dx, dy = 2*(x*dx-y*dy), 2*(x*dy+y*dx)
+		if dx*dx+dy*dy < interiorEpsilon {
+			return maxDepth, complex(x, y)
+		}
+		x, y = x2-y2+cx, 2*x*y+cy
+		x2, y2 = x*x, y*y
+		if i == check {
+			sx, sy, check = x, y, 2*check
+		} else if (x-sx)*(x-sx)+(y-sy)*(y-sy) <= eps {
+			return maxDepth, complex(x, y)
+		}
+		if x2+y2 >= 4 {
+			return i + 1, complex(x, y)
+		}
+		dx, dy = 2*(x*dx-y*dy), 2*(x*dy+y*dx)
+		if dx*dx+dy*dy < interiorEpsilon {
+			return maxDepth, complex(x, y)
+		}
+		x, y = x2-y2+cx, 2*x*y+cy
+		x2, y2 = x*x, y*y
+		if i+1 == check {
+			sx, sy, check = x, y, 2*check
+		} else if (x-sx)*(x-sx)+(y-sy)*(y-sy) <= eps {
+			return maxDepth, complex(x, y)
+		}
+	}
+	for ; i < maxDepth; i++ {
+		if x2+y2 >= 4 {
+			break
+		}
+		dx, dy = 2*(x*dx-y*dy), 2*(x*dy+y*dx)
+		if dx*dx+dy*dy < interiorEpsilon {
+			return maxDepth, complex(x, y)
+		}
+		x, y = x2-y2+cx, 2*x*y+cy
+		x2, y2 = x*x, y*y
+		if i == check {
+			sx, sy, check = x, y, 2*check
+		} else if (x-sx)*(x-sx)+(y-sy)*(y-sy) <= eps {
+			return maxDepth, complex(x, y)
+		}
+	}
+	return i, complex(x, y)
+}
+
+// inCardioid returns true if c is inside the main cardioid of the
+// Mandelbrot set
+func inCardioid(c complex128) bool {
+	x, y := real(c), imag(c)
+	q := (x-0.25)*(x-0.25) + y*y
+	return q*(q+(x-0.25)) <= y*y/4
+}
 
-	return color.RGBA{r, g, b, 255}
+// inPeriod2Bulb returns true if c is inside the period 2 bulb of the
+// Mandelbrot set, the circle to the left of the main cardioid
+func inPeriod2Bulb(c complex128) bool {
+	x, y := real(c), imag(c)
+	return (x+1)*(x+1)+y*y <= 1.0/16
 }
 
 // calculateMandlebrotRectangle plots a horizontal rectangle from the mandelbrot set
 //
-// The result is set in line as uint8 (r, g, b) tuples
-func calculateMandlebrotRectangle(fx, fy, dx float64, width int, line []byte, wg *sync.WaitGroup) {
-	defer wg.Done()
-	p := 0
-	for x := 0; x < width; x++ {
-		z := complex(0, 0)
-		c := complex(fx, fy)
-		var i int
-		for i = 0; i < depth; i++ {
-			if cmplx.Abs(z) >= 2 {
-				break
-			}
-			z = z*z + c
-		}
-		col := smoothColor(i, z, depth)
-		line[p+0] = col.R
-		line[p+1] = col.G
-		line[p+2] = col.B
-		p += 3
+// The raw iteration results are set in iters, ready to be colored
+// by colorPixels.
+func calculateMandlebrotRectangle(fx, fy, dx float64, width, maxDepth int, iters []iteration) {
+	ps := make([]complex128, width)
+	for x := range ps {
+		ps[x] = complex(fx, fy)
 		fx += dx
 	}
+	iteratePoints(ps, maxDepth, iters)
 }
 
 // writeRGBAImage send an image.RGBA image data in chunks to the terminal.
 func writeRGBAImage(img *image.RGBA) {
+	switch graphics {
+	case itermGraphics:
+		writeITermImage(img)
+		return
+	case sixelGraphics:
+		writeSixelImage(img)
+		return
+	case blockGraphics:
+		writeBlockImage(img)
+		return
+	case brailleGraphics:
+		writeBrailleImage(img)
+		return
+	}
 	width := img.Rect.Dx()
 	height := img.Rect.Dy()
 	chunkSize := 4096
@@ -142,12 +290,30 @@ func writeRGBAImage(img *image.RGBA) {
 		chunk := data[:end]
 		data = data[end:]
 
-		fmt.Printf("\033_Gf=32,a=T,s=%d,v=%d,q=2,m=%s;%s\033\\", width, height, m, chunk)
+		fmt.Fprintf(screen, "\033_Gf=32,a=T,s=%d,v=%d,q=2,m=%s;%s\033\\", width, height, m, chunk)
+	}
+}
+
+// clearImages deletes all the images from the terminal and clears the
+// screen, eg after a resize when the old images no longer line up
+func clearImages() {
+	if graphics == kittyGraphics {
+		fmt.Fprintf(screen, "\033_Ga=d,d=A,q=2\033\\")
 	}
+	fmt.Fprintf(screen, "\033[2J")
+	forgetLines(-1)
+	blockCells, brailleCells, itermLines = nil, nil, nil
 }
 
 // writeRGB sends raw RGB image data in chunks.
-func writeRGB(rawData []byte, width, height int) {
+//
+// If cols is non zero then the terminal scales the image to cover
+// cols x rows cells.
+func writeRGB(rawData []byte, width, height, cols, rows int) {
+	placement := ""
+	if cols > 0 {
+		placement = fmt.Sprintf(",c=%d,r=%d", cols, rows)
+	}
 	chunkSize := 4096
 	data := base64.StdEncoding.EncodeToString(rawData)
 	for len(data) > 0 {
@@ -160,10 +326,98 @@ func writeRGB(rawData []byte, width, height int) {
 		chunk := data[:end]
 		data = data[end:]
 
-		fmt.Printf("\033_Gf=24,a=T,s=%d,v=%d,q=2,m=%s;%s\033\\", width, height, m, chunk)
+		fmt.Fprintf(screen, "\033_Gf=24,a=T,s=%d,v=%d%s,q=2,m=%s;%s\033\\", width, height, placement, m, chunk)
+	}
+}
+
+// writeRGBFrame sends a whole frame of raw RGB data in chunks of
+// cellHeight pixels high, each placed over one line of cols cells
+func writeRGBFrame(data []byte, width, height, cols, cellHeight int) {
+	rowSize := 3 * width
+	for h := 0; h < height; h += cellHeight {
+		chunkHeight := cellHeight
+		if h+chunkHeight > height {
+			chunkHeight = height - h
+		}
+		writeRGBLine(h/cellHeight, data[h*rowSize:(h+chunkHeight)*rowSize], width, chunkHeight, cols)
 	}
 }
 
+// sentLines holds a hash of the image last sent to each line of the
+// screen, or 0 if unknown, so lines which haven't changed between
+// frames needn't be sent again
+var sentLines []uint64
+
+// writeRGBLine sends raw RGB data to cover line row of the screen
+// with cols cells, leaving the cursor on the next line
+//
+// If the same image was the last one sent to the line it is still
+// showing so nothing is sent. In low bandwidth mode it is quantized
+// first, so small changes often don't need sending either. Terminals
+// leave the cursor in different places after sixels and blocks, so it
+// is moved to the next line.
+func writeRGBLine(row int, data []byte, width, height, cols int) {
+	if !sendRGBLine(&sentLines, row, 0, data, width, height, cols) || graphics != kittyGraphics {
+		fmt.Fprintf(screen, "\033[%d;1H", row+2)
+		return
+	}
+	fmt.Fprintf(screen, "\n")
+}
+
+// sendRGBLine sends raw RGB data to cover cols cells from the cursor,
+// which is at column col of line row, unless sent says it was the
+// last image sent to the line, returning whether it was sent
+func sendRGBLine(sent *[]uint64, row, col int, data []byte, width, height, cols int) bool {
+	var img *image.Paletted
+	pixels := data
+	if *lowBandwidthFlag {
+		img = quantize(data, width, height)
+		pixels = img.Pix
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%dx%d,%d;", width, height, cols)
+	h.Write(pixels)
+	sum := h.Sum64()
+	if row < len(*sent) && (*sent)[row] == sum {
+		return false
+	}
+	for len(*sent) <= row {
+		*sent = append(*sent, 0)
+	}
+	(*sent)[row] = sum
+	switch {
+	case graphics == itermGraphics:
+		writeITermLine(row, col, data, img, width, height, cols)
+	case graphics == sixelGraphics:
+		writeSixelLine(data, width, height, cols)
+	case graphics == blockGraphics:
+		writeBlockLine(row, col, data, width, height, cols)
+	case graphics == brailleGraphics:
+		writeBrailleLine(row, col, data, width, height, cols)
+	case img != nil:
+		writePaletted(img, cols)
+	default:
+		writeRGB(data, width, height, cols, 1)
+	}
+	return true
+}
+
+// forgetLines marks the first n lines of the screen, or all of them
+// if n < 0, as needing sending again, eg because something has been
+// drawn over them
+func forgetLines(n int) {
+	clear(sentLines[:linesToForget(n, sentLines)])
+	clear(sentPaneLines[:linesToForget(n, sentPaneLines)])
+}
+
+// linesToForget returns how many of sent the first n lines are
+func linesToForget(n int, sent []uint64) int {
+	if n < 0 || n > len(sent) {
+		return len(sent)
+	}
+	return n
+}
+
 // getTerminalSize retrieves the terminal size in rows, columns, and pixels
 func getTerminalSize() (int, int, int, int, error) {
 	ws, err := unix.IoctlGetWinsize(unix.Stdout, unix.TIOCGWINSZ)
@@ -176,15 +430,42 @@ func getTerminalSize() (int, int, int, int, error) {
 // getImageDimensions sizes up the output image
 //
 // This is 1 cell less on x and y to work around bug? in ghostty
+//
+// It also works out pixelAspect from the cell metrics. Each line of
+// the image is placed to cover exactly one row of cells, so if the
+// cells aren't a whole number of pixels, or the terminal doesn't
+// report its size in pixels and the image gets scaled, the pixels
+// aren't square and the set would be squashed without correction.
 func getImageDimensions() (imageWidth, imageHeight, rows, cols, cellWidth, cellHeight int) {
 	rows, cols, terminalWidth, terminalHeight, err := getTerminalSize()
 	if err != nil {
 		fmt.Printf("Error retrieving terminal size: %v\n", err)
 		os.Exit(1)
 	}
+	if terminalWidth <= 0 || terminalHeight <= 0 {
+		terminalWidth, terminalHeight = cols*defaultCellWidth, rows*defaultCellHeight
+	}
 	cellWidth, cellHeight = terminalWidth/cols, terminalHeight/rows
+	switch graphics {
+	case blockGraphics:
+		// Each cell shows two pixels, one above the other
+		cellWidth, cellHeight = 1, 2
+	case brailleGraphics:
+		// Each cell shows 2 x 4 dots
+		cellWidth, cellHeight = 2, 4
+	}
+	pixelAspect = *aspectFlag
+	if pixelAspect <= 0 {
+		trueCellWidth := float64(terminalWidth) / float64(cols)
+		trueCellHeight := float64(terminalHeight) / float64(rows)
+		pixelAspect = (trueCellHeight / float64(cellHeight)) / (trueCellWidth / float64(cellWidth))
+	}
 	cols -= 1 // reduce cols and rows to work around terminal differences
 	rows -= 1 // between kitty and ghostty
+	if dualPane {
+		// The view is the left pane with the Julia pane beside it
+		cols /= 2
+	}
 	imageWidth, imageHeight = cols*cellWidth, rows*cellHeight
 
 	return imageWidth, imageHeight, rows, cols, cellWidth, cellHeight
@@ -192,7 +473,13 @@ func getImageDimensions() (imageWidth, imageHeight, rows, cols, cellWidth, cellH
 
 // Gets the size of the image in set co-ordinates
 func getSetSize(width, height int) (dx, dy float64) {
+	return getSetSizeAt(radius, width, height)
+}
+
+// Gets the size of the image in set co-ordinates at the radius given
+func getSetSizeAt(radius float64, width, height int) (dx, dy float64) {
 	// Choose shortest direction for radius
+	aspect := pixelAspect
 	if float64(height) > float64(width)/aspect {
 		dx = 2 * radius / float64(width)
 		dy = 2 * radius / float64(width) * aspect
@@ -203,36 +490,216 @@ func getSetSize(width, height int) (dx, dy float64) {
 	return dx, dy
 }
 
+// panBy moves the view by fractions of the radius, rounded to whole
+// pixels so the last plot can be shifted rather than recomputed
+func panBy(fx, fy float64) {
+	width, height, _, _, _, _ := getImageDimensions()
+	dx, dy := getSetSize(width, height)
+	panPixels(int(math.Round(radius*fx/dx)), int(math.Round(radius*fy/dy)))
+}
+
+// panPixels moves the view by a whole number of pixels
+func panPixels(px, py int) {
+	width, height, _, _, _, _ := getImageDimensions()
+	dx, dy := getSetSize(width, height)
+	center += complex(float64(px)*dx, float64(py)*dy)
+}
+
+// resized is called when the terminal changes size
+//
+// The old images are cleared as they won't line up with the new
+// cells, and any drag in progress is abandoned as its positions were
+// in the old cells.
+func resized() {
+	clearImages()
+	describeAgain()
+	dragging, dragMoved = false, false
+}
+
+// stepSizes returns how far to pan and zoom for a key with the
+// modifiers in mod - Shift or Alt for fine steps and Ctrl for coarse
+func stepSizes(mod termbox.Modifier) (panStep, zoomStep float64) {
+	switch {
+	case mod&modCtrl != 0:
+		return coarsePan, coarseZoom
+	case mod&(modShift|termbox.ModAlt) != 0:
+		return finePan, fineZoom
+	}
+	return pan, zoom
+}
+
 // writeMandlebrotSet sends raw RGB data in chunks of chunkHeightPixels high
+//
+// If renderScale is more than 1 then the set is computed at a reduced
+// resolution and the terminal is asked to scale it up to fill the
+// screen.
 func writeMandlebrotSet() {
-	width, height, _, _, _, cellHeight := getImageDimensions()
+	width, height, rows, cols, _, cellHeight := getImageDimensions()
 	dx, dy := getSetSize(width, height)
 	imgWidth, imgHeight = width, height
+	updateAutoDepth()
+	// Choose the kernel by the full resolution pixels, so it doesn't
+	// change with the resolution while animating
+	rebase(dx)
+
+	// Work out the reduced resolution image size, keeping a whole
+	// number of pixel rows per row of cells.
+	scale := renderScale
+	if scale > 1 {
+		cellHeight = (cellHeight + scale - 1) / scale
+		dx *= float64(width) / float64(width/scale)
+		dy *= float64(height) / float64(rows*cellHeight)
+		width, height = width/scale, rows*cellHeight
+	}
+
+	x0, y0 := real(center)+dx*float64(-width/2), imag(center)+dy*float64(-height/2)
+
+	// Only reuse plots at full resolution, reduced resolution frames
+	// use the pyramid instead. If the last plot was refined then carry
+	// on at the refined depth so the colors match.
+	prev := lastPlot
+	plotDepth := depth
+	unchanged := false
+	var sources []plotSource
+	if scale > 1 {
+		updatePyramid()
+	} else {
+		cached := tiles.lookup(x0, y0, dx, dy, width, height)
+		if ox, oy, ok := prev.offset(x0, y0, dx, dy, prev.depth); ok {
+			plotDepth = prev.depth
+			unchanged = ox == 0 && oy == 0
+			sources = append(sources, plotSource{p: &prev, ox: ox, oy: oy})
+		} else if len(cached) > 0 {
+			// Revisiting somewhere so carry on at the depth it was
+			// refined to
+			plotDepth = commonDepth(cached)
+		}
+		for _, p := range slices.Concat(prefetched, cached) {
+			if ox, oy, ok := p.offset(x0, y0, dx, dy, plotDepth); ok {
+				sources = append(sources, plotSource{p: p, ox: ox, oy: oy})
+			}
+		}
+	}
 
 	rowSize := 3 * width
-	data := make([]byte, cellHeight*rowSize)
+	frame := make([]byte, height*rowSize)
+	iters := make([]iteration, height*width)
+	covered := make([]bool, height*width)
+	// Rows mirroring rows of the bands already done are copied from
+	// them. The pyramid doesn't keep the iterations so this is only
+	// done at full resolution.
+	axis, mirror := symmetryAxis(y0, dy)
+	mirror = mirror && scale == 1
+	// The bands are sent to the terminal in the background so the
+	// next one is being worked out while the last is sent
+	send := make(chan int)
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for h := range send {
+			chunkHeight := min(cellHeight, height-h)
+			writeRGBLine(h/cellHeight, frame[h*rowSize:(h+chunkHeight)*rowSize], width, chunkHeight, cols)
+		}
+	}()
+	// fillRows fills in the rows from top to bottom from the sources
+	// and the pyramid
+	fillRows := func(top, bottom int) {
+		for y := top; y < bottom; y++ {
+			line := frame[y*rowSize : (y+1)*rowSize]
+			lineIters := iters[y*width : (y+1)*width]
+			lineCovered := covered[y*width : (y+1)*width]
+			for _, source := range sources {
+				source.fillRow(y, width, line, lineIters, lineCovered)
+			}
+			if scale > 1 {
+				fillRowFromPyramid(x0, y0+dy*float64(y), dx, dy, width, line, lineCovered)
+			}
+		}
+	}
+	// On the GPU the frame is iterated in a few big bands of whole
+	// rows of cells rather than by the workers
+	gpuRows := 0
+	if gpuFrames() {
+		gpuRows = max(1, gpuBandHeight/cellHeight) * cellHeight
+	}
 	var wg sync.WaitGroup
-	fy := imag(center) + dy*float64(-height/2)
 	for h := 0; h < height; h += cellHeight {
 		chunkHeight := cellHeight
 		if h+chunkHeight > height {
 			chunkHeight = height - h
 		}
-		data := data[:chunkHeight*rowSize]
-		for y := h; y < h+chunkHeight; y++ {
-			fx := real(center) + dx*float64(-width/2)
-			wg.Add(1)
-			go calculateMandlebrotRectangle(fx, fy, dx, width, data[(y-h)*rowSize:(y-h+1)*rowSize], &wg)
-			fy += dy
-
+		data := frame[h*rowSize : (h+chunkHeight)*rowSize]
+		if gpuRows > 0 {
+			if h%gpuRows == 0 {
+				bandRows := min(gpuRows, height-h)
+				fillRows(h, h+bandRows)
+				iterateGridGPU(x0, y0, dx, dy, width, h, bandRows, plotDepth, iters[h*width:(h+bandRows)*width], covered[h*width:(h+bandRows)*width])
+			}
+		} else {
+			fillRows(h, h+chunkHeight)
+			skip := covered[h*width : (h+chunkHeight)*width]
+			if mirror {
+				skip = slices.Clone(skip)
+				for y := h; y < h+chunkHeight; y++ {
+					if m := axis - y; m >= 0 && m < h {
+						mirrorRow(iters[y*width:(y+1)*width], iters[m*width:(m+1)*width], skip[(y-h)*width:(y-h+1)*width])
+					}
+				}
+			}
+			calculateBand(x0, y0, dx, dy, width, h, chunkHeight, plotDepth, iters[h*width:(h+chunkHeight)*width], skip, &wg)
+			wg.Wait()
 		}
-		wg.Wait()
-		writeRGB(data, width, chunkHeight)
-		fmt.Printf("\n")
+		colorPixels(data, iters[h*width:(h+chunkHeight)*width], covered[h*width:(h+chunkHeight)*width], width, chunkHeight, plotDepth, dx)
+		send <- h
 		if len(data) == 0 {
 			break
 		}
 	}
+	close(send)
+	<-sent
+	if usesColoring(histogramColoring) {
+		// The colors depend on the whole frame so now it is all
+		// iterated color it again, sending the rows which changed
+		equalize(iters, plotDepth)
+		colorPixels(frame, iters, nil, width, height, plotDepth, dx)
+		for h := 0; h < height; h += cellHeight {
+			chunkHeight := min(cellHeight, height-h)
+			writeRGBLine(h/cellHeight, frame[h*rowSize:(h+chunkHeight)*rowSize], width, chunkHeight, cols)
+		}
+	}
+
+	if scale == 1 {
+		if !unchanged && !dragging {
+			tiles.put(&prev)
+		}
+		lastPlot = plot{
+			data:       frame,
+			iters:      iters,
+			width:      width,
+			height:     height,
+			x0:         x0,
+			y0:         y0,
+			dx:         dx,
+			dy:         dy,
+			baseDepth:  depth,
+			depth:      plotDepth,
+			decompose:  decompose,
+			coloring:   coloringID,
+			derivative: needsDerivative(),
+			sum:        neededSum(),
+			interior:   interior,
+			params:     params,
+			origin:     origin,
+			refined:    unchanged && prev.refined,
+			aliased:    !unchanged || prev.aliased,
+
+			paletteOffset: paletteOffset,
+		}
+		if unchanged {
+			lastPlot.samples = prev.samples
+			lastPlot.supersamples, lastPlot.kept, lastPlot.lostSamples = prev.supersamples, prev.kept, prev.lostSamples
+		}
+	}
 }
 
 // loadFont loads the font
@@ -240,19 +707,96 @@ func loadFont() (*truetype.Font, error) {
 	return truetype.Parse(gobold.TTF)
 }
 
-// drawText draws text onto an RGBA image using the specified font face
+// loadFallbackFonts loads the TrueType fonts at paths to draw the
+// characters the built in font doesn't have, eg for non Latin text
+func loadFallbackFonts(paths []string) error {
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		f, err := truetype.Parse(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		fallbackFonts = append(fallbackFonts, f)
+	}
+	return nil
+}
+
+// newFace makes a face of f at fontSize points
+func newFace(f *truetype.Font) font.Face {
+	return truetype.NewFace(f, &truetype.Options{
+		Size:    fontSize,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+}
+
+// setFontSize makes the font faces for the overlay text at size points
+func setFontSize(size float64) {
+	fontSize = max(minFontSize, min(maxFontSize, size))
+	textFace = newFace(ttfFont)
+	fallbackFaces = fallbackFaces[:0]
+	for _, f := range fallbackFonts {
+		fallbackFaces = append(fallbackFaces, newFace(f))
+	}
+}
+
+// textRun is part of a string to be drawn in a single face
+type textRun struct {
+	face font.Face
+	text string
+}
+
+// textRuns splits text into runs of characters which can be drawn in
+// the same face, using the fallback fonts for characters which the
+// main font doesn't have
+func textRuns(text string) []textRun {
+	var runs []textRun
+	for _, r := range text {
+		face := textFace
+		if ttfFont.Index(r) == 0 {
+			for i, f := range fallbackFonts {
+				if f.Index(r) != 0 {
+					face = fallbackFaces[i]
+					break
+				}
+			}
+		}
+		if n := len(runs); n > 0 && runs[n-1].face == face {
+			runs[n-1].text += string(r)
+		} else {
+			runs = append(runs, textRun{face: face, text: string(r)})
+		}
+	}
+	return runs
+}
+
+// measureText returns the width text will be drawn at in pixels
+func measureText(text string) int {
+	var width fixed.Int26_6
+	for _, run := range textRuns(text) {
+		width += font.MeasureString(run.face, run.text)
+	}
+	return width.Ceil()
+}
+
+// drawText draws text onto an RGBA image using the overlay fonts
 func drawText(img *image.RGBA, x, y int, text string, col color.Color) {
 	point := fixed.Point26_6{
 		X: fixed.Int26_6(x * 64),
 		Y: fixed.Int26_6(y * 64),
 	}
 	d := &font.Drawer{
-		Dst:  img,
-		Src:  image.NewUniform(col),
-		Face: textFace,
-		Dot:  point,
+		Dst: img,
+		Src: image.NewUniform(col),
+		Dot: point,
+	}
+	for _, run := range textRuns(text) {
+		d.Face = run.face
+		d.DrawString(run.text)
 	}
-	d.DrawString(text)
 }
 
 var truncateDuration = regexp.MustCompile(`(\.\d{2})\d*`) // Match . and at least 2 digits
@@ -263,69 +807,610 @@ func truncatedDuration(d time.Duration) string {
 	return truncateDuration.ReplaceAllString(str, `$1`) // Replace with only the first 2 digits
 }
 
+// helpText is the help shown in the overlay, the first line is the title
+var helpText = []string{
+	"Terminal Mandlebrot by ncw",
+	"• ←↑↓→ to pan, with shift/alt for fine steps, ctrl for coarse",
+	"• =/- or left/right click to zoom, +/_ to zoom finely",
+	"• z to zoom to a radius, Z to zoom onto the minibrot in the view",
+	"• drag or flick with the mouse to pan",
+	"• double click to center, middle click or j for the Julia set under the mouse",
+	"• b or ctrl-click to bookmark, B to list the bookmarks",
+	"• a to write a note in the journal",
+	"• [/] to change depth, | to set it from the zoom",
+	"• h/i toggle help/info, </> to change the text size",
+	"• t to change the overlay theme, p to change the palette, R for a random one, P to save it, l to load one",
+	"• d toggle binary decompose, D to switch it between binary and tinted, o toggle outlines of the cardioid and bulbs",
+	"• c to change the coloring - smooth, by angle, by angle and depth, by histogram, cyclic, by distance, by stripes or exponential",
+	"• x to mix the gradient in RGB, HSV or OKLCH",
+	"• M to draw in color, gray or black and white",
+	"• A to pick gamma, brightness, contrast or saturation, 9/0 to lower/raise it",
+	"• I to change the coloring inside the set - black, by modulus, by angle, by period or by distance",
+	"• n/N to change the density of the cyclic coloring, s/S to shift the palette",
+	"• C to start/stop cycling the palette",
+	"• L to light the set as a surface, w/W to turn the light round",
+	"• m to change the fractal, f toggle fractal flame, F for a new flame",
+	"• (/) to change the parameter of the fractal",
+	"• g for the Buddhabrot, Nebulabrot, anti-Buddhabrot or the set, ,/. for exposure",
+	"• 1/2/3 to pick the red/green/blue layer of the Nebulabrot, {/} to change its limit",
+	"• q/ESC/c-C to quit",
+	"• r to reset",
+	"• u/backspace to undo, U to redo",
+	"• ` or ctrl-^ to jump back to the last view and again to return",
+	"• space to start/stop fly-in zoom",
+	"• e to start/stop exploring automatically",
+	"• v to split the screen with the Julia set under the mouse on the right",
+	"• J to start/stop morphing the Julia set, K to change its path, ;/' for speed",
+}
+
+// infoText returns the lines of info to show in the overlay
+func infoText() []string {
+	info := []string{
+		fmt.Sprintf(tr("• Center %s"), centerText()),
+		fmt.Sprintf(tr("• Radius %g"), radius),
+	}
+	if flameMode {
+		info = append(info, fmt.Sprintf(tr("• Flame samples %d"), flameSamples()))
+	} else if buddhaMode != noBuddha {
+		info = append(info, buddhaInfo()...)
+	} else {
+		info = append(info, fractalName())
+		if autoDepth && lastPlot.depth > depth && lastPlotCurrent() {
+			info = append(info, fmt.Sprintf(tr("• Auto depth %d (refined to %d)"), depth, lastPlot.depth))
+		} else if autoDepth {
+			info = append(info, fmt.Sprintf(tr("• Auto depth %d"), depth))
+		} else if lastPlot.depth > depth && lastPlotCurrent() {
+			info = append(info, fmt.Sprintf(tr("• Depth %d (refined to %d)"), depth, lastPlot.depth))
+		} else {
+			info = append(info, fmt.Sprintf(tr("• Depth %d"), depth))
+		}
+		info = append(info, fmt.Sprintf(tr("• Palette %s mixed in %s"), paletteName(), interpolationName()))
+		if usesColoring(cyclicColoring) || paletteOffset != 0 {
+			info = append(info, fmt.Sprintf(tr("• Palette density %.3g, offset %.3g"), paletteDensity, paletteOffset))
+		}
+		if layers != nil {
+			info = append(info, fmt.Sprintf(tr("• Layers %s"), layersText()))
+		}
+		if lighting {
+			info = append(info, fmt.Sprintf(tr("• Lit from %g°"), lightAngle))
+		}
+		if adjusted() {
+			info = append(info, fmt.Sprintf(tr("• Gamma %.3g, brightness %.3g, contrast %.3g, saturation %.3g"), gamma, brightness, contrast, saturation))
+		}
+		if lastPlot.samples > 0 && lastPlotCurrent() {
+			info = append(info, fmt.Sprintf(tr("• Antialiased with %d samples"), lastPlot.samples+1))
+		}
+		if dualPane && !params.julia {
+			info = append(info, juliaPaneInfo())
+		}
+	}
+	if animating() {
+		info = append(info, fmt.Sprintf(tr("• Fly-in %.2f doublings/s at 1/%d resolution"), flyVelocity, renderScale))
+	}
+	info = append(info, fmt.Sprintf(tr("• Time %s (%d x %d)"), truncatedDuration(plotDuration), imgWidth, imgHeight))
+	info = append(info, kernelName())
+	info = append(info, workersInfo())
+	if farmConns > 0 {
+		info = append(info, farmInfo())
+	}
+	info = append(info, memoryInfo())
+	return info
+}
+
 // helpOverlay returns an image with the help text to overlay on the main image
 func helpOverlay() *image.RGBA {
-	width, height := 600, 300
-	h := 22
-	sp := 10
-	infoY := h * 10
-	if !showHelp {
-		height = 7 * h
-		infoY = h
-	}
-	textImg := image.NewRGBA(image.Rectangle{Max: image.Point{width, height}})
-	white := color.RGBA{255, 255, 255, 255}
-	g80 := color.RGBA{255, 255, 255, 204}
+	// Scale the layout with the font size
+	h := int(1.1*fontSize + 0.5)
+	sp := int(fontSize / 2)
+	lines := overlayLines()
+	// Make the image wide enough for the longest line
+	width := 0
+	for _, line := range lines {
+		width = max(width, measureText(line.text))
+	}
+	width += 2 * sp
+	textImg := image.NewRGBA(image.Rectangle{Max: image.Point{width, h * (len(lines) + 1)}})
+	t := theme()
+	t.fillPanel(textImg)
+	for i, line := range lines {
+		if line.text != "" {
+			t.drawText(textImg, sp, h*(i+1), line.text, line.col)
+		}
+	}
+	return textImg
+}
+
+// overlayLine is a line of text of an overlay, blank for a gap
+type overlayLine struct {
+	text string
+	col  color.RGBA
+}
+
+// overlayLines returns the lines of the help, info and prompt to show
+// in the overlay in the colors of the theme
+func overlayLines() []overlayLine {
+	t := theme()
+	var lines []overlayLine
 	if showHelp {
-		drawText(textImg, sp, h*1, "Terminal Mandlebrot by ncw", white)
-		drawText(textImg, sp, h*2, "• ←↑↓→ to pan", g80)
-		drawText(textImg, sp, h*3, "• +/- or left/right click to zoom", g80)
-		drawText(textImg, sp, h*4, "• [/] to change depth", g80)
-		drawText(textImg, sp, h*5, "• h/i toggle help/info", g80)
-		drawText(textImg, sp, h*6, "• d toggle binary decompose", g80)
-		drawText(textImg, sp, h*7, "• q/ESC/c-C to quit", g80)
-		drawText(textImg, sp, h*8, "• r to reset", g80)
+		for i, line := range helpText {
+			col := t.help
+			if i == 0 {
+				col = t.title
+			}
+			lines = append(lines, overlayLine{tr(line), col})
+		}
+		lines = append(lines, overlayLine{})
 	}
 	if showInfo {
-		b80 := color.RGBA{128, 128, 255, 204}
-		drawText(textImg, sp, infoY+h*0, fmt.Sprintf("• Center %g", center), b80)
-		drawText(textImg, sp, infoY+h*1, fmt.Sprintf("• Radius %g", radius), b80)
-		drawText(textImg, sp, infoY+h*2, fmt.Sprintf("• Depth %d", depth), b80)
-		drawText(textImg, sp, infoY+h*3, fmt.Sprintf("• Time %s (%d x %d)", truncatedDuration(plotDuration), imgWidth, imgHeight), b80)
+		for _, line := range infoText() {
+			lines = append(lines, overlayLine{line, t.info})
+		}
 	}
-	return textImg
+	if line := promptText(); line != "" {
+		lines = append(lines, overlayLine{line, t.prompt})
+	}
+	return lines
 }
 
 // draw the Mandelbrot set and any help/info required
 func draw() {
 	// Home the cursor - don't clear the screen
-	fmt.Printf("\033[H")
+	fmt.Fprintf(screen, "\033[H")
+	adaptResolution()
 	t0 := time.Now()
-	writeMandlebrotSet()
+	if flameMode {
+		writeFlame()
+	} else if buddhaMode != noBuddha {
+		writeBuddhabrot()
+	} else {
+		writeMandlebrotSet()
+	}
+	writeJuliaPane()
 	plotDuration = time.Since(t0)
+	logEvent(eventRecord{Event: "render", RenderMs: plotDuration.Seconds() * 1000, Width: imgWidth, Height: imgHeight})
+
+	drawOverlay()
+	writeDescription()
+	updateTitle()
+	journalVisit()
+}
 
-	if showHelp || showInfo {
-		// Home the cursor and print text overlay
-		fmt.Printf("\033[H")
-		img := helpOverlay()
-		writeRGBAImage(img)
+// drawOverlay draws any help/info required over the image
+func drawOverlay() {
+	if showOutlines && !densityMode() && isMandelbrot(params.fractal) {
+		fmt.Fprintf(screen, "\033[H")
+		writeRGBAImage(outlineOverlay(imgWidth, imgHeight))
+		forgetLines(-1)
+	}
+	if !menuOpen && !showHelp && !showInfo && promptText() == "" {
+		return
+	}
+	if graphics == blockGraphics || graphics == brailleGraphics {
+		lines := overlayLines()
+		if menuOpen {
+			lines = menuLines()
+		}
+		if graphics == blockGraphics {
+			forgetLines(writeBlockText(lines))
+		} else {
+			forgetLines(writeBrailleText(lines))
+		}
+		fmt.Fprintf(screen, "\033[H")
+		return
+	}
+	img := helpOverlay()
+	if menuOpen {
+		img = menuOverlay()
 	}
+	// Home the cursor and print text overlay
+	fmt.Fprintf(screen, "\033[H")
+	writeRGBAImage(img)
+	// The lines under the overlay must be sent again to remove it
+	_, _, _, _, _, cellHeight := getImageDimensions()
+	forgetLines((img.Rect.Dy() + cellHeight - 1) / cellHeight)
+}
+
+// handleEvent acts on a terminal event, returning whether the screen
+// needs redrawing and whether the user asked to quit
+func handleEvent(ev termbox.Event) (redraw, quit bool) {
+	before := currentView()
+	// Only the start of a drag goes in the history
+	remember := !(ev.Type == termbox.EventMouse && ev.Mod&termbox.ModMotion != 0 && dragMoved)
+	if screensaverInput(ev) {
+		return false, true
+	}
+	switch ev.Type {
+	case termbox.EventKey:
+		redraw = true
+		message = ""
+		if activePrompt != nil {
+			promptKey(ev)
+			break
+		}
+		if menuOpen {
+			menuKey(ev)
+			break
+		}
+		// Taking the controls stops exploring
+		if exploring && ev.Ch != 'e' {
+			exploring = false
+		}
+		panStep, zoomStep := stepSizes(ev.Mod)
+		switch ev.Key + termbox.Key(ev.Ch) {
+		case termbox.KeyEsc, termbox.KeyCtrlC, 'q':
+			return false, true
+		case 'u', termbox.KeyBackspace, termbox.KeyBackspace2:
+			panVelocityX, panVelocityY = 0, 0
+			return undo(), false
+		case 'U':
+			panVelocityX, panVelocityY = 0, 0
+			return redo(), false
+		case '`', termbox.KeyCtrl6:
+			panVelocityX, panVelocityY = 0, 0
+			redraw = jumpBack()
+		case termbox.KeyArrowUp:
+			panBy(0, -panStep)
+		case termbox.KeyArrowDown:
+			panBy(0, panStep)
+		case termbox.KeyArrowLeft:
+			panBy(-panStep, 0)
+		case termbox.KeyArrowRight:
+			panBy(panStep, 0)
+		case termbox.KeyPgup, '=':
+			radius /= zoomStep
+		case termbox.KeyPgdn, '-':
+			radius *= zoomStep
+		case '+':
+			radius /= fineZoom
+		case '_':
+			radius *= fineZoom
+		case 'z':
+			zoomToRadius()
+		case 'Z':
+			if err := zoomToMinibrot(); err != nil {
+				message = err.Error()
+			}
+		case 't':
+			nextTheme()
+		case 'b':
+			addBookmark(currentView())
+		case 'B':
+			openMenu(false)
+		case 'a':
+			annotate()
+		case 'p':
+			nextPalette()
+		case 'A':
+			nextAdjustment()
+		case '9':
+			changeAdjustment(-1)
+		case '0':
+			changeAdjustment(1)
+		case 'M':
+			nextTone()
+		case 'R':
+			randomPalette()
+		case 'P':
+			exportPalette()
+		case 'l':
+			importPalette()
+		case 'x':
+			nextInterpolation()
+		case 'n':
+			changeDensity(1 / 1.25)
+		case 'N':
+			changeDensity(1.25)
+		case 'C':
+			togglePaletteCycling()
+		case 'I':
+			nextInterior()
+		case 'L':
+			toggleLighting()
+		case 'w':
+			turnLight(1)
+		case 'W':
+			turnLight(-1)
+		case 's':
+			shiftPalette(-1)
+		case 'S':
+			shiftPalette(1)
+		case '<':
+			setFontSize(fontSize - 2)
+		case '>':
+			setFontSize(fontSize + 2)
+		case ']':
+			setDepth(depth * 2)
+		case '[':
+			setDepth(max(64, depth/2))
+		case '|':
+			toggleAutoDepth()
+		case 'h':
+			showHelp = !showHelp
+		case 'i':
+			showInfo = !showInfo
+		case 'o':
+			showOutlines = !showOutlines
+		case 'c':
+			nextColoring()
+		case 'd':
+			decompose = !decompose
+			recolor()
+		case 'D':
+			nextDecomposition()
+		case 'f':
+			flameMode = !flameMode
+			if flameMode {
+				setBuddhabrot(noBuddha)
+			} else {
+				stopFlame()
+			}
+		case 'F':
+			newFlame()
+			flameMode = true
+			setBuddhabrot(noBuddha)
+		case 'g':
+			nextBuddhabrot()
+		case '1', '2', '3':
+			nebulaChannel = int(ev.Ch - '1')
+		case '{':
+			changeNebulaLimit(false)
+		case '}':
+			changeNebulaLimit(true)
+		case ',':
+			changeExposure(1 / math.Sqrt2)
+		case '.':
+			changeExposure(math.Sqrt2)
+		case 'r':
+			reset()
+		case 'm':
+			nextFractal()
+		case '(':
+			changeParam(-1)
+		case ')':
+			changeParam(1)
+		case 'e':
+			toggleExplore()
+		case 'j':
+			toggleJulia(pointUnderMouse())
+		case 'J':
+			toggleMorph()
+		case 'v':
+			toggleDualPane()
+		case 'K':
+			nextMorphPath()
+		case ';':
+			changeMorphSpeed(1 / math.Sqrt2)
+		case '\'':
+			changeMorphSpeed(math.Sqrt2)
+		case ' ':
+			toggleFlyIn()
+		default:
+			redraw = false
+		}
+	case termbox.EventMouse:
+		exploring = false
+		trackMouse(ev)
+		if inJuliaPane(ev.MouseX) && !dragging {
+			break
+		}
+		redraw = handleMouse(ev)
+	case eventClick:
+		exploring = false
+		zoomAt(ev.MouseX, ev.MouseY, 1.0/zoom)
+		redraw = true
+	case eventHover:
+		trackMouse(ev)
+		// Follow the mouse with the Julia pane, skipping motion
+		// which is already out of date
+		redraw = dualPane && len(events) == 0
+	case termbox.EventResize:
+		// The cell size may have changed too, eg if the font size
+		// was changed, which is picked up when the image
+		// dimensions are next read.
+		redraw = true
+		resized()
+	case eventFocusIn, eventFocusOut:
+		focusChanged(ev.Type == eventFocusIn)
+	case eventCommand:
+		redraw = true
+		q, err := runCommand(<-commands)
+		if err != nil {
+			message = err.Error()
+		}
+		if q {
+			return false, true
+		}
+	}
+	if currentView() != before {
+		if remember {
+			pushHistory(before)
+		}
+		logEvent(eventRecord{Event: "navigate", Action: actionName(ev)})
+	}
+	return redraw, false
 }
 
 func main() {
-	// Load font
-	ttfFont, err := loadFont()
+	flag.Parse()
+	var err error
+	err = checkCommand()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	err = checkDescribeFlag()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if textOnly() {
+		screen = io.Discard
+	}
+	err = checkControlFlag()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	err = checkKernelFlag()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	err = checkWorkersFlag()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	err = checkFarmFlag()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	err = checkFractalFlag()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	err = checkSequenceFlag()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	err = checkFormulaFlag()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	err = checkHybridFlag()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	err = checkMorphFlags()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	checkLightingFlags()
+	err = checkInterpolateFlag()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	err = checkInteriorFlag()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	err = checkSectorsFlag()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	err = checkAdjustFlags()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	err = checkToneFlag()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	err = checkLayersFlag()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	err = checkGraphicsFlag()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	err = checkBrailleFlag()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	err = openEventLog()
+	if err != nil {
+		fmt.Printf("Error opening --events-json: %v\n", err)
+		os.Exit(1)
+	}
+	maxMemory, err = parseSize(*maxMemoryFlag)
+	if err != nil {
+		fmt.Printf("Error parsing --max-memory: %v\n", err)
+		os.Exit(1)
+	}
+
+	err = loadConfig()
+	if err != nil {
+		fmt.Printf("Error reading config: %v\n", err)
+		os.Exit(1)
+	}
+	err = startPalette()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	reset()
+	err = startView()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if benchmark {
+		runBenchmark()
+		return
+	}
+	if *serveWorkerFlag != "" {
+		err = serveWorker()
+		fmt.Printf("Error serving calculations: %v\n", err)
+		os.Exit(1)
+	}
+	if *renderFlag != "" {
+		msg, err := batchRender()
+		if err != nil {
+			notify("termbrot", fmt.Sprintf("Render failed: %v", err))
+			fmt.Printf("Error rendering: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(msg)
+		notify("termbrot", msg)
+		return
+	}
+
+	err = chooseLanguage()
+	if err != nil {
+		fmt.Printf("Error in config: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Load fonts
+	ttfFont, err = loadFont()
+	if err != nil {
+		fmt.Printf("Error loading font: %v\n", err)
+		os.Exit(1)
+	}
+	err = loadFallbackFonts(cfg.Fonts)
 	if err != nil {
 		fmt.Printf("Error loading font: %v\n", err)
 		os.Exit(1)
 	}
 
 	// Create a font face for drawing text
-	textFace = truetype.NewFace(ttfFont, &truetype.Options{
-		Size:    20,
-		DPI:     72,
-		Hinting: font.HintingFull,
-	})
+	setFontSize(cfg.FontSize)
+	if cfg.Theme != "" {
+		err = setTheme(cfg.Theme)
+		if err != nil {
+			fmt.Printf("Error in config: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Ask the terminal how to draw in it before termbox takes over
+	probeGraphics()
 
 	// Init termbox which will control most things about the
 	// terminal, but it doesn't support images yet so we'll do
@@ -334,74 +1419,97 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	// Remember where we got to so it can be started from next
+	// time, once the terminal is back to normal to show any error
+	defer func() {
+		if err := saveSession(); err != nil {
+			fmt.Printf("Error saving session: %v\n", err)
+		}
+	}()
 	defer termbox.Close()
 	termbox.SetInputMode(termbox.InputEsc | termbox.InputMouse)
+	fmt.Print(enableKeyboardProtocol + enableFocusReporting + enableMouseMotion + pushTitle)
+	defer fmt.Print(disableKeyboardProtocol + disableFocusReporting + disableMouseMotion + popTitle)
 
-	reset()
+	// Read events in the background so progressive renderers can
+	// keep refreshing the image while waiting for input.
+	events = make(chan inputEvent, 64)
+	go pollEvents()
+	readCommands()
+
+	startupMenu()
+	startScreensaver()
 	draw()
 	for {
-		redraw := false
-		ev := termbox.PollEvent()
-
-		switch ev.Type {
-		case termbox.EventKey:
-			redraw = true
-			switch ev.Key + termbox.Key(ev.Ch) {
-			case termbox.KeyEsc, termbox.KeyCtrlC, 'q':
-				return
-			case termbox.KeyArrowUp:
-				center += complex(0.0, -radius*pan)
-			case termbox.KeyArrowDown:
-				center += complex(0.0, radius*pan)
-			case termbox.KeyArrowLeft:
-				center += complex(-radius*pan, 0.0)
-			case termbox.KeyArrowRight:
-				center += complex(radius*pan, 0.0)
-			case termbox.KeyPgup, '=', '+':
-				radius /= zoom
-			case termbox.KeyPgdn, '-', '_':
-				radius *= zoom
-			case ']':
-				depth *= 2
-			case '[':
-				depth /= 2
-				if depth < 64 {
-					depth = 64
-				}
-			case 'h':
-				showHelp = !showHelp
-			case 'i':
-				showInfo = !showInfo
-			case 'd':
-				decompose = !decompose
-			case 'r':
-				reset()
+		screensaverCheck()
+		var ev inputEvent
+		if wait, ok := clickWaiting(); ok {
+			select {
+			case ev = <-events:
+			case <-time.After(wait):
+				ev = clickEvent()
+			}
+		} else if !focused {
+			ev = <-events
+		} else if animating() {
+			select {
+			case ev = <-events:
 			default:
-				redraw = false
+				animate()
+				draw()
+				continue
+			}
+		} else if densityMode() {
+			select {
+			case ev = <-events:
+			case <-time.After(refreshInterval):
+				draw()
+				continue
 			}
-		case termbox.EventMouse:
-			redraw = true
-			switch ev.Key {
-			case termbox.MouseLeft, termbox.MouseRight:
-				width, height, rows, cols, _, _ := getImageDimensions()
-				dx, dy := getSetSize(width, height)
-				newReal := real(center) + dx*float64(ev.MouseX-cols/2)/float64(cols)*float64(width)
-				newImag := imag(center) + dy*float64(ev.MouseY-rows/2)/float64(rows)*float64(height)
-				center = complex(newReal, newImag)
-				if ev.Key == termbox.MouseLeft && ev.Mod&termbox.ModAlt == 0 {
-					radius /= zoom
-				} else {
-					radius *= zoom
+		} else if paletteCycling && lastPlotCurrent() {
+			select {
+			case ev = <-events:
+			case <-time.After(frameTime):
+				cycleStep()
+				showLastPlot()
+				continue
+			}
+		} else if idlePending() {
+			select {
+			case ev = <-events:
+			case <-time.After(idleDelay):
+				if idleStep(func() bool { return len(events) > 0 }) {
+					showLastPlot()
 				}
-			case termbox.MouseWheelDown:
-				radius *= zoom
-			case termbox.MouseWheelUp:
-				radius /= zoom
+				continue
+			}
+		} else if wait, ok := screensaverWaiting(); ok {
+			select {
+			case ev = <-events:
+			case <-time.After(wait):
+				continue
+			}
+		} else {
+			ev = <-events
+		}
+
+		eventTime = ev.t
+		redraw, quit := handleEvent(ev.Event)
+		// Deal with any events which have queued up, eg mouse
+		// drags, before drawing
+	drain:
+		for !quit {
+			select {
+			case ev = <-events:
+				eventTime = ev.t
+				more, q := handleEvent(ev.Event)
+				redraw, quit = redraw || more, q
 			default:
-				redraw = false
+				break drain
 			}
-		case termbox.EventResize:
-			redraw = true
+		}
+		if quit {
+			return
 		}
 		if redraw {
 			draw()
@@ -0,0 +1,9 @@
+package main
+
+import "golang.org/x/sys/unix"
+
+// The ioctls to get and set the terminal's settings
+const (
+	ioctlGetTermios = unix.TCGETS
+	ioctlSetTermios = unix.TCSETS
+)
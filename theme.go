@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+)
+
+// overlayTheme is how the help and info overlays are drawn
+type overlayTheme struct {
+	name   string
+	title  color.RGBA // first line of the help
+	help   color.RGBA // rest of the help
+	info   color.RGBA // info lines
+	prompt color.RGBA // prompts and messages
+	panel  color.RGBA // background behind the text, transparent for none
+	shadow bool       // set to draw a drop shadow under the text
+}
+
+// The overlay themes, the first is the default
+var themes = []overlayTheme{
+	{
+		name:   "classic",
+		title:  color.RGBA{255, 255, 255, 255},
+		help:   color.RGBA{255, 255, 255, 204},
+		info:   color.RGBA{128, 128, 255, 204},
+		prompt: color.RGBA{255, 255, 255, 255},
+	},
+	{
+		name:   "shadow",
+		title:  color.RGBA{255, 255, 255, 255},
+		help:   color.RGBA{255, 255, 255, 230},
+		info:   color.RGBA{160, 160, 255, 230},
+		prompt: color.RGBA{255, 255, 255, 255},
+		shadow: true,
+	},
+	{
+		name:   "panel",
+		title:  color.RGBA{255, 255, 255, 255},
+		help:   color.RGBA{230, 230, 230, 255},
+		info:   color.RGBA{160, 160, 255, 255},
+		prompt: color.RGBA{255, 255, 255, 255},
+		panel:  color.RGBA{0, 0, 0, 160},
+	},
+	{
+		name:   "high-contrast",
+		title:  color.RGBA{255, 255, 0, 255},
+		help:   color.RGBA{255, 255, 255, 255},
+		info:   color.RGBA{0, 255, 255, 255},
+		prompt: color.RGBA{255, 255, 0, 255},
+		panel:  color.RGBA{0, 0, 0, 255},
+		shadow: true,
+	},
+}
+
+// The theme in use as an index into themes
+var themeIndex = 0
+
+// theme returns the overlay theme in use
+func theme() *overlayTheme {
+	return &themes[themeIndex]
+}
+
+// setTheme selects the overlay theme called name
+func setTheme(name string) error {
+	for i := range themes {
+		if themes[i].name == name {
+			themeIndex = i
+			return nil
+		}
+	}
+	var names []string
+	for _, t := range themes {
+		names = append(names, t.name)
+	}
+	return fmt.Errorf("unknown theme %q - use one of %s", name, strings.Join(names, ", "))
+}
+
+// nextTheme cycles to the next overlay theme
+func nextTheme() {
+	themeIndex = (themeIndex + 1) % len(themes)
+	message = fmt.Sprintf(tr("Theme %s"), theme().name)
+}
+
+// fillPanel draws the background panel of the theme, if any
+func (t *overlayTheme) fillPanel(img *image.RGBA) {
+	if t.panel.A == 0 {
+		return
+	}
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i+0], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = t.panel.R, t.panel.G, t.panel.B, t.panel.A
+	}
+}
+
+// drawText draws a line of text in the style of the theme
+func (t *overlayTheme) drawText(img *image.RGBA, x, y int, text string, col color.RGBA) {
+	if t.shadow {
+		offset := max(1, int(fontSize/10))
+		drawText(img, x+offset, y+offset, text, color.RGBA{0, 0, 0, col.A})
+	}
+	drawText(img, x, y, text, col)
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"container/list"
+	"math"
+)
+
+// Size of a tile in pixels
+const tileSize = 64
+
+// tileKey identifies a tile of the set
+//
+// Tiles are taken from plots on a grid of pixels which is global for
+// each zoom level, so the location is the position on that grid. As
+// views are not necessarily aligned to whole pixels on the global
+// grid, the phase of the grid is part of the key too.
+//
+// The coloring isn't part of the key as the tiles are colored again
+// from their iterations when it has changed since they were cut out.
+type tileKey struct {
+	dx, dy         float64 // zoom level as the size of a pixel
+	phaseX, phaseY int     // sub pixel phase of the grid in 1/1000 pixel
+	tx, ty         int64   // position of the tile on the grid
+	baseDepth      int     // depth requested by the user
+	params         fractalParams
+	origin         *deepPoint // the point the grid is relative to
+}
+
+// tileCache is a least recently used cache of tiles
+type tileCache struct {
+	tiles map[tileKey]*list.Element
+	lru   *list.List // of *tileEntry, most recently used at the front
+	bytes int64      // memory used by the tiles
+}
+
+// tileEntry is an element of the tile cache
+type tileEntry struct {
+	key  tileKey
+	tile *plot
+}
+
+// The tiles of recently plotted views
+var tiles = tileCache{
+	tiles: make(map[tileKey]*list.Element),
+	lru:   list.New(),
+}
+
+// gridPosition returns the global pixel index of the first pixel of a
+// grid starting at x0 and its phase in 1/1000 of a pixel
+func gridPosition(x0, dx float64) (index int64, phase int) {
+	f := x0 / dx
+	fi := math.Floor(f)
+	phase = int(math.Round((f - fi) * 1000))
+	if phase == 1000 {
+		fi++
+		phase = 0
+	}
+	return int64(fi), phase
+}
+
+// floorDiv returns a/b rounded down
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+// tileRange returns the range of tiles [t0, t1) which overlap a run of
+// n pixels starting at global index i, and if whole is set only the
+// tiles which are entirely within it
+func tileRange(i int64, n int, whole bool) (t0, t1 int64) {
+	if whole {
+		return floorDiv(i+tileSize-1, tileSize), floorDiv(i+int64(n), tileSize)
+	}
+	return floorDiv(i, tileSize), floorDiv(i+int64(n)+tileSize-1, tileSize)
+}
+
+// tileKey returns the key of tile tx, ty on the grid of p
+func (p *plot) tileKey(phaseX, phaseY int, tx, ty int64) tileKey {
+	return tileKey{
+		dx:        p.dx,
+		dy:        p.dy,
+		phaseX:    phaseX,
+		phaseY:    phaseY,
+		tx:        tx,
+		ty:        ty,
+		baseDepth: p.baseDepth,
+		params:    p.params,
+		origin:    p.origin,
+	}
+}
+
+// put cuts the whole tiles out of p and stores them in the cache
+func (tc *tileCache) put(p *plot) {
+	if p.data == nil {
+		return
+	}
+	ix, phaseX := gridPosition(p.x0, p.dx)
+	iy, phaseY := gridPosition(p.y0, p.dy)
+	tx0, tx1 := tileRange(ix, p.width, true)
+	ty0, ty1 := tileRange(iy, p.height, true)
+	for ty := ty0; ty < ty1; ty++ {
+		for tx := tx0; tx < tx1; tx++ {
+			key := p.tileKey(phaseX, phaseY, tx, ty)
+			if e, ok := tc.tiles[key]; ok {
+				old := e.Value.(*tileEntry).tile
+				if old.depth == p.depth && old.aliased == p.aliased && old.samples >= p.samples &&
+					old.derivative == p.derivative && old.sum == p.sum && old.interior == p.interior {
+					// Already have this tile
+					tc.lru.MoveToFront(e)
+					continue
+				}
+			}
+			// Offset of the tile within p
+			ox := int(tx*tileSize - ix)
+			oy := int(ty*tileSize - iy)
+			t := &plot{
+				data:       make([]byte, 3*tileSize*tileSize),
+				iters:      make([]iteration, tileSize*tileSize),
+				width:      tileSize,
+				height:     tileSize,
+				x0:         p.x0 + p.dx*float64(ox),
+				y0:         p.y0 + p.dy*float64(oy),
+				dx:         p.dx,
+				dy:         p.dy,
+				baseDepth:  p.baseDepth,
+				depth:      p.depth,
+				decompose:  p.decompose,
+				coloring:   p.coloring,
+				derivative: p.derivative,
+				sum:        p.sum,
+				interior:   p.interior,
+				params:     p.params,
+				origin:     p.origin,
+				refined:    p.refined,
+				aliased:    p.aliased,
+				samples:    p.samples,
+
+				paletteOffset: p.paletteOffset,
+			}
+			for y := 0; y < tileSize; y++ {
+				sp := (oy+y)*p.width + ox
+				copy(t.data[3*y*tileSize:3*(y+1)*tileSize], p.data[3*sp:3*(sp+tileSize)])
+				copy(t.iters[y*tileSize:(y+1)*tileSize], p.iters[sp:sp+tileSize])
+			}
+			tc.add(key, t)
+		}
+	}
+}
+
+// add puts a tile in the cache replacing any existing one, evicting
+// the least recently used tiles if necessary
+func (tc *tileCache) add(key tileKey, t *plot) {
+	tc.bytes += t.size()
+	if e, ok := tc.tiles[key]; ok {
+		entry := e.Value.(*tileEntry)
+		tc.bytes -= entry.tile.size()
+		entry.tile = t
+		tc.lru.MoveToFront(e)
+	} else {
+		tc.tiles[key] = tc.lru.PushFront(&tileEntry{key: key, tile: t})
+	}
+	enforceMemoryBudget()
+}
+
+// evict removes the least recently used tile
+func (tc *tileCache) evict() {
+	e := tc.lru.Back()
+	if e == nil {
+		return
+	}
+	entry := e.Value.(*tileEntry)
+	tc.lru.Remove(e)
+	delete(tc.tiles, entry.key)
+	tc.bytes -= entry.tile.size()
+}
+
+// get returns a tile from the cache or nil if not found
+func (tc *tileCache) get(key tileKey) *plot {
+	e, ok := tc.tiles[key]
+	if !ok {
+		return nil
+	}
+	tc.lru.MoveToFront(e)
+	return e.Value.(*tileEntry).tile
+}
+
+// lookup returns the cached tiles which overlap a width x height grid
+// at x0, y0 and have the iterations the current coloring needs.
+func (tc *tileCache) lookup(x0, y0, dx, dy float64, width, height int) []*plot {
+	ix, phaseX := gridPosition(x0, dx)
+	iy, phaseY := gridPosition(y0, dy)
+	tx0, tx1 := tileRange(ix, width, false)
+	ty0, ty1 := tileRange(iy, height, false)
+	key := tileKey{dx: dx, dy: dy, phaseX: phaseX, phaseY: phaseY, baseDepth: depth, params: params, origin: origin}
+	var found []*plot
+	for key.ty = ty0; key.ty < ty1; key.ty++ {
+		for key.tx = tx0; key.tx < tx1; key.tx++ {
+			if t := tc.get(key); t != nil && t.recolorable() {
+				found = append(found, t)
+			}
+		}
+	}
+	return found
+}
+
+// commonDepth returns the depth most of the tiles are plotted at
+func commonDepth(ts []*plot) int {
+	count := make(map[int]int)
+	best := depth
+	for _, t := range ts {
+		count[t.depth]++
+		if count[t.depth] > count[best] {
+			best = t.depth
+		}
+	}
+	return best
+}
@@ -0,0 +1,49 @@
+package main
+
+import "fmt"
+
+// Escape sequences to save the terminal title before changing it
+// and to put it back afterwards
+const (
+	pushTitle = "\033[22;2t"
+	popTitle  = "\033[23;2t"
+)
+
+// The title last set so it is only sent when it changes
+var lastTitle string
+
+// titleText returns a short description of the view for the title
+func titleText() string {
+	if densityMode() {
+		return "termbrot " + densityName()
+	}
+	title := "termbrot "
+	if !isMandelbrot(params.fractal) {
+		title += params.fractal.Name() + " "
+	}
+	if f := formulaText(params); f != "" {
+		title += f + " "
+	}
+	if params.hybrid != "" {
+		title += params.hybrid + " "
+	}
+	if fp := fractalParamOf(params.fractal); fp != nil {
+		title += fmt.Sprintf("%s=%g ", fp.name, params.param)
+	}
+	title += fmt.Sprintf("%.6g r=%.3g", absCenter(), radius)
+	if params.julia {
+		title += fmt.Sprintf(" julia %.4g", params.c)
+	}
+	return title
+}
+
+// updateTitle sets the window title to the current location if it
+// has changed, with OSC 2
+func updateTitle() {
+	title := titleText()
+	if title == lastTitle {
+		return
+	}
+	lastTitle = title
+	fmt.Fprintf(screen, "\033]2;%s\033\\", title)
+}
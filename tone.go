@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// Flags
+var (
+	toneFlag = flag.String("tone", "color", "Tone to draw in - color, gray for grayscale or mono for black and white only")
+)
+
+// toneMode is whether the palette or shades of gray are drawn with
+type toneMode int
+
+// The tones
+const (
+	colorTone toneMode = iota // the palette
+	grayTone                  // black to white
+	monoTone                  // black and white bands
+)
+
+// Names of the tones indexed by toneMode
+var toneNames = []string{"color", "gray", "mono"}
+
+// Number of bands the palette is split into with mono, alternately
+// white and black
+const monoBands = 16
+
+// The tone in use
+var tone = colorTone
+
+// checkToneFlag sets the tone from --tone
+func checkToneFlag() error {
+	if err := setTone(*toneFlag); err != nil {
+		return fmt.Errorf("--tone: %w", err)
+	}
+	return nil
+}
+
+// setTone selects the tone called name
+func setTone(name string) error {
+	for i, n := range toneNames {
+		if n == name {
+			if tone != toneMode(i) {
+				tone = toneMode(i)
+				coloringID++
+				recolor()
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown tone %q - use one of %s", name, strings.Join(toneNames, ", "))
+}
+
+// nextTone cycles to the next tone
+func nextTone() {
+	_ = setTone(toneNames[(int(tone)+1)%len(toneNames)])
+	message = fmt.Sprintf(tr("Tone %s"), toneNames[tone])
+}
+
+// toneColor returns the color at t from 0 to 1 in the tone given,
+// which isn't colorTone
+//
+// Gray maps t straight to the brightness, for e-ink terminals, and
+// mono splits it into bands of black and white like contour lines,
+// for printing.
+func toneColor(t float64) color.RGBA {
+	t = math.Min(math.Max(t, 0), 1)
+	if tone == monoTone {
+		if int(t*monoBands)%2 == 0 {
+			return color.RGBA{255, 255, 255, 255}
+		}
+		return color.RGBA{0, 0, 0, 255}
+	}
+	v := uint8(255*t + 0.5)
+	return color.RGBA{v, v, v, 255}
+}
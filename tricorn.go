@@ -0,0 +1,44 @@
+package main
+
+func init() {
+	registerFractal(50, tricornFractal{})
+}
+
+// tricornFractal is the Tricorn, also called the Mandelbar set
+type tricornFractal struct{}
+
+func (tricornFractal) Name() string                       { return "tricorn" }
+func (tricornFractal) Title() string                      { return "Tricorn" }
+func (tricornFractal) DefaultView() (complex128, float64) { return complex(-0.3, 0), 1.7 }
+
+// Iterate iterates the Tricorn from iteration i until it escapes or
+// reaches maxDepth iterations
+//
+// This is the Mandelbrot iteration with z conjugated before squaring.
+// Conjugating doesn't change the size of the derivative, so it stops
+// early inside the set the same way as multibrot.
+func (tricornFractal) Iterate(z, c complex128, i, maxDepth int) (int, complex128) {
+	x, y := real(z), imag(z)
+	cx, cy := real(c), imag(c)
+	d := 1.0 // |dz|²
+	for ; i < maxDepth; i++ {
+		r2 := x*x + y*y
+		if r2 >= escapeRadius*escapeRadius {
+			break
+		}
+		if i > 0 {
+			d *= 4 * r2
+			if d < interiorEpsilon {
+				return maxDepth, complex(x, y)
+			}
+		}
+		x, y = tricornStep(x, y, cx, cy)
+	}
+	return i, complex(x, y)
+}
+
+// tricornStep does one step of the Tricorn iteration of x, y with c at
+// cx, cy
+func tricornStep(x, y, cx, cy float64) (float64, float64) {
+	return x*x - y*y + cx, -2*x*y + cy
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Flags
+var (
+	workersFlag = flag.Int("workers", 0, "Number of calculations to run at once, 0 to tune it automatically")
+)
+
+// Constants
+const (
+	// Time to measure each work setting for when tuning
+	tuneTime = 20 * time.Millisecond
+
+	// Times to measure all the settings when tuning, each keeping
+	// its best, so a blip while one is measured doesn't count
+	// against it
+	tuneRounds = 2
+
+	// Measure the setting chosen again this often, tuning again if
+	// its throughput drops below retuneDrop of what it was when
+	// tuned, eg as the CPU throttles when hot
+	retuneInterval = time.Minute
+	retuneDrop     = 0.7
+
+	// Pixels in each piece of a row when rows are split up
+	tileWidth = 64
+
+	// Jobs from outside the pool which can be queued or running for
+	// each worker, enough to keep them all busy
+	queuedPerWorker = 4
+)
+
+// The grid of the Mandelbrot set the work settings are measured on,
+// round the edge of Seahorse Valley where the points take very
+// different numbers of iterations as they do in most frames
+const (
+	tuneX0, tuneY0        = -0.77, 0.08
+	tuneDx                = 2e-4
+	tuneWidth, tuneHeight = 128, 64
+	tuneDepth             = 1000
+)
+
+// workSetting is how the calculation is split up
+type workSetting struct {
+	workers int  // calculations run at once
+	split   bool // set to split rows into tileWidth pieces
+}
+
+// Globals
+var (
+	work      workSetting
+	pool      workerPool
+	tuning    []workSetting // settings to measure, none once tuned
+	tunedRate float64       // iterations/s of the setting chosen when measured
+	tunedAt   time.Time     // when the setting chosen was last measured
+)
+
+// checkWorkersFlag sets up the workers from --workers
+func checkWorkersFlag() error {
+	if *workersFlag < 0 {
+		return fmt.Errorf("--workers must not be negative")
+	}
+	if *workersFlag > 0 {
+		setWork(workSetting{workers: *workersFlag, split: true})
+		return nil
+	}
+	setWork(workSetting{workers: runtime.NumCPU(), split: true})
+	startTuning()
+	return nil
+}
+
+// A job run by the workers, marking wg done when finished
+type job struct {
+	fn    func(w int)     // called with the worker running it
+	wg    *sync.WaitGroup // marked done when finished
+	slots chan struct{}   // holds a token for the job if it came from outside the pool
+	farm  *farmJob        // how a farm worker can do the job instead if it can
+}
+
+// workerPool runs the calculations on a fixed set of goroutines, the
+// workers, rather than starting a goroutine for each
+//
+// Each worker has its own queue of jobs. Jobs from outside the pool
+// are dealt out to the queues in turn, and jobs a worker splits off
+// what it is doing go on its own queue. Workers take from the back of
+// their own queues, carrying on with the newest and smallest pieces
+// of what they were doing, and when theirs is empty steal from the
+// front of the others, taking the oldest and biggest, so a slow part
+// of the frame gets shared out rather than holding up the rest.
+type workerPool struct {
+	mu     sync.Mutex
+	wake   sync.Cond     // broadcast when jobs are queued or the size changes
+	queues [][]job       // queue of each worker started
+	size   int           // workers in use, the others only finish their queues
+	next   int           // queue the next job from outside goes on
+	slots  chan struct{} // holds a token for each job from outside queued or running
+	remote int           // farm workers taking jobs too
+}
+
+// resize sets the number of workers in use, starting more if needed
+//
+// Workers aren't stopped, the ones not in use just wait.
+func (p *workerPool) resize(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.wake.L = &p.mu
+	p.size = n
+	p.slots = make(chan struct{}, queuedPerWorker*(n+p.remote))
+	for w := len(p.queues); w < n; w++ {
+		p.queues = append(p.queues, nil)
+		go p.work(w)
+	}
+	p.wake.Broadcast()
+}
+
+// add queues j for worker w, or the next worker in turn if w < 0
+func (p *workerPool) add(w int, j job) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if w < 0 {
+		w = p.next % p.size
+		p.next++
+	}
+	p.queues[w] = append(p.queues[w], j)
+	p.wake.Broadcast()
+}
+
+// take waits for a job for worker w, from its own queue if it has any
+// or stolen from another worker's if not
+func (p *workerPool) take(w int) job {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		if q := p.queues[w]; len(q) > 0 {
+			j := q[len(q)-1]
+			q[len(q)-1] = job{}
+			p.queues[w] = q[:len(q)-1]
+			return j
+		}
+		if w < p.size {
+			for k := 1; k < len(p.queues); k++ {
+				v := (w + k) % len(p.queues)
+				if q := p.queues[v]; len(q) > 0 {
+					j := q[0]
+					q[0] = job{}
+					p.queues[v] = q[1:]
+					return j
+				}
+			}
+		}
+		p.wake.Wait()
+	}
+}
+
+// takeFarm waits for a job a farm worker can do, stolen from the
+// front of any of the queues
+func (p *workerPool) takeFarm() job {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		for v, q := range p.queues {
+			for k, j := range q {
+				if j.farm != nil {
+					p.queues[v] = slices.Delete(q, k, k+1)
+					return j
+				}
+			}
+		}
+		p.wake.Wait()
+	}
+}
+
+// addRemote makes room on the queues for another farm worker
+func (p *workerPool) addRemote() {
+	p.mu.Lock()
+	p.remote++
+	p.mu.Unlock()
+	p.resize(p.size)
+}
+
+// work runs the jobs of worker w
+func (p *workerPool) work(w int) {
+	for {
+		j := p.take(w)
+		j.fn(w)
+		j.finish()
+	}
+}
+
+// finish marks the job done
+func (j *job) finish() {
+	if j.slots != nil {
+		<-j.slots
+	}
+	j.wg.Done()
+}
+
+// setWork changes how the calculation is split up
+func setWork(s workSetting) {
+	work = s
+	pool.resize(s.workers)
+}
+
+// startTuning lists the settings worth trying to be measured when
+// there is nothing else to do
+//
+// With hyperthreading only half of runtime.NumCPU are real cores so
+// that many workers may do as well with less contention, and more
+// workers than CPUs can help keep them busy.
+func startTuning() {
+	n := runtime.NumCPU()
+	counts := []int{n, 2 * n}
+	if n >= 4 {
+		counts = append(counts, n/2)
+	}
+	tuning = tuning[:0]
+	for _, count := range counts {
+		tuning = append(tuning, workSetting{workers: count, split: true}, workSetting{workers: count})
+	}
+}
+
+// goWork runs fn on a worker, calling wg.Done when finished
+//
+// It waits until there is room on the queues, so the caller doesn't
+// get far ahead of the workers and can stop adding jobs if
+// interrupted.
+func goWork(wg *sync.WaitGroup, fn func()) {
+	goWorker(wg, func(int) { fn() })
+}
+
+// goWorker is goWork for jobs which need to know the worker w running
+// them so they can split pieces off with splitWork
+func goWorker(wg *sync.WaitGroup, fn func(w int)) {
+	goFarm(wg, fn, nil)
+}
+
+// goFarm is goWorker for jobs which farm workers can do too, as f
+// says, if there are any
+func goFarm(wg *sync.WaitGroup, fn func(w int), f *farmJob) {
+	pool.mu.Lock()
+	slots := pool.slots
+	pool.mu.Unlock()
+	slots <- struct{}{}
+	wg.Add(1)
+	pool.add(-1, job{fn: fn, wg: wg, slots: slots, farm: f})
+}
+
+// splitWork queues fn on worker w as a piece split off what it is
+// doing which other workers can steal, calling wg.Done when finished
+//
+// Unlike goWork it doesn't wait, so workers can call it.
+func splitWork(w int, wg *sync.WaitGroup, fn func(w int)) {
+	wg.Add(1)
+	pool.add(w, job{fn: fn, wg: wg})
+}
+
+// measureWork returns the iterations per second the workers do on the
+// tuning grid with the current work setting, or false if interrupted
+//
+// The grid is worked out as many times as fit in tuneTime, with the
+// rows split up as calculateRow does, so every setting is measured on
+// the same work however long the frames are taking.
+func measureWork(interrupted func() bool) (float64, bool) {
+	var done atomic.Int64
+	t0 := time.Now()
+	for time.Since(t0) < tuneTime {
+		var wg sync.WaitGroup
+		for y := 0; y < tuneHeight; y++ {
+			if interrupted() {
+				wg.Wait()
+				return 0, false
+			}
+			fy := tuneY0 + tuneDx*float64(y)
+			splitRow(tuneWidth, func(start, end int) {
+				goWork(&wg, func() {
+					n := 0
+					for x := start; x < end; x++ {
+						i, _ := mandelbrot(0, complex(tuneX0+tuneDx*float64(x), fy), 0, tuneDepth)
+						n += i
+					}
+					done.Add(int64(n))
+				})
+			})
+		}
+		wg.Wait()
+	}
+	return float64(done.Load()) / time.Since(t0).Seconds(), true
+}
+
+// tunePending returns true if the work setting is being tuned or the
+// one chosen is due to be measured again
+func tunePending() bool {
+	return *workersFlag == 0 && (len(tuning) > 0 || time.Since(tunedAt) > retuneInterval)
+}
+
+// tuneStep measures each of the settings being tuned in turn,
+// tuneRounds times over so they all see the same conditions, and
+// keeps the fastest. Once tuned it measures the setting chosen again,
+// tuning again if it has slowed down.
+//
+// If interrupted the measurements are thrown away and the setting is
+// left as it was.
+func tuneStep(interrupted func() bool) {
+	if len(tuning) == 0 {
+		rate, ok := measureWork(interrupted)
+		if !ok {
+			return
+		}
+		tunedAt = time.Now()
+		if rate < retuneDrop*tunedRate {
+			startTuning()
+		}
+		return
+	}
+	was := work
+	rates := make(map[workSetting]float64)
+	for range tuneRounds {
+		for _, s := range tuning {
+			setWork(s)
+			rate, ok := measureWork(interrupted)
+			if !ok {
+				setWork(was)
+				return
+			}
+			rates[s] = max(rates[s], rate)
+		}
+	}
+	best := tuning[0]
+	for _, s := range tuning {
+		if rates[s] > rates[best] || rates[s] == rates[best] && s.workers < best.workers {
+			best = s
+		}
+	}
+	tuning = tuning[:0]
+	setWork(best)
+	tunedRate, tunedAt = rates[best], time.Now()
+}
+
+// workersInfo describes the work setting for the info overlay
+func workersInfo() string {
+	switch {
+	case *workersFlag > 0:
+		return fmt.Sprintf(tr("• %d workers"), work.workers)
+	case len(tuning) > 0:
+		return fmt.Sprintf(tr("• Tuning workers, trying %d"), work.workers)
+	}
+	return fmt.Sprintf(tr("• %d workers, tuned at %.3g iterations/s"), work.workers, tunedRate)
+}
+
+// splitRow calls fn on each piece of a row width pixels wide with
+// the start and end of the piece
+func splitRow(width int, fn func(start, end int)) {
+	step := width
+	if work.split {
+		step = tileWidth
+	}
+	for x := 0; x < width; x += step {
+		fn(x, min(x+step, width))
+	}
+}